@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	haproxy_models "github.com/haproxytech/client-native/v6/models"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -46,11 +47,58 @@ type BackendSpec struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	Servers Servers `json:"servers,omitempty"`
 
+	// server templates
+	// Servers that HAProxy discovers itself by resolving a DNS name, for targets outside of Kubernetes.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	ServerTemplates ServerTemplates `json:"server_templates,omitempty"`
+
 	// HTTP check list
 	// +operator-sdk:csv:customresourcedefinitions:type=spec
 	HTTPCheckList HTTPChecks `json:"http_check_list,omitempty"`
+
+	// topology
+	// Keeps traffic of servers generated from serviceRef in the zone of the HAProxy instance.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Topology *TopologyPolicy `json:"topology,omitempty"`
 }
 
+// TopologyPolicy defines how servers outside of the HAProxy instance's zone are treated. The zone of
+// an endpoint is read from its EndpointSlice topology hints or zone, or from the
+// topology.kubernetes.io/zone label of its node. Endpoints in an unknown zone are treated as local.
+// The policy is not applied when no server is in the local zone.
+type TopologyPolicy struct {
+	// mode
+	// Required: true
+	// Backup marks out-of-zone servers as backup servers, which only receive traffic when all
+	// local servers are down. Weight lowers the weight of out-of-zone servers to remoteWeight.
+	// Enum: ["Backup","Weight"]
+	// +kubebuilder:validation:Enum=Backup;Weight
+	Mode TopologyMode `json:"mode"`
+
+	// localZone
+	// Zone of the HAProxy instance. Defaults to the HAPROXY_ZONE environment variable of the operator.
+	LocalZone string `json:"localZone,omitempty"`
+
+	// remoteWeight
+	// Weight of out-of-zone servers in Weight mode, as a percentage of their configured weight.
+	// Maximum: 100
+	// Minimum: 0
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=10
+	RemoteWeight *int64 `json:"remoteWeight,omitempty"`
+}
+
+// TopologyMode selects how out-of-zone servers are treated.
+type TopologyMode string
+
+const (
+	// TopologyModeBackup marks out-of-zone servers as backup servers.
+	TopologyModeBackup TopologyMode = "Backup"
+	// TopologyModeWeight lowers the weight of out-of-zone servers.
+	TopologyModeWeight TopologyMode = "Weight"
+)
+
 type Balance struct {
 	// algorithm
 	// Required: true
@@ -108,7 +156,12 @@ func (s *Server) Validate() error {
 	if (s.Address != "" || s.Name != "") && s.ValueFrom != nil {
 		return fmt.Errorf("only one of address/name or valueFrom may be set for server")
 	}
-	return nil
+	if s.ValueFrom != nil {
+		if err := s.ValueFrom.Validate(); err != nil {
+			return err
+		}
+	}
+	return s.ServerParams.Validate()
 }
 
 // ValidateServers checks all servers in a Servers slice.
@@ -126,6 +179,27 @@ type ServerValueFromSource struct {
 	// serviceRef
 	// Reference to a Kubernetes Service to dynamically resolve endpoints.
 	ServiceRef *K8sServiceRef `json:"serviceRef,omitempty"`
+
+	// nodePortRef
+	// Reference to a Kubernetes Service exposed through a NodePort. A server is added for each
+	// eligible node, addressed by the node IP and the Service NodePort.
+	NodePortRef *K8sNodePortRef `json:"nodePortRef,omitempty"`
+
+	// weight
+	// Share of the traffic for the servers of this source, relative to the other sources with a
+	// weight, for example 90 for a stable and 10 for a canary Service. The share is split evenly
+	// across the generated servers and overrides their weight. 0 drains the source.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=256
+	Weight *int64 `json:"weight,omitempty"`
+}
+
+// Validate checks that exactly one source is set.
+func (v *ServerValueFromSource) Validate() error {
+	if (v.ServiceRef == nil) == (v.NodePortRef == nil) {
+		return fmt.Errorf("exactly one of serviceRef or nodePortRef must be set in valueFrom")
+	}
+	return nil
 }
 
 // K8sServiceRef allows referencing a Kubernetes Service object for dynamic endpoint resolution.
@@ -141,15 +215,207 @@ type K8sServiceRef struct {
 	Name string `json:"name"`
 
 	// port
-	// Name or number of the port to use from the Service.
+	// Name or number of the port to use from the Service. May be omitted when the
+	// Service exposes a single port, or when the server's port matches one of the
+	// Service ports.
+	Port string `json:"port,omitempty"`
+
+	// addressMode
+	// Selects the address and port used for the generated servers:
+	// PodIP uses the endpoint address and the resolved target port,
+	// NodePort uses the IP of each node hosting a ready endpoint and the Service NodePort,
+	// LoadBalancer uses the Service's load balancer ingress addresses and the Service port.
+	// Enum: ["PodIP","NodePort","LoadBalancer"]
+	// +kubebuilder:validation:Enum=PodIP;NodePort;LoadBalancer
+	// +kubebuilder:default=PodIP
+	AddressMode ServiceAddressMode `json:"addressMode,omitempty"`
+
+	// addressFamily
+	// Selects the IP family of the generated servers on dual-stack clusters:
+	// IPv4 or IPv6 use only addresses of that family,
+	// PreferIPv4 uses the IPv4 address of each endpoint and falls back to IPv6,
+	// DualStack adds a separate server for each family, suffixing the names of IPv6 servers with "-ipv6".
+	// FQDN endpoints and load balancer hostnames are used regardless of the family.
+	// Enum: ["IPv4","IPv6","PreferIPv4","DualStack"]
+	// +kubebuilder:validation:Enum=IPv4;IPv6;PreferIPv4;DualStack
+	// +kubebuilder:default=PreferIPv4
+	AddressFamily AddressFamily `json:"addressFamily,omitempty"`
+}
+
+// K8sNodePortRef allows referencing a Kubernetes Service to reach it through the NodePort on each node.
+type K8sNodePortRef struct {
+	// namespace
+	// Namespace of the Service. Defaults to the backend resource's namespace if omitted.
+	Namespace string `json:"namespace,omitempty"`
+
+	// name
+	// Required: true
+	// Name of the Service to reference.
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9-_.:]+$`
+	Name string `json:"name"`
+
+	// port
+	// Name or number of the Service port whose NodePort is used. May be omitted when the
+	// Service exposes a single port, or when the server's port matches one of the Service ports.
 	Port string `json:"port,omitempty"`
+
+	// nodeSelector
+	// Only nodes matching the selector are added. All nodes are eligible if omitted.
+	NodeSelector *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+
+	// addressType
+	// The node address used for the servers. Nodes without an address of this type are skipped.
+	// Enum: ["InternalIP","ExternalIP"]
+	// +kubebuilder:validation:Enum=InternalIP;ExternalIP
+	// +kubebuilder:default=InternalIP
+	AddressType corev1.NodeAddressType `json:"addressType,omitempty"`
+
+	// addressFamily
+	// Selects the IP family of the node addresses on dual-stack clusters. See serviceRef.addressFamily.
+	// Enum: ["IPv4","IPv6","PreferIPv4","DualStack"]
+	// +kubebuilder:validation:Enum=IPv4;IPv6;PreferIPv4;DualStack
+	// +kubebuilder:default=PreferIPv4
+	AddressFamily AddressFamily `json:"addressFamily,omitempty"`
+
+	// includeNotReady
+	// Also add nodes whose Ready condition is not true.
+	IncludeNotReady bool `json:"includeNotReady,omitempty"`
+
+	// includeUnschedulable
+	// Also add cordoned or otherwise unschedulable nodes.
+	IncludeUnschedulable bool `json:"includeUnschedulable,omitempty"`
 }
 
+// ServiceAddressMode selects how servers generated from a Service are addressed.
+type ServiceAddressMode string
+
+const (
+	// ServiceAddressModePodIP addresses each ready endpoint directly on its target port.
+	ServiceAddressModePodIP ServiceAddressMode = "PodIP"
+	// ServiceAddressModeNodePort addresses each node hosting a ready endpoint on the Service NodePort.
+	ServiceAddressModeNodePort ServiceAddressMode = "NodePort"
+	// ServiceAddressModeLoadBalancer addresses the Service's load balancer ingress on the Service port.
+	ServiceAddressModeLoadBalancer ServiceAddressMode = "LoadBalancer"
+)
+
+// AddressFamily selects the IP family of servers generated from a Service or its nodes.
+type AddressFamily string
+
+const (
+	// AddressFamilyIPv4 only uses IPv4 addresses.
+	AddressFamilyIPv4 AddressFamily = "IPv4"
+	// AddressFamilyIPv6 only uses IPv6 addresses.
+	AddressFamilyIPv6 AddressFamily = "IPv6"
+	// AddressFamilyPreferIPv4 uses the IPv4 address where one exists, and the IPv6 address otherwise.
+	AddressFamilyPreferIPv4 AddressFamily = "PreferIPv4"
+	// AddressFamilyDualStack uses the addresses of both families as separate servers.
+	AddressFamilyDualStack AddressFamily = "DualStack"
+)
+
 type ServerParams struct {
 	// check
 	// Enum: ["enabled","disabled"]
 	// +kubebuilder:validation:Enum=enabled;disabled;
 	Check string `json:"check,omitempty"`
+
+	// resolvers
+	// Name of the resolvers section used to resolve the server address at runtime.
+	// Servers generated from ExternalName Services use "default" if omitted.
+	// Pattern: ^[A-Za-z0-9-_.:]+$
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9-_.:]+$`
+	Resolvers string `json:"resolvers,omitempty"`
+
+	// init-addr
+	// Order of the methods used to resolve the server address at startup.
+	// Servers generated from ExternalName Services use "last,libc,none" if omitted.
+	// Pattern: ^[^\s]+$
+	// +kubebuilder:validation:Pattern=`^[^\s]+$`
+	InitAddr *string `json:"init-addr,omitempty"`
+
+	// resolve-prefer
+	// Address family preferred when the DNS response holds both IPv4 and IPv6 addresses.
+	// Enum: ["ipv4","ipv6"]
+	// +kubebuilder:validation:Enum=ipv4;ipv6;
+	ResolvePrefer string `json:"resolve-prefer,omitempty"`
+
+	// resolversRef
+	// Name of a Resolver in the Backend's namespace whose resolvers section is used.
+	// Mutually exclusive with resolvers.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`
+	ResolversRef string `json:"resolversRef,omitempty"`
+
+	// weight
+	// Maximum: 256
+	// Minimum: 0
+	// +kubebuilder:validation:Maximum=256
+	// +kubebuilder:validation:Minimum=0
+	Weight *int64 `json:"weight,omitempty"`
+
+	// backup
+	// Enum: ["enabled","disabled"]
+	// +kubebuilder:validation:Enum=enabled;disabled;
+	Backup string `json:"backup,omitempty"`
+}
+
+// Validate checks that resolvers and resolversRef are not both set.
+func (p *ServerParams) Validate() error {
+	if p.Resolvers != "" && p.ResolversRef != "" {
+		return fmt.Errorf("only one of resolvers or resolversRef may be set")
+	}
+	return nil
+}
+
+// HAProxy backend server templates array.
+type ServerTemplates []*ServerTemplate
+
+// HAProxy server template configuration. HAProxy creates the servers and fills their addresses
+// from the DNS records of the FQDN, which may be an SRV record.
+// Example: {"prefix":"www","num_or_range":"1-5","fqdn":"_http._tcp.www.example.com","resolvers":"default"}
+type ServerTemplate struct {
+	ServerParams `json:",inline"`
+
+	// prefix
+	// Required: true
+	// Pattern: ^[^\s]+$
+	// +kubebuilder:validation:Pattern=`^[^\s]+$`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Prefix string `json:"prefix"`
+
+	// num or range
+	// Required: true
+	// Number of servers, or a range of server numbers such as "1-5".
+	// Pattern: ^[0-9]+(-[0-9]+)?$
+	// +kubebuilder:validation:Pattern=`^[0-9]+(-[0-9]+)?$`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	NumOrRange string `json:"num_or_range"`
+
+	// fqdn
+	// Required: true
+	// DNS name resolved for the servers. SRV records also provide the ports.
+	// +kubebuilder:validation:MinLength=1
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Fqdn string `json:"fqdn"`
+
+	// id
+	ID *int64 `json:"id,omitempty"`
+
+	// port
+	// Maximum: 65535
+	// Minimum: 1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:validation:Minimum=1
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Port *int64 `json:"port,omitempty"`
+}
+
+// ValidateServerTemplates checks all server templates in a ServerTemplates slice.
+func ValidateServerTemplates(templates ServerTemplates) error {
+	for i, t := range templates {
+		if err := t.Validate(); err != nil {
+			return fmt.Errorf("server_templates[%d]: %w", i, err)
+		}
+	}
+	return nil
 }
 
 // BackendStatus defines the observed state of Backend.
@@ -165,6 +431,73 @@ type BackendStatus struct {
 	// Conditions store the status conditions of the Backend
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// servers
+	// Live state of the servers as reported by HAProxy.
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Servers []ServerStatus `json:"servers,omitempty"`
+
+	// upServers
+	// Number of servers HAProxy reports UP.
+	// +optional
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	UpServers int64 `json:"upServers"`
+
+	// totalServers
+	// Number of servers HAProxy reports for the backend.
+	// +optional
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	TotalServers int64 `json:"totalServers"`
+
+	// reloadID
+	// ID of the HAProxy reload applying the last changes to the backend, while it is pending or
+	// after it failed.
+	// +optional
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ReloadID string `json:"reloadID,omitempty"`
+}
+
+// ServerState is the state of a server in HAProxy.
+type ServerState string
+
+const (
+	// ServerStateUp means the server passes its health checks, or is not checked, and takes traffic.
+	ServerStateUp ServerState = "UP"
+	// ServerStateDown means the server fails its health checks.
+	ServerStateDown ServerState = "DOWN"
+	// ServerStateMaint means the server is in maintenance and takes no traffic.
+	ServerStateMaint ServerState = "MAINT"
+	// ServerStateDrain means the server only serves its existing connections.
+	ServerStateDrain ServerState = "DRAIN"
+	// ServerStateNoLB means the server is up but excluded from load balancing.
+	ServerStateNoLB ServerState = "NOLB"
+)
+
+// ServerStatus is the live state of a server as reported by HAProxy.
+type ServerStatus struct {
+	// name
+	// Name of the server.
+	Name string `json:"name"`
+
+	// state
+	// State of the server: UP, DOWN, MAINT, DRAIN or NOLB.
+	State ServerState `json:"state,omitempty"`
+
+	// checkStatus
+	// Result of the last health check, such as L7OK or L4CON.
+	CheckStatus string `json:"checkStatus,omitempty"`
+
+	// lastCheck
+	// Details of the last health check.
+	LastCheck string `json:"lastCheck,omitempty"`
+
+	// currentSessions
+	// Number of sessions the server is currently handling.
+	CurrentSessions int64 `json:"currentSessions,omitempty"`
+
+	// weight
+	// Effective weight of the server.
+	Weight int64 `json:"weight,omitempty"`
 }
 
 // HTTPChecks is a slice of HTTPCheck pointers.
@@ -215,10 +548,26 @@ func BackendSpecToModel(spec BackendSpec) *haproxy_models.Backend {
 	servers := make(map[string]haproxy_models.Server)
 	for _, s := range spec.Servers {
 		servers[s.Name] = haproxy_models.Server{
-			Name:    s.Name,
-			Address: s.Address,
-			Port:    s.Port,
-			ID:      s.ID,
+			ServerParams: serverParamsToModel(s.ServerParams),
+			Name:         s.Name,
+			Address:      s.Address,
+			Port:         s.Port,
+			ID:           s.ID,
+		}
+	}
+
+	var serverTemplates map[string]haproxy_models.ServerTemplate
+	if len(spec.ServerTemplates) > 0 {
+		serverTemplates = make(map[string]haproxy_models.ServerTemplate, len(spec.ServerTemplates))
+	}
+	for _, t := range spec.ServerTemplates {
+		serverTemplates[t.Prefix] = haproxy_models.ServerTemplate{
+			ServerParams: serverParamsToModel(t.ServerParams),
+			Prefix:       t.Prefix,
+			NumOrRange:   t.NumOrRange,
+			Fqdn:         t.Fqdn,
+			Port:         t.Port,
+			ID:           t.ID,
 		}
 	}
 
@@ -245,8 +594,9 @@ func BackendSpecToModel(spec BackendSpec) *haproxy_models.Backend {
 			Balance:  balance,
 			AdvCheck: spec.AdvCheck,
 		},
-		Servers:       servers,
-		HTTPCheckList: httpChecks,
+		Servers:         servers,
+		ServerTemplates: serverTemplates,
+		HTTPCheckList:   httpChecks,
 	}
 }
 
@@ -261,14 +611,27 @@ func ModelToBackendSpec(model *haproxy_models.Backend) BackendSpec {
 	servers := make(Servers, 0, len(model.Servers))
 	for _, s := range model.Servers {
 		server := &Server{
-			Name:    s.Name,
-			Address: s.Address,
-			Port:    s.Port,
-			ID:      s.ID,
+			ServerParams: modelToServerParams(s.ServerParams),
+			Name:         s.Name,
+			Address:      s.Address,
+			Port:         s.Port,
+			ID:           s.ID,
 		}
 		servers = append(servers, server)
 	}
 
+	var serverTemplates ServerTemplates
+	for _, t := range model.ServerTemplates {
+		serverTemplates = append(serverTemplates, &ServerTemplate{
+			ServerParams: modelToServerParams(t.ServerParams),
+			Prefix:       t.Prefix,
+			NumOrRange:   t.NumOrRange,
+			Fqdn:         t.Fqdn,
+			Port:         t.Port,
+			ID:           t.ID,
+		})
+	}
+
 	httpChecks := make(HTTPChecks, 0, len(model.HTTPCheckList))
 	for _, hc := range model.HTTPCheckList {
 		var headers []*ReturnHeader
@@ -287,16 +650,44 @@ func ModelToBackendSpec(model *haproxy_models.Backend) BackendSpec {
 	}
 
 	return BackendSpec{
-		Name:          model.Name,
-		Balance:       balance,
-		AdvCheck:      model.AdvCheck,
-		Servers:       servers,
-		HTTPCheckList: httpChecks,
+		Name:            model.Name,
+		Balance:         balance,
+		AdvCheck:        model.AdvCheck,
+		Servers:         servers,
+		ServerTemplates: serverTemplates,
+		HTTPCheckList:   httpChecks,
+	}
+}
+
+func serverParamsToModel(p ServerParams) haproxy_models.ServerParams {
+	return haproxy_models.ServerParams{
+		Check:         p.Check,
+		Resolvers:     p.Resolvers,
+		InitAddr:      p.InitAddr,
+		ResolvePrefer: p.ResolvePrefer,
+		Weight:        p.Weight,
+		Backup:        p.Backup,
+	}
+}
+
+func modelToServerParams(p haproxy_models.ServerParams) ServerParams {
+	return ServerParams{
+		Check:         p.Check,
+		Resolvers:     p.Resolvers,
+		InitAddr:      p.InitAddr,
+		ResolvePrefer: p.ResolvePrefer,
+		Weight:        p.Weight,
+		Backup:        p.Backup,
 	}
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Up",type=integer,JSONPath=`.status.upServers`
+// +kubebuilder:printcolumn:name="Total",type=integer,JSONPath=`.status.totalServers`
+// +kubebuilder:printcolumn:name="Available",type=string,JSONPath=`.status.conditions[?(@.type=="Available")].status`
+// +kubebuilder:printcolumn:name="Degraded",type=string,JSONPath=`.status.conditions[?(@.type=="Degraded")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Backend is the Schema for the backends API.
 // +kubebuilder:subresource:status
@@ -188,3 +188,98 @@ func TestBackendSpecToModel_FullRoundTrip(t *testing.T) {
 		t.Errorf("expected model:\n%#v\ngot:\n%#v", expected, model)
 	}
 }
+
+func TestServerValidate_ValueFrom(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  Server
+		wantErr bool
+	}{
+		{name: "static", server: Server{Name: "srv1", Address: "10.0.0.1"}},
+		{name: "serviceRef", server: Server{ValueFrom: &ServerValueFromSource{ServiceRef: &K8sServiceRef{Name: "web"}}}},
+		{name: "nodePortRef", server: Server{ValueFrom: &ServerValueFromSource{NodePortRef: &K8sNodePortRef{Name: "web"}}}},
+		{name: "both refs", server: Server{ValueFrom: &ServerValueFromSource{
+			ServiceRef:  &K8sServiceRef{Name: "web"},
+			NodePortRef: &K8sNodePortRef{Name: "web"},
+		}}, wantErr: true},
+		{name: "empty valueFrom", server: Server{ValueFrom: &ServerValueFromSource{}}, wantErr: true},
+		{name: "address and valueFrom", server: Server{Name: "srv1", Address: "10.0.0.1",
+			ValueFrom: &ServerValueFromSource{ServiceRef: &K8sServiceRef{Name: "web"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.server.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestServerParams_RoundTrip(t *testing.T) {
+	initAddr := "last,libc,none"
+	spec := BackendSpec{
+		Name: "backend3",
+		Servers: Servers{
+			&Server{
+				ServerParams: ServerParams{Check: "enabled", Resolvers: "default", InitAddr: &initAddr},
+				Name:         "legacy",
+				Address:      "legacy.example.com",
+			},
+		},
+	}
+
+	got := ModelToBackendSpec(BackendSpecToModel(spec))
+	if !reflect.DeepEqual(got.Servers[0].ServerParams, spec.Servers[0].ServerParams) {
+		t.Errorf("server parameters mismatch: expected %+v, got %+v", spec.Servers[0].ServerParams, got.Servers[0].ServerParams)
+	}
+}
+
+func TestBackendSpecToModel_ServerTemplates(t *testing.T) {
+	port := int64(8080)
+	spec := BackendSpec{
+		Name: "backend4",
+		ServerTemplates: ServerTemplates{
+			&ServerTemplate{
+				ServerParams: ServerParams{Resolvers: "corp-dns", ResolvePrefer: "ipv4"},
+				Prefix:       "www",
+				NumOrRange:   "1-5",
+				Fqdn:         "_http._tcp.www.example.com",
+				Port:         &port,
+			},
+		},
+	}
+
+	model := BackendSpecToModel(spec)
+	template, ok := model.ServerTemplates["www"]
+	if !ok {
+		t.Fatalf("server template www not found in model.ServerTemplates")
+	}
+	if template.NumOrRange != "1-5" || template.Fqdn != "_http._tcp.www.example.com" || *template.Port != port {
+		t.Errorf("server template fields mismatch: %+v", template)
+	}
+	if template.Resolvers != "corp-dns" || template.ResolvePrefer != "ipv4" {
+		t.Errorf("server template parameters mismatch: %+v", template.ServerParams)
+	}
+
+	got := ModelToBackendSpec(model)
+	if !reflect.DeepEqual(got.ServerTemplates, spec.ServerTemplates) {
+		t.Errorf("server templates mismatch: expected %+v, got %+v", spec.ServerTemplates, got.ServerTemplates)
+	}
+}
+
+func TestServerParamsValidate(t *testing.T) {
+	if err := (&ServerParams{Resolvers: "default"}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (&ServerParams{ResolversRef: "corp-dns"}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (&ServerParams{Resolvers: "default", ResolversRef: "corp-dns"}).Validate(); err == nil {
+		t.Error("expected error when both resolvers and resolversRef are set")
+	}
+	templates := ServerTemplates{{ServerParams: ServerParams{Resolvers: "default", ResolversRef: "corp-dns"}}}
+	if err := ValidateServerTemplates(templates); err == nil {
+		t.Error("expected error for server template with both resolvers and resolversRef")
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	haproxy_models "github.com/haproxytech/client-native/v6/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CutoverSpec defines the desired state of Cutover.
+type CutoverSpec struct {
+	// frontend
+	// Required: true
+	// Name of the HAProxy frontend that is switched. The frontend must be managed by the operator.
+	// Pattern: ^[A-Za-z0-9-_.:]+$
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9-_.:]+$`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Frontend string `json:"frontend"`
+
+	// backendRef
+	// Required: true
+	// Backend in the namespace of the Cutover that the frontend is switched to.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	BackendRef BackendReference `json:"backendRef"`
+
+	// rule
+	// Switches the backend of the use_backend rule with this condition instead of the
+	// default_backend of the frontend.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Rule *SwitchingRuleCondition `json:"rule,omitempty"`
+
+	// minUpServers
+	// Number of servers HAProxy must report UP in the target backend before the frontend is switched to it.
+	// Minimum: 0
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=1
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	MinUpServers int64 `json:"minUpServers,omitempty"`
+
+	// rollback
+	// Switches the frontend back to the backend it used before the last cutover, without waiting
+	// for its servers. Setting it back to false cuts over to backendRef again.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Rollback bool `json:"rollback,omitempty"`
+}
+
+// BackendReference refers to a Backend in the same namespace.
+type BackendReference struct {
+	// name
+	// Required: true
+	// Name of the Backend.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// SwitchingRuleCondition is the condition of a use_backend rule.
+type SwitchingRuleCondition struct {
+	// cond
+	// Enum: [if unless]
+	// +kubebuilder:validation:Enum=if;unless
+	// +kubebuilder:default=if
+	Cond string `json:"cond,omitempty"`
+
+	// cond_test
+	// Required: true
+	// ACL expression of the condition, such as "{ hdr(host) -i app.example.com }".
+	// +kubebuilder:validation:MinLength=1
+	CondTest string `json:"cond_test"`
+}
+
+// CutoverStatus defines the observed state of Cutover.
+type CutoverStatus struct {
+	// activeBackend
+	// HAProxy backend the frontend is switched to.
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	ActiveBackend string `json:"activeBackend,omitempty"`
+
+	// previousBackend
+	// HAProxy backend the frontend used before the last cutover, which a rollback switches back to.
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	PreviousBackend string `json:"previousBackend,omitempty"`
+
+	// previousBackendRecorded
+	// Whether previousBackend was recorded before switching the frontend. previousBackend is empty
+	// when the frontend had no default backend, or no rule with the condition, which a rollback then
+	// removes.
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	PreviousBackendRecorded bool `json:"previousBackendRecorded,omitempty"`
+
+	// Conditions store the status conditions of the Cutover
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+}
+
+// ----- Conversion helpers -----
+
+// SwitchingRuleToModel returns the use_backend rule with the condition that sends traffic to the backend.
+func SwitchingRuleToModel(condition SwitchingRuleCondition, backend string) *haproxy_models.BackendSwitchingRule {
+	cond := condition.Cond
+	if cond == "" {
+		cond = "if"
+	}
+	return &haproxy_models.BackendSwitchingRule{
+		Cond:     cond,
+		CondTest: condition.CondTest,
+		Name:     backend,
+	}
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Frontend",type=string,JSONPath=`.spec.frontend`
+// +kubebuilder:printcolumn:name="Active",type=string,JSONPath=`.status.activeBackend`
+// +kubebuilder:printcolumn:name="Previous",type=string,JSONPath=`.status.previousBackend`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Cutover is the Schema for the cutovers API. It switches a HAProxy frontend to a Backend in one
+// transaction once the Backend has enough servers UP, for blue/green deployments.
+type Cutover struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CutoverSpec   `json:"spec,omitempty"`
+	Status CutoverStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CutoverList contains a list of Cutover.
+type CutoverList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Cutover `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Cutover{}, &CutoverList{})
+}
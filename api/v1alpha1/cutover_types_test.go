@@ -0,0 +1,15 @@
+package v1alpha1
+
+import "testing"
+
+func TestSwitchingRuleToModel(t *testing.T) {
+	rule := SwitchingRuleToModel(SwitchingRuleCondition{CondTest: "{ hdr(host) -i app.example.com }"}, "app-green")
+	if rule.Cond != "if" || rule.CondTest != "{ hdr(host) -i app.example.com }" || rule.Name != "app-green" {
+		t.Errorf("unexpected rule: %+v", rule)
+	}
+
+	rule = SwitchingRuleToModel(SwitchingRuleCondition{Cond: "unless", CondTest: "is_internal"}, "app-blue")
+	if rule.Cond != "unless" || rule.Name != "app-blue" {
+		t.Errorf("unexpected rule: %+v", rule)
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RestoreAnnotation requests restoring the configuration of a HAProxyConfigRevision to HAProxy.
+// The operator removes it once the restore was attempted.
+const RestoreAnnotation = "external-haproxy-operator.ullberg.us/restore"
+
+// HAProxyConfigRevisionSpec defines the configuration of a HAProxyConfigRevision.
+type HAProxyConfigRevisionSpec struct {
+	// version
+	// Version of the HAProxy configuration in the Data Plane API.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Version int64 `json:"version"`
+
+	// source
+	// Resource whose changes the operator committed, such as "Backend default/web".
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Source string `json:"source,omitempty"`
+
+	// reloadID
+	// ID of the HAProxy reload applying the configuration, if the commit reloaded HAProxy.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	ReloadID string `json:"reloadID,omitempty"`
+
+	// checksum
+	// SHA-256 checksum of the recorded configuration.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Checksum string `json:"checksum"`
+
+	// size
+	// Size of the recorded configuration in bytes.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Size int64 `json:"size"`
+
+	// config
+	// Raw HAProxy configuration with its passwords redacted, compressed with gzip.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Config []byte `json:"config"`
+
+	// diff
+	// Unified diff of the raw configuration from the previous revision, truncated when it is large.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Diff string `json:"diff,omitempty"`
+}
+
+// HAProxyConfigRevisionStatus defines the observed state of HAProxyConfigRevision.
+type HAProxyConfigRevisionStatus struct {
+	// Conditions store the status conditions of the HAProxyConfigRevision
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+}
+
+// ----- Conversion helpers -----
+
+// CompressConfig compresses a raw HAProxy configuration for the config field of a revision.
+func CompressConfig(raw string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(w, raw); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RawConfig returns the raw HAProxy configuration of the revision.
+func (r *HAProxyConfigRevision) RawConfig() (string, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(r.Spec.Config))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = reader.Close() }()
+	raw, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Version",type=integer,JSONPath=`.spec.version`
+// +kubebuilder:printcolumn:name="Source",type=string,JSONPath=`.spec.source`
+// +kubebuilder:printcolumn:name="Reload",type=string,JSONPath=`.spec.reloadID`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// HAProxyConfigRevision is the Schema for the haproxyconfigrevisions API. It records the HAProxy
+// configuration after a change committed by the operator, and restores it when annotated with
+// external-haproxy-operator.ullberg.us/restore.
+type HAProxyConfigRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HAProxyConfigRevisionSpec   `json:"spec,omitempty"`
+	Status HAProxyConfigRevisionStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HAProxyConfigRevisionList contains a list of HAProxyConfigRevision.
+type HAProxyConfigRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HAProxyConfigRevision `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HAProxyConfigRevision{}, &HAProxyConfigRevisionList{})
+}
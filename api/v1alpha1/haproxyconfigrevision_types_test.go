@@ -0,0 +1,24 @@
+package v1alpha1
+
+import "testing"
+
+func TestHAProxyConfigRevisionRawConfig(t *testing.T) {
+	raw := "# _version=42\nglobal\n  daemon\n\nbackend web\n  mode http\n"
+	config, err := CompressConfig(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	revision := &HAProxyConfigRevision{Spec: HAProxyConfigRevisionSpec{Config: config}}
+	got, err := revision.RawConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != raw {
+		t.Errorf("got %q, expected %q", got, raw)
+	}
+
+	revision.Spec.Config = []byte(raw)
+	if _, err := revision.RawConfig(); err == nil {
+		t.Error("expected an error for a configuration that is not compressed")
+	}
+}
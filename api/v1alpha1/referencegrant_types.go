@@ -0,0 +1,125 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReferenceGrantSpec defines which resources in other namespaces may reference resources in the
+// namespace of the ReferenceGrant.
+type ReferenceGrantSpec struct {
+	// from
+	// Required: true
+	// The resources that are allowed to reference the resources listed in to.
+	// +kubebuilder:validation:MinItems=1
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	From []ReferenceGrantFrom `json:"from"`
+
+	// to
+	// Required: true
+	// The resources in this namespace that may be referenced.
+	// +kubebuilder:validation:MinItems=1
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	To []ReferenceGrantTo `json:"to"`
+}
+
+// ReferenceGrantFrom describes the resources that are trusted to reference resources in the grant's namespace.
+type ReferenceGrantFrom struct {
+	// group
+	// API group of the referencing resource.
+	// +kubebuilder:default=external-haproxy-operator.ullberg.us
+	Group string `json:"group,omitempty"`
+
+	// kind
+	// Kind of the referencing resource.
+	// Enum: ["Backend"]
+	// +kubebuilder:validation:Enum=Backend
+	// +kubebuilder:default=Backend
+	Kind string `json:"kind,omitempty"`
+
+	// namespace
+	// Required: true
+	// Namespace of the referencing resources.
+	Namespace string `json:"namespace"`
+}
+
+// ReferenceGrantTo describes the resources in the grant's namespace that may be referenced.
+type ReferenceGrantTo struct {
+	// group
+	// API group of the referenced resource. The core API group is the empty string.
+	Group string `json:"group,omitempty"`
+
+	// kind
+	// Kind of the referenced resource.
+	// Enum: ["Service"]
+	// +kubebuilder:validation:Enum=Service
+	// +kubebuilder:default=Service
+	Kind string `json:"kind,omitempty"`
+
+	// name
+	// Name of the referenced resource. All resources of the kind may be referenced if omitted.
+	Name string `json:"name,omitempty"`
+}
+
+// PermitsServiceReference reports whether the grant allows a Backend in the given namespace to
+// reference the named Service in the grant's namespace.
+func (g *ReferenceGrant) PermitsServiceReference(backendNamespace, serviceName string) bool {
+	fromPermitted := false
+	for _, from := range g.Spec.From {
+		if (from.Group == "" || from.Group == GroupVersion.Group) &&
+			(from.Kind == "" || from.Kind == "Backend") &&
+			from.Namespace == backendNamespace {
+			fromPermitted = true
+			break
+		}
+	}
+	if !fromPermitted {
+		return false
+	}
+	for _, to := range g.Spec.To {
+		if to.Group == "" && (to.Kind == "" || to.Kind == "Service") &&
+			(to.Name == "" || to.Name == serviceName) {
+			return true
+		}
+	}
+	return false
+}
+
+// +kubebuilder:object:root=true
+
+// ReferenceGrant allows Backends in other namespaces to reference Services in the namespace of the
+// ReferenceGrant. Without a grant, a Backend can only use Services in its own namespace.
+type ReferenceGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ReferenceGrantSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ReferenceGrantList contains a list of ReferenceGrant.
+type ReferenceGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ReferenceGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ReferenceGrant{}, &ReferenceGrantList{})
+}
@@ -0,0 +1,32 @@
+package v1alpha1
+
+import "testing"
+
+func TestReferenceGrantPermitsServiceReference(t *testing.T) {
+	grant := &ReferenceGrant{
+		Spec: ReferenceGrantSpec{
+			From: []ReferenceGrantFrom{{Kind: "Backend", Namespace: "tenant-a"}},
+			To:   []ReferenceGrantTo{{Kind: "Service", Name: "router"}},
+		},
+	}
+
+	if !grant.PermitsServiceReference("tenant-a", "router") {
+		t.Error("expected reference from tenant-a to router to be permitted")
+	}
+	if grant.PermitsServiceReference("tenant-b", "router") {
+		t.Error("expected reference from tenant-b to be rejected")
+	}
+	if grant.PermitsServiceReference("tenant-a", "database") {
+		t.Error("expected reference to an unlisted Service to be rejected")
+	}
+
+	grant.Spec.To = []ReferenceGrantTo{{Kind: "Service"}}
+	if !grant.PermitsServiceReference("tenant-a", "database") {
+		t.Error("expected a grant without a name to permit any Service")
+	}
+
+	grant.Spec.To = []ReferenceGrantTo{{Group: "apps", Kind: "Service"}}
+	if grant.PermitsServiceReference("tenant-a", "router") {
+		t.Error("expected a grant for another API group to be rejected")
+	}
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	haproxy_models "github.com/haproxytech/client-native/v6/models"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ResolverSpec defines the desired state of a HAProxy resolvers section.
+type ResolverSpec struct {
+	// name
+	// Required: true
+	// Name of the resolvers section in HAProxy.
+	// Pattern: ^[A-Za-z0-9-_.:]+$
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9-_.:]+$`
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Name string `json:"name"`
+
+	// nameservers
+	// DNS servers queried by the section. May be omitted when parse-resolv-conf is set.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Nameservers []Nameserver `json:"nameservers,omitempty"`
+
+	// parse-resolv-conf
+	// Add the nameservers from the HAProxy host's /etc/resolv.conf.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	ParseResolvConf bool `json:"parse-resolv-conf,omitempty"`
+
+	// accepted payload size
+	// Maximum accepted size of DNS responses, in bytes.
+	// Maximum: 65535
+	// Minimum: 512
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:validation:Minimum=512
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	AcceptedPayloadSize int64 `json:"accepted_payload_size,omitempty"`
+
+	// resolve retries
+	// Number of queries sent before giving up on a name.
+	// Minimum: 1
+	// +kubebuilder:validation:Minimum=1
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	ResolveRetries int64 `json:"resolve_retries,omitempty"`
+
+	// timeout resolve
+	// Time between two resolutions of a name, in milliseconds.
+	// Minimum: 0
+	// +kubebuilder:validation:Minimum=0
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	TimeoutResolve int64 `json:"timeout_resolve,omitempty"`
+
+	// timeout retry
+	// Time between two queries when no valid response was received, in milliseconds.
+	// Minimum: 0
+	// +kubebuilder:validation:Minimum=0
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	TimeoutRetry int64 `json:"timeout_retry,omitempty"`
+
+	// hold
+	// How long the last resolution is kept for each response status, in milliseconds.
+	// +operator-sdk:csv:customresourcedefinitions:type=spec
+	Hold *ResolverHold `json:"hold,omitempty"`
+}
+
+// Nameserver is a DNS server of a resolvers section.
+type Nameserver struct {
+	// name
+	// Required: true
+	// Pattern: ^[A-Za-z0-9-_.:]+$
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9-_.:]+$`
+	Name string `json:"name"`
+
+	// address
+	// Required: true
+	// Pattern: ^[^\s]+$
+	// +kubebuilder:validation:Pattern=`^[^\s]+$`
+	Address string `json:"address"`
+
+	// port
+	// Maximum: 65535
+	// Minimum: 1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=53
+	Port *int64 `json:"port,omitempty"`
+}
+
+// ResolverHold holds the hold timers of a resolvers section, in milliseconds.
+type ResolverHold struct {
+	// nx
+	// +kubebuilder:validation:Minimum=0
+	Nx *int64 `json:"nx,omitempty"`
+
+	// obsolete
+	// +kubebuilder:validation:Minimum=0
+	Obsolete *int64 `json:"obsolete,omitempty"`
+
+	// other
+	// +kubebuilder:validation:Minimum=0
+	Other *int64 `json:"other,omitempty"`
+
+	// refused
+	// +kubebuilder:validation:Minimum=0
+	Refused *int64 `json:"refused,omitempty"`
+
+	// timeout
+	// +kubebuilder:validation:Minimum=0
+	Timeout *int64 `json:"timeout,omitempty"`
+
+	// valid
+	// +kubebuilder:validation:Minimum=0
+	Valid *int64 `json:"valid,omitempty"`
+}
+
+// ResolverStatus defines the observed state of Resolver.
+type ResolverStatus struct {
+	// Conditions store the status conditions of the Resolver
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+}
+
+// ----- Conversion helpers -----
+
+func ResolverSpecToModel(spec ResolverSpec) *haproxy_models.Resolver {
+	nameservers := make(map[string]haproxy_models.Nameserver, len(spec.Nameservers))
+	for _, ns := range spec.Nameservers {
+		address := ns.Address
+		nameservers[ns.Name] = haproxy_models.Nameserver{
+			Name:    ns.Name,
+			Address: &address,
+			Port:    ns.Port,
+		}
+	}
+
+	resolver := &haproxy_models.Resolver{
+		ResolverBase: haproxy_models.ResolverBase{
+			Name:                spec.Name,
+			AcceptedPayloadSize: spec.AcceptedPayloadSize,
+			ParseResolvConf:     spec.ParseResolvConf,
+			ResolveRetries:      spec.ResolveRetries,
+			TimeoutResolve:      spec.TimeoutResolve,
+			TimeoutRetry:        spec.TimeoutRetry,
+		},
+		Nameservers: nameservers,
+	}
+	if spec.Hold != nil {
+		resolver.HoldNx = spec.Hold.Nx
+		resolver.HoldObsolete = spec.Hold.Obsolete
+		resolver.HoldOther = spec.Hold.Other
+		resolver.HoldRefused = spec.Hold.Refused
+		resolver.HoldTimeout = spec.Hold.Timeout
+		resolver.HoldValid = spec.Hold.Valid
+	}
+	return resolver
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// Resolver is the Schema for the resolvers API. It manages a HAProxy resolvers section used by
+// servers that HAProxy resolves through DNS.
+type Resolver struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ResolverSpec   `json:"spec,omitempty"`
+	Status ResolverStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResolverList contains a list of Resolver.
+type ResolverList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Resolver `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Resolver{}, &ResolverList{})
+}
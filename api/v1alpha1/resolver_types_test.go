@@ -0,0 +1,38 @@
+package v1alpha1
+
+import "testing"
+
+func TestResolverSpecToModel(t *testing.T) {
+	port := int64(53)
+	valid := int64(10000)
+	spec := ResolverSpec{
+		Name: "corp-dns",
+		Nameservers: []Nameserver{
+			{Name: "dns1", Address: "10.0.0.53", Port: &port},
+			{Name: "dns2", Address: "10.0.1.53"},
+		},
+		AcceptedPayloadSize: 8192,
+		ResolveRetries:      3,
+		Hold:                &ResolverHold{Valid: &valid},
+	}
+
+	model := ResolverSpecToModel(spec)
+
+	if model.Name != "corp-dns" || model.AcceptedPayloadSize != 8192 || model.ResolveRetries != 3 {
+		t.Errorf("unexpected resolver section: %+v", model.ResolverBase)
+	}
+	if model.HoldValid == nil || *model.HoldValid != valid || model.HoldNx != nil {
+		t.Errorf("unexpected hold timers: valid=%v nx=%v", model.HoldValid, model.HoldNx)
+	}
+	if len(model.Nameservers) != 2 {
+		t.Fatalf("expected 2 nameservers, got %d", len(model.Nameservers))
+	}
+	dns1 := model.Nameservers["dns1"]
+	if dns1.Address == nil || *dns1.Address != "10.0.0.53" || dns1.Port == nil || *dns1.Port != port {
+		t.Errorf("unexpected nameserver dns1: %+v", dns1)
+	}
+	dns2 := model.Nameservers["dns2"]
+	if dns2.Address == nil || *dns2.Address != "10.0.1.53" {
+		t.Errorf("unexpected nameserver dns2: %+v", dns2)
+	}
+}
@@ -17,17 +17,21 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -47,6 +51,7 @@ import (
 	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
 	"github.com/ullbergm/external-haproxy-operator/internal/controller"
 	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+	"github.com/ullbergm/external-haproxy-operator/internal/tracing"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -59,6 +64,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(externalhaproxyoperatorv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(monitoringv1.AddToScheme(scheme))
 
 	// Register metrics
 	monitoring.RegisterMetrics()
@@ -75,6 +81,9 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var installPrometheusRule bool
+	var configRevisionRetention int
+	var tracingConfig tracing.Config
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8443", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -91,6 +100,19 @@ func main() {
 		"The directory that contains the metrics server certificate.")
 	flag.StringVar(&metricsCertName, "metrics-cert-name", "tls.crt", "The name of the metrics server certificate file.")
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
+	flag.BoolVar(&installPrometheusRule, "install-prometheus-rule", true,
+		"If set, the PrometheusRule with the alerts and recording rules of the operator is installed "+
+			"in the namespace of the operator, when the Prometheus Operator CRDs are present.")
+	flag.IntVar(&configRevisionRetention, "config-revision-retention", 20,
+		"The number of HAProxyConfigRevisions recording the HAProxy configuration after the changes of the operator "+
+			"that are kept. Revisions are not recorded if 0.")
+	flag.StringVar(&tracingConfig.Endpoint, "otlp-endpoint", "",
+		"The host:port of the OTLP gRPC collector to export traces of the reconciliations and Data Plane API "+
+			"requests to. Tracing is disabled if empty.")
+	flag.BoolVar(&tracingConfig.Insecure, "otlp-insecure", false,
+		"If set, traces are exported to the OTLP collector without TLS.")
+	flag.Float64Var(&tracingConfig.SampleRatio, "otlp-sample-ratio", 1,
+		"The ratio of the reconciliations that are traced, between 0 and 1.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	opts := zap.Options{
@@ -244,6 +266,12 @@ func main() {
 				&externalhaproxyoperatorv1alpha1.Backend{}: {
 					Label: selector,
 				},
+				&externalhaproxyoperatorv1alpha1.Resolver{}: {
+					Label: selector,
+				},
+				&externalhaproxyoperatorv1alpha1.Cutover{}: {
+					Label: selector,
+				},
 			},
 		},
 	})
@@ -270,25 +298,141 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Set up tracing before the HAProxy client, whose requests are traced
+	ctx := ctrl.SetupSignalHandler()
+	shutdownTracing, err := tracing.Setup(ctx, tracingConfig)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	if tracingConfig.Endpoint != "" {
+		setupLog.Info("Exporting traces", "endpoint", tracingConfig.Endpoint)
+	}
+
 	// Now you can safely use these values to create your client
+	haproxyTLS, err := getHAProxyTLSConfig()
+	if err != nil {
+		setupLog.Error(err, "invalid HAProxy Data Plane API TLS configuration")
+		os.Exit(1)
+	}
 	haproxyConfig := haproxyclient.HAProxyConfig{
 		BaseURL:  url,
 		Username: user,
 		Password: pass,
+		TLS:      haproxyTLS,
+	}
+	var transactionTracker *controller.TransactionTracker
+	if namespace, err := getOperatorNamespace(); err != nil {
+		setupLog.Info("Not tracking HAProxy transactions, stale ones are not swept: " + err.Error())
+	} else {
+		transactionTracker = &controller.TransactionTracker{
+			Client:    mgr.GetClient(),
+			Reader:    mgr.GetAPIReader(),
+			Namespace: namespace,
+		}
+		haproxyConfig.Tracker = transactionTracker
 	}
 	haproxy := haproxyclient.NewHAProxyClient(haproxyConfig)
+	negotiateCtx, cancelNegotiate := context.WithTimeout(ctx, 30*time.Second)
+	if info, err := haproxy.Negotiate(negotiateCtx); err != nil {
+		// The requests, including those of the readiness check, fail and detect it again until it succeeds
+		setupLog.Error(err, "unable to detect the HAProxy Data Plane API version, retrying on the next requests")
+	} else {
+		setupLog.Info("Detected the HAProxy Data Plane API", "apiVersion", info.APIVersion, "haproxyVersion", info.HAProxyVersion)
+	}
+	cancelNegotiate()
+
+	var revisions *controller.ConfigRevisions
+	if configRevisionRetention > 0 {
+		revisions = &controller.ConfigRevisions{
+			Client:        mgr.GetClient(),
+			HAProxyClient: haproxy,
+			Retention:     configRevisionRetention,
+		}
+	}
 
 	if err := (&controller.BackendReconciler{
 		Client:        mgr.GetClient(),
 		Scheme:        mgr.GetScheme(),
 		Recorder:      mgr.GetEventRecorderFor("backend-controller"),
 		HAProxyClient: haproxy,
+		LocalZone:     getHAProxyZone(),
+		Revisions:     revisions,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Backend")
 		os.Exit(1)
 	}
+	if err := (&controller.ResolverReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorderFor("resolver-controller"),
+		HAProxyClient: haproxy,
+		Revisions:     revisions,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Resolver")
+		os.Exit(1)
+	}
+	if err := (&controller.CutoverReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorderFor("cutover-controller"),
+		HAProxyClient: haproxy,
+		Revisions:     revisions,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Cutover")
+		os.Exit(1)
+	}
+	if err := (&controller.HAProxyConfigRevisionReconciler{
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorderFor("haproxyconfigrevision-controller"),
+		HAProxyClient: haproxy,
+		Revisions:     revisions,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HAProxyConfigRevision")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
+	if err := mgr.Add(&controller.OrphanedBackendMonitor{
+		Client:        mgr.GetClient(),
+		HAProxyClient: haproxy,
+	}); err != nil {
+		setupLog.Error(err, "unable to add orphaned backend monitor to manager")
+		os.Exit(1)
+	}
+
+	if err := mgr.Add(&controller.ServerStatusMonitor{
+		Client:        mgr.GetClient(),
+		HAProxyClient: haproxy,
+	}); err != nil {
+		setupLog.Error(err, "unable to add server status monitor to manager")
+		os.Exit(1)
+	}
+
+	if transactionTracker != nil {
+		if err := mgr.Add(&controller.StaleTransactionSweeper{
+			Tracker:       transactionTracker,
+			HAProxyClient: haproxy,
+		}); err != nil {
+			setupLog.Error(err, "unable to add stale transaction sweeper to manager")
+			os.Exit(1)
+		}
+	}
+
+	if installPrometheusRule {
+		if namespace, err := getOperatorNamespace(); err != nil {
+			setupLog.Info("Not installing the PrometheusRule: " + err.Error())
+		} else if err := mgr.Add(&controller.PrometheusRuleInstaller{
+			Client:    mgr.GetClient(),
+			Reader:    mgr.GetAPIReader(),
+			Namespace: namespace,
+		}); err != nil {
+			setupLog.Error(err, "unable to add PrometheusRule installer to manager")
+			os.Exit(1)
+		}
+	}
+
 	if metricsCertWatcher != nil {
 		setupLog.Info("Adding metrics certificate watcher to manager")
 		if err := mgr.Add(metricsCertWatcher); err != nil {
@@ -313,9 +457,22 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("haproxy", haproxyclient.ReadyzCheck(haproxy)); err != nil {
+		setupLog.Error(err, "unable to set up HAProxy ready check")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	err = mgr.Start(ctx)
+
+	// Flush the traces of the last reconciliations
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		setupLog.Error(err, "unable to flush traces")
+	}
+	cancel()
+
+	if err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
@@ -388,3 +545,37 @@ func getHAProxyPass() (string, error) {
 	}
 	return val, nil
 }
+
+// getHAProxyTLSConfig returns the TLS configuration of the connection to the HAProxy API from env.
+// The files are typically mounted from Secrets, and are loaded again when they are rotated.
+func getHAProxyTLSConfig() (haproxyclient.TLSConfig, error) {
+	config := haproxyclient.TLSConfig{
+		CAFile:     os.Getenv("HAPROXY_API_CA_FILE"),
+		CertFile:   os.Getenv("HAPROXY_API_CERT_FILE"),
+		KeyFile:    os.Getenv("HAPROXY_API_KEY_FILE"),
+		ServerName: os.Getenv("HAPROXY_API_SERVER_NAME"),
+	}
+	if val := os.Getenv("HAPROXY_API_INSECURE_SKIP_VERIFY"); val != "" {
+		insecure, err := strconv.ParseBool(val)
+		if err != nil {
+			return haproxyclient.TLSConfig{}, fmt.Errorf("HAPROXY_API_INSECURE_SKIP_VERIFY: %w", err)
+		}
+		config.InsecureSkipVerify = insecure
+	}
+	return config, config.Validate()
+}
+
+// getHAProxyZone returns the zone of the HAProxy instance from env, or an empty string if not set
+func getHAProxyZone() string {
+	return os.Getenv("HAPROXY_ZONE")
+}
+
+// getOperatorNamespace returns the Namespace the operator runs in from env or an error if not set
+func getOperatorNamespace() (string, error) {
+	const envVar = "OPERATOR_NAMESPACE"
+	val, found := os.LookupEnv(envVar)
+	if !found || val == "" {
+		return "", fmt.Errorf("%s must be set", envVar)
+	}
+	return val, nil
+}
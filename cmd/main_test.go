@@ -143,3 +143,69 @@ func TestGetHAProxyPass_NotSet(t *testing.T) {
 		t.Error("expected error when HAPROXY_API_PASS is not set")
 	}
 }
+
+func TestGetHAProxyZone(t *testing.T) {
+	const envVar = "HAPROXY_ZONE"
+	expected := "zone-a"
+	os.Setenv(envVar, expected)
+	defer os.Unsetenv(envVar)
+
+	if zone := getHAProxyZone(); zone != expected {
+		t.Errorf("expected %q, got %q", expected, zone)
+	}
+}
+
+func TestGetOperatorNamespace(t *testing.T) {
+	const envVar = "OPERATOR_NAMESPACE"
+	expected := "haproxy-system"
+	os.Setenv(envVar, expected)
+	defer os.Unsetenv(envVar)
+
+	val, err := getOperatorNamespace()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if val != expected {
+		t.Errorf("expected %q, got %q", expected, val)
+	}
+}
+
+func TestGetOperatorNamespace_NotSet(t *testing.T) {
+	const envVar = "OPERATOR_NAMESPACE"
+	os.Unsetenv(envVar)
+
+	_, err := getOperatorNamespace()
+	if err == nil {
+		t.Error("expected error when OPERATOR_NAMESPACE is not set")
+	}
+}
+
+func TestGetHAProxyTLSConfig(t *testing.T) {
+	t.Setenv("HAPROXY_API_CA_FILE", "/etc/haproxy-api-tls/ca.crt")
+	t.Setenv("HAPROXY_API_CERT_FILE", "/etc/haproxy-api-tls/tls.crt")
+	t.Setenv("HAPROXY_API_KEY_FILE", "/etc/haproxy-api-tls/tls.key")
+	t.Setenv("HAPROXY_API_SERVER_NAME", "haproxy.internal")
+	t.Setenv("HAPROXY_API_INSECURE_SKIP_VERIFY", "false")
+
+	config, err := getHAProxyTLSConfig()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if config.CAFile != "/etc/haproxy-api-tls/ca.crt" || config.CertFile != "/etc/haproxy-api-tls/tls.crt" ||
+		config.KeyFile != "/etc/haproxy-api-tls/tls.key" || config.ServerName != "haproxy.internal" || config.InsecureSkipVerify {
+		t.Errorf("unexpected TLS configuration %+v", config)
+	}
+}
+
+func TestGetHAProxyTLSConfig_Invalid(t *testing.T) {
+	t.Setenv("HAPROXY_API_INSECURE_SKIP_VERIFY", "maybe")
+	if _, err := getHAProxyTLSConfig(); err == nil {
+		t.Error("expected error when HAPROXY_API_INSECURE_SKIP_VERIFY is not a bool")
+	}
+
+	t.Setenv("HAPROXY_API_INSECURE_SKIP_VERIFY", "")
+	t.Setenv("HAPROXY_API_CERT_FILE", "/etc/haproxy-api-tls/tls.crt")
+	if _, err := getHAProxyTLSConfig(); err == nil {
+		t.Error("expected error when the client certificate is set without its key")
+	}
+}
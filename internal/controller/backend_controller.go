@@ -18,10 +18,12 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -32,16 +34,18 @@ import (
 	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
 	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
 	"github.com/ullbergm/external-haproxy-operator/monitoring"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	"k8s.io/apimachinery/pkg/api/meta"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -51,6 +55,19 @@ type BackendReconciler struct {
 	Scheme        *runtime.Scheme
 	Recorder      record.EventRecorder
 	HAProxyClient haproxyclient.HAProxyClient
+
+	// LocalZone is the zone of the HAProxy instance, used by Backends with a topology policy that
+	// do not set their own.
+	LocalZone string
+	// Revisions records the HAProxy configuration after each change to a Backend.
+	Revisions *ConfigRevisions
+
+	// appliedFingerprints are the fingerprints of the configurations last applied to HAProxy by
+	// Backend, used to detect drift. Guarded by haproxyTransactionMu.
+	appliedFingerprints map[types.NamespacedName]uint64
+	// rollbacks are the snapshots of the configuration from before the last changes to Backends,
+	// restored when HAProxy fails to reload with them. Guarded by haproxyTransactionMu.
+	rollbacks map[types.NamespacedName]*rollbackState
 }
 
 func (r *BackendReconciler) setCondition(
@@ -58,6 +75,17 @@ func (r *BackendReconciler) setCondition(
 	condition metav1.Condition,
 ) {
 	meta.SetStatusCondition(&backend.Status.Conditions, condition)
+	if condition.Type != "ReconcilingComplete" {
+		return
+	}
+	reconciled := 1.0
+	if condition.Status != metav1.ConditionTrue {
+		reconciled = 0
+	}
+	if condition.Status == metav1.ConditionFalse {
+		monitoring.ReconcileFailuresTotal.WithLabelValues("backend", condition.Reason).Inc()
+	}
+	monitoring.BackendReconciled.WithLabelValues(backend.Namespace, backend.Name, backend.Spec.Name).Set(reconciled)
 }
 
 const backendFinalizer = "external-haproxy-operator.ullberg.us/finalizer"
@@ -65,10 +93,13 @@ const backendFinalizer = "external-haproxy-operator.ullberg.us/finalizer"
 // +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=backends,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=backends/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=backends/finalizers,verbs=update
+// +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=referencegrants,verbs=get;list;watch
+// +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=resolvers,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
-// +kubebuilder:rbac:groups=discoveryv1,resources=endpointslices,verbs=get;list;watch
-// +kubebuilder:rbac:groups=corev1,resources=services,verbs=get;list;watch
-// +kubebuilder:rbac:groups=corev1,resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -76,6 +107,17 @@ const backendFinalizer = "external-haproxy-operator.ullberg.us/finalizer"
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.21.0/pkg/reconcile
 func (r *BackendReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracer.Start(ctx, "BackendReconciler.Reconcile", trace.WithAttributes(
+		attribute.String("k8s.namespace.name", req.Namespace),
+		attribute.String("backend.name", req.Name),
+	))
+	result, err := r.reconcile(ctx, req)
+	endSpan(span, err)
+	return result, err
+}
+
+// reconcile reconciles a Backend within the span of Reconcile.
+func (r *BackendReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	reqLogger := logf.FromContext(ctx)
 
 	// Fetch the Backend instance
@@ -97,7 +139,11 @@ func (r *BackendReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	reqLogger.V(1).Info("Reconciling Backend")
 
 	// Loop through the backend's servers and validate them
-	if err := externalhaproxyoperatorv1alpha1.ValidateServers(backend.Spec.Servers); err != nil {
+	err = externalhaproxyoperatorv1alpha1.ValidateServers(backend.Spec.Servers)
+	if err == nil {
+		err = externalhaproxyoperatorv1alpha1.ValidateServerTemplates(backend.Spec.ServerTemplates)
+	}
+	if err != nil {
 		// Emit an event for the validation error
 		r.Recorder.Event(backend, "Warning", "ValidationError", err.Error())
 		reqLogger.Error(err, "Validation failed for Backend servers", "name", backend.Name)
@@ -117,59 +163,86 @@ func (r *BackendReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	// Create a copy of the backend variable and modify it to include the resolved servers.
 	modifiedBackend := backend.DeepCopy()
 
-	if err1 := resolveServerObjects(ctx, backend, reqLogger, r, modifiedBackend); err1 != nil {
-		return ctrl.Result{}, err1
+	unresolved, err := resolveServerObjects(ctx, backend, reqLogger, r, modifiedBackend)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.resolveResolverRefs(ctx, reqLogger, backend, modifiedBackend); err != nil {
+		return ctrl.Result{}, err
 	}
 	reqLogger.V(2).Info("Object processed", "before", backend, "after", modifiedBackend)
 
-	// Start a transaction in HAProxy
-	transaction, err := r.HAProxyClient.StartTransaction()
+	haproxyTransactionMu.Lock()
+	defer haproxyTransactionMu.Unlock()
+
+	// Apply weight changes at runtime first, so that shifting traffic does not reload HAProxy
+	backendModel := externalhaproxyoperatorv1alpha1.BackendSpecToModel(modifiedBackend.Spec)
+	fingerprint := backendFingerprint(backendModel)
+	if backend.GetDeletionTimestamp() == nil && r.rolledBack(backend, fingerprint) {
+		return r.keepRolledBack(ctx, reqLogger, backend)
+	}
+	if backend.GetDeletionTimestamp() == nil && r.reloadPending(backend, fingerprint) {
+		// The changes were committed already, only their reload is checked until it completes
+		if result, done := r.checkReload(ctx, reqLogger, backend); !done {
+			_ = r.Status().Update(ctx, backend)
+			return result, nil
+		}
+		return r.completeReconcile(ctx, backend, unresolved)
+	}
+	updated, err := r.HAProxyClient.UpdateServerWeights(ctx, backendModel)
 	if err != nil {
-		// Increment the error counter metric
-		monitoring.HAProxyClientErrorCountTotal.Inc()
-		reqLogger.Error(err, "Failed to start HAProxy transaction")
+		if haproxyclient.IsUnavailable(err) {
+			return r.waitForHAProxy(ctx, reqLogger, backend, err)
+		}
+		reqLogger.Error(err, "Failed to update server weights in HAProxy", "name", backend.Name)
 		// Set Reconciling Condition
 		r.setCondition(backend, metav1.Condition{
 			Type:    "ReconcilingComplete",
 			Status:  metav1.ConditionFalse,
 			Reason:  "HAProxyClientError",
-			Message: "Failed to start HAProxy transaction: " + err.Error(),
+			Message: "Failed to update server weights in HAProxy: " + err.Error(),
 		})
 		_ = r.Status().Update(ctx, backend)
 		return ctrl.Result{}, err
 	}
-
-	// Create a backend in HAProxy if it does not exist
-	if err := r.HAProxyClient.EnsureBackend(externalhaproxyoperatorv1alpha1.BackendSpecToModel(modifiedBackend.Spec)); err != nil {
-		// Increment the error counter metric
-		monitoring.HAProxyClientErrorCountTotal.Inc()
-		reqLogger.Error(err, "Failed to create backend in HAProxy", "name", backend.Name)
-		// Set Reconciling Condition
-		r.setCondition(backend, metav1.Condition{
-			Type:    "ReconcilingComplete",
-			Status:  metav1.ConditionFalse,
-			Reason:  "HAProxyClientError",
-			Message: "Failed to create backend in HAProxy: " + err.Error(),
-		})
-		_ = r.Status().Update(ctx, backend)
-		return ctrl.Result{}, err
+	if len(updated) > 0 {
+		reqLogger.Info("Updated server weights at runtime", "servers", updated)
 	}
 
-	// Commit the transaction in HAProxy
-	if _, err := r.HAProxyClient.CommitTransaction(transaction.ID, false); err != nil {
-		// Increment the error counter metric
-		monitoring.HAProxyClientErrorCountTotal.Inc()
-		reqLogger.Error(err, "Failed to commit HAProxy transaction", "transactionID", transaction.ID)
+	// Create or update the backend in HAProxy in a transaction
+	committed, err := r.HAProxyClient.RunTransaction(ctx, func(ctx context.Context) error {
+		return r.HAProxyClient.EnsureBackend(ctx, backendModel)
+	})
+	if err != nil {
+		if haproxyclient.IsUnavailable(err) {
+			return r.waitForHAProxy(ctx, reqLogger, backend, err)
+		}
+		if haproxyclient.IsUnsupported(err) {
+			return r.rejectUnsupported(ctx, reqLogger, backend, err)
+		}
+		if isInvalidConfiguration(err) {
+			return r.rejectInvalid(ctx, reqLogger, backend, err)
+		}
+		reqLogger.Error(err, "Failed to apply backend in HAProxy", "name", backend.Name)
 		// Set Reconciling Condition
 		r.setCondition(backend, metav1.Condition{
 			Type:    "ReconcilingComplete",
 			Status:  metav1.ConditionFalse,
 			Reason:  "HAProxyClientError",
-			Message: "Failed to commit HAProxy transaction: " + err.Error(),
+			Message: "Failed to apply backend in HAProxy: " + err.Error(),
 		})
 		_ = r.Status().Update(ctx, backend)
 		return ctrl.Result{}, err
 	}
+	// A noop commit deletes the transaction and returns no transaction
+	if committed.ID != "" {
+		r.Revisions.Record(ctx, "Backend "+req.String(), committed.ReloadID)
+	}
+	if r.detectDrift(backend, fingerprint, len(updated) > 0 || committed.ID != "") {
+		r.Recorder.Event(backend, "Warning", "DriftCorrected",
+			"The backend in HAProxy was changed outside of the operator and has been corrected")
+		reqLogger.Info("Corrected drift of the backend in HAProxy", "backend", backend.Spec.Name)
+	}
 
 	// Check if the Backend instance is marked to be deleted, which is
 	// indicated by the deletion timestamp being set.
@@ -179,7 +252,7 @@ func (r *BackendReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 			// Run finalization logic for backendFinalizer. If the
 			// finalization logic fails, don't remove the finalizer so
 			// that we can retry during the next reconciliation.
-			if err := r.finalizeBackend(reqLogger, backend); err != nil {
+			if err := r.finalizeBackend(ctx, reqLogger, backend); err != nil {
 				return ctrl.Result{}, err
 			}
 
@@ -211,169 +284,288 @@ func (r *BackendReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		}
 	}
 
-	// Set Reconciling Condition
-	r.setCondition(backend, metav1.Condition{
+	// Publish the live state of the servers, which the ServerStatusMonitor refreshes afterwards
+	updateServerStatus(ctx, reqLogger, r.HAProxyClient, backend)
+
+	// Changes committed with a reload pending are only live once HAProxy reloads
+	if committed.ReloadID != "" {
+		backend.Status.ReloadID = committed.ReloadID
+		r.rememberSnapshot(backend, committed, fingerprint)
+	}
+	if backend.Status.ReloadID != "" {
+		if result, done := r.checkReload(ctx, reqLogger, backend); !done {
+			_ = r.Status().Update(ctx, backend)
+			return result, nil
+		}
+	}
+	return r.completeReconcile(ctx, backend, unresolved)
+}
+
+// completeReconcile reports that the Backend was reconciled, or the source whose servers were
+// removed from it because they could not be resolved.
+func (r *BackendReconciler) completeReconcile(ctx context.Context, backend *externalhaproxyoperatorv1alpha1.Backend, unresolved *unresolvedSource) (ctrl.Result, error) {
+	condition := metav1.Condition{
 		Type:    "ReconcilingComplete",
 		Status:  metav1.ConditionTrue,
 		Reason:  "ReconcileCompleted",
 		Message: "Reconciliation completed successfully",
-	})
+	}
+	if unresolved != nil {
+		// The backend was applied without the servers of the source
+		condition.Status, condition.Reason = metav1.ConditionFalse, unresolved.reason
+		condition.Message = unresolved.message + "; its servers were removed from the backend"
+	}
+	r.setCondition(backend, condition)
 	_ = r.Status().Update(ctx, backend)
 
 	return ctrl.Result{}, nil
 }
 
-func resolveServerObjects(ctx context.Context, backend *externalhaproxyoperatorv1alpha1.Backend, reqLogger logr.Logger, r *BackendReconciler, modifiedBackend *externalhaproxyoperatorv1alpha1.Backend) error {
-	for _, server := range backend.Spec.Servers {
+// resolveServerObjects resolves the servers of the Backend into modifiedBackend. It returns the first
+// source whose servers were removed because they could not be resolved, if any.
+func resolveServerObjects(ctx context.Context, backend *externalhaproxyoperatorv1alpha1.Backend, reqLogger logr.Logger, r *BackendReconciler, modifiedBackend *externalhaproxyoperatorv1alpha1.Backend) (unresolved *unresolvedSource, err error) {
+	ctx, span := tracer.Start(ctx, "resolveServerObjects",
+		trace.WithAttributes(attribute.Int("backend.server_sources", len(backend.Spec.Servers))))
+	defer func() { endSpan(span, err) }()
+
+	sources := make([]serverSource, 0, len(backend.Spec.Servers))
+	topology := r.backendTopology(reqLogger, backend)
+	for i, server := range backend.Spec.Servers {
 		reqLogger.V(2).Info("Processing server", "object", server)
-		if server.ValueFrom != nil && server.ValueFrom.ServiceRef != nil {
-			serviceRef := server.ValueFrom.ServiceRef
-			if serviceRef.Name == "" {
-				err := errors.NewBadRequest("ServiceRef name must be specified for dynamic servers")
-				r.Recorder.Event(backend, "Warning", "ValidationError", err.Error())
-				reqLogger.Error(err, "Validation failed for Backend server")
-				// Set Reconciling Condition
-				r.setCondition(backend, metav1.Condition{
-					Type:    "ReconcilingComplete",
-					Status:  metav1.ConditionFalse,
-					Reason:  "ValidationError",
-					Message: "Failed to validate Backend server: " + err.Error(),
-				})
-				_ = r.Status().Update(ctx, backend)
-				return err
-			}
+		if server.ValueFrom == nil {
+			sources = append(sources, serverSource{servers: externalhaproxyoperatorv1alpha1.Servers{modifiedBackend.Spec.Servers[i]}})
+			continue
+		}
 
-			// Fetch the referenced Service to ensure it exists
-			service := &corev1.Service{}
-			err := r.Get(ctx, client.ObjectKey{Namespace: serviceRef.Namespace, Name: serviceRef.Name}, service)
-			if err != nil {
-				if errors.IsNotFound(err) {
-					err = errors.NewNotFound(corev1.Resource("Service"), serviceRef.Name)
-				}
-				r.Recorder.Event(backend, "Warning", "ServiceNotFound", err.Error())
-				reqLogger.Error(err, "Referenced Service not found", "serviceName", serviceRef.Name)
-				// Update the Backend status to reflect the error
-				r.setCondition(backend, metav1.Condition{
-					Type:    "ReconcilingComplete",
-					Status:  metav1.ConditionFalse,
-					Reason:  "ServiceNotFound",
-					Message: "Referenced Service not found: " + serviceRef.Name,
-				})
-				_ = r.Status().Update(ctx, backend)
-				return err
+		namespace, name, _ := serverServiceRef(backend, server)
+		// Each source gets its own span, covering the lookups of its Service, endpoints, Pods and Nodes
+		sourceCtx, sourceSpan := tracer.Start(ctx, "resolveServerSource", trace.WithAttributes(
+			attribute.String("server.name", server.Name),
+			attribute.String("k8s.namespace.name", namespace),
+			attribute.String("k8s.service.name", name),
+		))
+		source, err := r.resolveServerSource(sourceCtx, reqLogger, backend, server, namespace, name, topology)
+		endSpan(sourceSpan, err)
+		if err != nil {
+			return nil, err
+		}
+		if source != nil && source.unresolved != nil {
+			if unresolved == nil {
+				unresolved = source.unresolved
 			}
+			continue
+		}
+		if source != nil {
+			sources = append(sources, *source)
+		}
+	}
 
-			// Get the endpointslices for the service
-			endpoints := &discoveryv1.EndpointSliceList{}
-			err = r.List(ctx, endpoints, client.InNamespace(serviceRef.Namespace), client.MatchingLabels{"kubernetes.io/service-name": serviceRef.Name})
-			if err != nil {
-				r.Recorder.Event(backend, "Warning", "EndpointsListError", err.Error())
-				reqLogger.Error(err, "Failed to list endpoints for Service", "serviceName", serviceRef.Name)
-
-				// Set Reconciling Condition
-				r.setCondition(backend, metav1.Condition{
-					Type:    "ReconcilingComplete",
-					Status:  metav1.ConditionFalse,
-					Reason:  "EndpointsListError",
-					Message: "Failed to list endpoints for Service: " + serviceRef.Name,
-				})
-				_ = r.Status().Update(ctx, backend)
-				return err
-			}
-			if len(endpoints.Items) == 0 {
-				err = errors.NewNotFound(discoveryv1.Resource("EndpointSlice"), serviceRef.Name)
-				r.Recorder.Event(backend, "Warning", "EndpointsNotFound", err.Error())
-				reqLogger.Error(err, "No endpoints found for Service", "serviceName", serviceRef.Name)
-				// Set Reconciling Condition
-				r.setCondition(backend, metav1.Condition{
-					Type:    "ReconcilingComplete",
-					Status:  metav1.ConditionFalse,
-					Reason:  "EndpointsNotFound",
-					Message: "Failed to find endpoints for Service: " + serviceRef.Name,
-				})
-				_ = r.Status().Update(ctx, backend)
-				return err
-			}
-			// Add a server to the spec for each endpoint, skipping those where the pod is terminating, not running, or not ready
-			for _, endpointSlice := range endpoints.Items {
-				for _, endpoint := range endpointSlice.Endpoints {
-					if len(endpoint.Addresses) == 0 {
-						continue
-					}
-					shouldSkip := false
-					if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
-						pod := &corev1.Pod{}
-						err := r.Get(ctx, client.ObjectKey{Namespace: endpoint.TargetRef.Namespace, Name: endpoint.TargetRef.Name}, pod)
-						if err == nil {
-							podName := ""
-							podNs := ""
-							if endpoint.TargetRef != nil {
-								podName = endpoint.TargetRef.Name
-								podNs = endpoint.TargetRef.Namespace
-							}
-
-							// Skip if terminating
-							if pod.DeletionTimestamp != nil {
-								reqLogger.V(2).Info("Skipping endpoint for terminating pod", "podName", podName, "namespace", podNs)
-								shouldSkip = true
-							}
-							// Skip if not running
-							if pod.Status.Phase != corev1.PodRunning {
-								reqLogger.V(2).Info("Skipping endpoint for non-running pod", "podName", pod.Name, "namespace", pod.Namespace, "podPhase", pod.Status.Phase)
-								shouldSkip = true
-							}
-							// Skip if not all containers are ready
-							ready := false
-							for _, cond := range pod.Status.Conditions {
-								if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
-									ready = true
-									break
-								}
-							}
-							if !ready {
-								reqLogger.V(2).Info("Skipping endpoint for non-ready pod", "podName", pod.Name, "namespace", pod.Namespace)
-								shouldSkip = true
-							}
-						}
-					}
-					if shouldSkip {
-						continue
-					}
-					// Create a new server with the address from the endpoint
-					newServer := externalhaproxyoperatorv1alpha1.Server{
-						Name:    *endpoint.NodeName,
-						Address: endpoint.Addresses[0],
-						Port:    server.Port,
-					}
-					modifiedBackend.Spec.Servers = append(modifiedBackend.Spec.Servers, &newServer)
-				}
-			}
-			// Remove the original server if it was dynamic
-			if server.ValueFrom != nil {
-				for i, s := range modifiedBackend.Spec.Servers {
-					if s.Name == server.Name && s.ValueFrom != nil {
-						modifiedBackend.Spec.Servers = append(modifiedBackend.Spec.Servers[:i], modifiedBackend.Spec.Servers[i+1:]...)
-						break
-					}
-				}
+	// Split the traffic between weighted sources, then prefer the servers in the zone of the
+	// HAProxy instance
+	distributeSourceWeights(sources)
+	servers := make(externalhaproxyoperatorv1alpha1.Servers, 0, len(sources))
+	for _, source := range sources {
+		if topology != nil && source.zones != nil {
+			topology.apply(reqLogger, source.servers, source.zones)
+		}
+		servers = appendServers(servers, source.servers)
+	}
+	modifiedBackend.Spec.Servers = servers
+	return unresolved, nil
+}
+
+// resolveServerSource resolves the servers of a server of a Backend that has a ValueFrom. It
+// returns no source when there are no servers yet, and an unresolved source when the referenced
+// Service is not permitted or does not exist, so that servers exposed before are removed.
+func (r *BackendReconciler) resolveServerSource(
+	ctx context.Context,
+	reqLogger logr.Logger,
+	backend *externalhaproxyoperatorv1alpha1.Backend,
+	server *externalhaproxyoperatorv1alpha1.Server,
+	namespace, name string,
+	topology *zoneTopology,
+) (*serverSource, error) {
+	permitted, err := r.serviceReferencePermitted(ctx, backend, namespace, name)
+	if err != nil {
+		return nil, r.failServerResolution(ctx, reqLogger, backend, "ReferenceGrantListError",
+			"Failed to list ReferenceGrants in namespace: "+namespace, err)
+	}
+	if !permitted {
+		err = errors.NewForbidden(corev1.Resource("Service"), name,
+			fmt.Errorf("no ReferenceGrant in namespace %s allows Backends in namespace %s to reference it", namespace, backend.Namespace))
+		return r.skipServerSource(reqLogger, backend, "RefNotPermitted",
+			"Reference to Service "+namespace+"/"+name+" is not permitted: "+err.Error(), err), nil
+	}
+
+	if nodePortRef := server.ValueFrom.NodePortRef; nodePortRef != nil {
+		service := &corev1.Service{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: nodePortRef.Name}, service)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				err = errors.NewNotFound(corev1.Resource("Service"), nodePortRef.Name)
+				return r.skipServerSource(reqLogger, backend, "ServiceNotFound",
+					"Referenced Service not found: "+nodePortRef.Name, err), nil
 			}
+			return nil, r.failServerResolution(ctx, reqLogger, backend, "ServiceNotFound",
+				"Failed to get referenced Service: "+nodePortRef.Name, err)
+		}
+		resolved, err := r.nodePortRefServers(ctx, reqLogger, nodePortRef, service, server)
+		if err != nil {
+			return nil, r.failServerResolution(ctx, reqLogger, backend, "NodePortNotFound",
+				"Failed to resolve node ports for Service: "+err.Error(), err)
 		}
+		return &serverSource{servers: resolved, weight: server.ValueFrom.Weight}, nil
 	}
-	return nil
+
+	serviceRef := server.ValueFrom.ServiceRef
+	if serviceRef.Name == "" {
+		err := errors.NewBadRequest("ServiceRef name must be specified for dynamic servers")
+		return nil, r.failServerResolution(ctx, reqLogger, backend, "ValidationError",
+			"Failed to validate Backend server: "+err.Error(), err)
+	}
+
+	// Fetch the referenced Service to ensure it exists
+	service := &corev1.Service{}
+	err = r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: serviceRef.Name}, service)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			err = errors.NewNotFound(corev1.Resource("Service"), serviceRef.Name)
+			return r.skipServerSource(reqLogger, backend, "ServiceNotFound",
+				"Referenced Service not found: "+serviceRef.Name, err), nil
+		}
+		return nil, r.failServerResolution(ctx, reqLogger, backend, "ServiceNotFound",
+			"Failed to get referenced Service: "+serviceRef.Name, err)
+	}
+
+	// Resolve the referenced port against the Service's ports
+	servicePort, err := resolveServicePort(service, serviceRef.Port, server.Port)
+	if err != nil {
+		return nil, r.failServerResolution(ctx, reqLogger, backend, "ServicePortNotFound",
+			"Failed to resolve port for Service: "+err.Error(), err)
+	}
+
+	var resolved externalhaproxyoperatorv1alpha1.Servers
+	if service.Spec.Type == corev1.ServiceTypeExternalName {
+		externalServer, err := externalNameServer(service, servicePort, server)
+		if err != nil {
+			return nil, r.failServerResolution(ctx, reqLogger, backend, "ServicePortNotFound",
+				"Failed to resolve port for Service: "+err.Error(), err)
+		}
+		return &serverSource{
+			servers: externalhaproxyoperatorv1alpha1.Servers{externalServer},
+			weight:  server.ValueFrom.Weight,
+		}, nil
+	}
+	if serviceRef.AddressMode == externalhaproxyoperatorv1alpha1.ServiceAddressModeLoadBalancer {
+		resolved, err = loadBalancerServers(service, servicePort, server, serviceRef.AddressFamily)
+		if err != nil {
+			return nil, r.failServerResolution(ctx, reqLogger, backend, "LoadBalancerNotReady",
+				"Failed to resolve load balancer address for Service: "+err.Error(), err)
+		}
+		return &serverSource{servers: resolved, weight: server.ValueFrom.Weight}, nil
+	}
+
+	// Get the endpointslices for the service
+	endpoints := &discoveryv1.EndpointSliceList{}
+	err = r.List(ctx, endpoints, client.InNamespace(namespace), client.MatchingLabels{"kubernetes.io/service-name": serviceRef.Name})
+	if err != nil {
+		return nil, r.failServerResolution(ctx, reqLogger, backend, "EndpointsListError",
+			"Failed to list endpoints for Service: "+serviceRef.Name, err)
+	}
+	if len(endpoints.Items) == 0 && len(service.Spec.Selector) == 0 {
+		// Services without a selector get their EndpointSlices from whoever manages them,
+		// and may legitimately have none yet.
+		reqLogger.V(1).Info("No EndpointSlices for Service without selector", "service", serviceRef.Name, "namespace", namespace)
+		return nil, nil
+	}
+	if len(endpoints.Items) == 0 {
+		err = errors.NewNotFound(discoveryv1.Resource("EndpointSlice"), serviceRef.Name)
+		return r.skipServerSource(reqLogger, backend, "EndpointsNotFound",
+			"Failed to find endpoints for Service: "+serviceRef.Name, err), nil
+	}
+
+	// Add a server for each ready endpoint, addressed according to the address mode
+	ready := r.readyEndpoints(ctx, reqLogger, endpoints.Items)
+	var zones map[string]endpointZone
+	if serviceRef.AddressMode == externalhaproxyoperatorv1alpha1.ServiceAddressModeNodePort {
+		resolved, err = r.nodePortServers(ctx, ready, servicePort, server, serviceRef.AddressFamily)
+		if err != nil {
+			return nil, r.failServerResolution(ctx, reqLogger, backend, "NodePortNotFound",
+				"Failed to resolve node ports for Service: "+err.Error(), err)
+		}
+		if topology != nil {
+			zones = r.nodeServerZones(ctx, ready)
+		}
+	} else {
+		ready = filterEndpointFamilies(ready, serviceRef.AddressFamily)
+		resolved, err = podIPServers(ready, servicePort, server)
+		if err != nil {
+			return nil, r.failServerResolution(ctx, reqLogger, backend, "ServicePortNotFound",
+				"Failed to resolve port for Service: "+err.Error(), err)
+		}
+		if topology != nil {
+			zones = r.endpointZones(ctx, ready)
+		}
+	}
+	return &serverSource{servers: resolved, weight: server.ValueFrom.Weight, zones: zones}, nil
 }
 
-func (r *BackendReconciler) finalizeBackend(reqLogger logr.Logger, m *externalhaproxyoperatorv1alpha1.Backend) error {
+// failServerResolution records a failure to resolve the servers of a Backend as an event and a
+// ReconcilingComplete condition, and returns the error.
+func (r *BackendReconciler) failServerResolution(
+	ctx context.Context,
+	reqLogger logr.Logger,
+	backend *externalhaproxyoperatorv1alpha1.Backend,
+	reason, message string,
+	err error,
+) error {
+	r.Recorder.Event(backend, "Warning", reason, err.Error())
+	reqLogger.Error(err, "Failed to resolve Backend servers", "reason", reason)
+	// Set Reconciling Condition
+	r.setCondition(backend, metav1.Condition{
+		Type:    "ReconcilingComplete",
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+	_ = r.Status().Update(ctx, backend)
+	return err
+}
+
+// unresolvedSource is the reason the servers of a source could not be resolved, reported in the
+// ReconcilingComplete condition once the backend is applied without them.
+type unresolvedSource struct {
+	reason  string
+	message string
+}
+
+// skipServerSource records that the servers of a source are removed from the backend as an event,
+// and returns the unresolved source. The rest of the backend is still applied, so that revoking a
+// ReferenceGrant or deleting a Service removes the servers exposed through it from HAProxy.
+func (r *BackendReconciler) skipServerSource(
+	reqLogger logr.Logger,
+	backend *externalhaproxyoperatorv1alpha1.Backend,
+	reason, message string,
+	err error,
+) *serverSource {
+	r.Recorder.Event(backend, "Warning", reason, err.Error())
+	reqLogger.Info("Removing the servers of an unresolved source from the backend", "reason", reason, "error", err.Error())
+	return &serverSource{unresolved: &unresolvedSource{reason: reason, message: message}}
+}
+
+func (r *BackendReconciler) finalizeBackend(ctx context.Context, reqLogger logr.Logger, m *externalhaproxyoperatorv1alpha1.Backend) error {
 	// Delete the resources associated with this Backend
 	reqLogger.Info("Finalizing Backend", "name", m.Name)
 
 	// Delete the backend from HAProxy
-	if err := r.HAProxyClient.DeleteBackend(m.Name); err != nil {
-		// Increment the error counter metric
-		monitoring.HAProxyClientErrorCountTotal.Inc()
-
+	if err := r.HAProxyClient.DeleteBackend(ctx, m.Name); err != nil {
+		monitoring.ReconcileFailuresTotal.WithLabelValues("backend", "FinalizeFailed").Inc()
 		reqLogger.Error(err, "Failed to delete backend from HAProxy", "name", m.Name)
 		return err
 	}
+	monitoring.DeleteBackendMetrics(m.Namespace, m.Name)
+	r.forgetBackend(m)
+	r.Revisions.Record(ctx, "Backend "+m.Namespace+"/"+m.Name, "")
 
 	// Emit an event for the finalization
 	r.Recorder.Event(m, "Normal", "Finalized", "Successfully finalized backend")
@@ -383,8 +575,92 @@ func (r *BackendReconciler) finalizeBackend(reqLogger logr.Logger, m *externalha
 	return nil
 }
 
+// waitForHAProxy records that the Data Plane API is unavailable in the ReconcilingComplete condition
+// and requeues the Backend, instead of failing the reconcile and retrying it with a backoff while the
+// HAProxy client short-circuits the requests.
+func (r *BackendReconciler) waitForHAProxy(ctx context.Context, reqLogger logr.Logger, backend *externalhaproxyoperatorv1alpha1.Backend, err error) (ctrl.Result, error) {
+	reqLogger.Info("HAProxy Data Plane API is unavailable, requeueing", "requeueAfter", haproxyUnavailableRequeueAfter)
+	r.setCondition(backend, metav1.Condition{
+		Type:    "ReconcilingComplete",
+		Status:  metav1.ConditionFalse,
+		Reason:  "HAProxyUnavailable",
+		Message: "Waiting for the HAProxy Data Plane API to become available: " + err.Error(),
+	})
+	_ = r.Status().Update(ctx, backend)
+	return ctrl.Result{RequeueAfter: haproxyUnavailableRequeueAfter}, nil
+}
+
+// keepRolledBack reports that the changes to the backend were rolled back because HAProxy failed to
+// reload with them, and are not applied again until the backend changes. The reload of the rollback
+// is checked until it completes.
+func (r *BackendReconciler) keepRolledBack(ctx context.Context, reqLogger logr.Logger, backend *externalhaproxyoperatorv1alpha1.Backend) (ctrl.Result, error) {
+	reqLogger.V(1).Info("Not applying the changes HAProxy failed to reload with again")
+	result := ctrl.Result{}
+	if backend.Status.ReloadID != "" {
+		result = r.checkRollbackReload(ctx, reqLogger, backend)
+	}
+	updateServerStatus(ctx, reqLogger, r.HAProxyClient, backend)
+	reason, message := "RolledBack", "The changes were rolled back because HAProxy failed to reload with them"
+	if condition := meta.FindStatusCondition(backend.Status.Conditions, "RolledBack"); condition != nil {
+		message = condition.Message
+		if condition.Status == metav1.ConditionFalse {
+			reason = condition.Reason
+		}
+	}
+	r.setCondition(backend, metav1.Condition{
+		Type:    "ReconcilingComplete",
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+	_ = r.Status().Update(ctx, backend)
+	return result, nil
+}
+
+// rejectInvalid reports that HAProxy rejected the configuration of the backend before it was
+// committed, naming the field of the spec that generated the rejected line. The backend is only
+// retried when the rejected line is outside of its backend section, as it fails the same way until
+// its spec changes otherwise.
+func (r *BackendReconciler) rejectInvalid(ctx context.Context, reqLogger logr.Logger, backend *externalhaproxyoperatorv1alpha1.Backend, err error) (ctrl.Result, error) {
+	message, own := invalidConfiguration(err, "backend "+backend.Spec.Name, func(invalid haproxyclient.ErrInvalidConfiguration) string {
+		return backendSpecField(backend.Spec, invalid)
+	})
+	reqLogger.Info("HAProxy rejected the configuration of the backend", "name", backend.Name, "reason", message)
+	r.Recorder.Event(backend, "Warning", "ValidationFailed", message)
+	r.setCondition(backend, metav1.Condition{
+		Type:    "ReconcilingComplete",
+		Status:  metav1.ConditionFalse,
+		Reason:  "ValidationFailed",
+		Message: message,
+	})
+	_ = r.Status().Update(ctx, backend)
+	if !own {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// rejectUnsupported reports that the backend sets a field the version of HAProxy does not support.
+// The backend is not requeued, as it fails the same way until its spec changes.
+func (r *BackendReconciler) rejectUnsupported(ctx context.Context, reqLogger logr.Logger, backend *externalhaproxyoperatorv1alpha1.Backend, err error) (ctrl.Result, error) {
+	message := unsupportedMessage(err)
+	reqLogger.Info("Backend uses a feature HAProxy does not support", "name", backend.Name, "reason", message)
+	r.Recorder.Event(backend, "Warning", "Unsupported", message)
+	r.setCondition(backend, metav1.Condition{
+		Type:    "ReconcilingComplete",
+		Status:  metav1.ConditionFalse,
+		Reason:  "Unsupported",
+		Message: message,
+	})
+	_ = r.Status().Update(ctx, backend)
+	return ctrl.Result{}, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *BackendReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := setupIndexers(context.Background(), mgr); err != nil {
+		return err
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&externalhaproxyoperatorv1alpha1.Backend{}).
 		Named("backend").
@@ -417,80 +693,61 @@ func (r *BackendReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				return enqueueRequestsFromEndpointSlices(ctx, obj, r)
 			}),
 		).
+		// Also watch for grants allowing or revoking cross-namespace Service references
+		Watches(&externalhaproxyoperatorv1alpha1.ReferenceGrant{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+				return enqueueRequestsFromReferenceGrants(ctx, obj, r)
+			}),
+		).
+		// Also watch for changes to the resolvers sections referenced by servers
+		Watches(&externalhaproxyoperatorv1alpha1.Resolver{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+				return enqueueRequestsFromResolvers(ctx, obj, r)
+			}),
+		).
+		// Also watch for nodes becoming eligible or ineligible for backends addressed by node IPs
+		Watches(&corev1.Node{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+				return enqueueRequestsFromNodes(ctx, obj, r)
+			}),
+			builder.WithPredicates(nodeChangedPredicate),
+		).
 		Complete(r)
 }
 
 func enqueueRequestsFromEndpointSlices(ctx context.Context, obj client.Object, r *BackendReconciler) []reconcile.Request {
 	reqLogger := logf.FromContext(ctx)
 	reqLogger.V(2).Info("Processing EndpointSlice for Backend reconciliation", "endpointSliceName", obj.GetName(), "namespace", obj.GetNamespace())
-	var reqs []reconcile.Request
-	// List all Backend resources
-	backendList := &externalhaproxyoperatorv1alpha1.BackendList{}
-	if err := r.List(ctx, backendList, client.InNamespace("")); err != nil {
+	serviceName := obj.GetLabels()["kubernetes.io/service-name"]
+	if serviceName == "" {
 		return nil
 	}
-	for _, backend := range backendList.Items {
-		// Get the service object from the backend and then get the endpointslices for that service and check if it matches the EndpointSlice
-		for _, server := range backend.Spec.Servers {
-			// Get the service reference from the server
-			if server.ValueFrom != nil && server.ValueFrom.ServiceRef != nil {
-				// Get the service object from kubernetes
-				service := &corev1.Service{}
-				err := r.Get(ctx, client.ObjectKey{
-					Namespace: server.ValueFrom.ServiceRef.Namespace,
-					Name:      server.ValueFrom.ServiceRef.Name,
-				}, service)
-				if err != nil {
-					if errors.IsNotFound(err) {
-						reqLogger.V(2).Info("Service not found for Backend", "serviceName", server.ValueFrom.ServiceRef.Name)
-						continue
-					}
-					reqLogger.Error(err, "Failed to get Service for Backend", "serviceName", server.ValueFrom.ServiceRef.Name)
-					continue
-				}
-				// Check if the EndpointSlice matches the service
-				if len(obj.GetLabels()) > 0 && obj.GetLabels()["kubernetes.io/service-name"] == service.Name &&
-					obj.GetNamespace() == service.Namespace {
-					reqs = append(reqs, reconcile.Request{
-						NamespacedName: types.NamespacedName{
-							Name:      backend.Name,
-							Namespace: backend.Namespace,
-						},
-					})
-					reqLogger.V(2).Info("Found matching Backend for EndpointSlice", "backendName", backend.Name, "serviceName", service.Name, "endpointSliceName", obj.GetName())
-					break // No need to check other servers in this backend
-				} else {
-					reqLogger.V(2).Info("EndpointSlice does not match Backend", "backendName", backend.Name, "serviceName", service.Name, "endpointSliceName", obj.GetName())
-				}
-			}
-		}
-	}
-	return reqs
+	return requestsForIndexedBackends(ctx, r, backendServiceIndex, objectIndexKey(obj.GetNamespace(), serviceName))
 }
 
 func enqueueRequestsFromServices(ctx context.Context, obj client.Object, r *BackendReconciler) []reconcile.Request {
 	reqLogger := logf.FromContext(ctx)
 	reqLogger.V(2).Info("Processing Service for Backend reconciliation", "serviceName", obj.GetName(), "namespace", obj.GetNamespace())
-	var reqs []reconcile.Request
-	// List all Backend resources
-	backendList := &externalhaproxyoperatorv1alpha1.BackendList{}
-	if err := r.List(ctx, backendList, client.InNamespace("")); err != nil {
-		return nil
+	return requestsForIndexedBackends(ctx, r, backendServiceIndex, objectIndexKey(obj.GetNamespace(), obj.GetName()))
+}
+
+func enqueueRequestsFromNodes(ctx context.Context, obj client.Object, r *BackendReconciler) []reconcile.Request {
+	reqLogger := logf.FromContext(ctx)
+	reqLogger.V(2).Info("Processing Node for Backend reconciliation", "nodeName", obj.GetName())
+	return requestsForIndexedBackends(ctx, r, backendNodeAddressIndex, nodeAddressIndexValue)
+}
+
+// serverServiceRef returns the namespace and name of the Service a server references, if any.
+// The namespace defaults to the Backend's namespace.
+func serverServiceRef(backend *externalhaproxyoperatorv1alpha1.Backend, server *externalhaproxyoperatorv1alpha1.Server) (string, string, bool) {
+	if server.ValueFrom == nil {
+		return "", "", false
 	}
-	for _, backend := range backendList.Items {
-		// Check if the Backend references this Service
-		for _, server := range backend.Spec.Servers {
-			if server.ValueFrom != nil && server.ValueFrom.ServiceRef != nil &&
-				server.ValueFrom.ServiceRef.Name == obj.GetName() &&
-				server.ValueFrom.ServiceRef.Namespace == obj.GetNamespace() {
-				reqs = append(reqs, reconcile.Request{
-					NamespacedName: types.NamespacedName{
-						Name:      backend.Name,
-						Namespace: backend.Namespace,
-					},
-				})
-			}
-		}
+	if ref := server.ValueFrom.ServiceRef; ref != nil {
+		return serviceNamespace(backend, ref.Namespace), ref.Name, true
+	}
+	if ref := server.ValueFrom.NodePortRef; ref != nil {
+		return serviceNamespace(backend, ref.Namespace), ref.Name, true
 	}
-	return reqs
+	return "", "", false
 }
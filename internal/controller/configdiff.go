@@ -0,0 +1,188 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around the changes of a diff.
+	diffContext = 3
+	// maxDiffCells bounds the memory used to diff the changed part of two configurations. Larger
+	// changes are shown as removing all of the old lines and adding all of the new ones.
+	maxDiffCells = 1 << 22
+	// maxDiffSize is the size a diff is truncated to, to keep revisions well below the size limit
+	// of Kubernetes objects.
+	maxDiffSize = 64 << 10
+)
+
+// diffLine is a line of a diff, unchanged (' '), removed ('-') or added ('+').
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// unifiedDiff returns the unified diff between two raw configurations, or an empty string if they
+// are equal. The diff is truncated to maxDiffSize.
+func unifiedDiff(fromName, toName, from, to string) string {
+	lines := diffLines(splitLines(from), splitLines(to))
+
+	// fromLine and toLine are the number of lines of each configuration before each diff line
+	fromLine := make([]int, len(lines)+1)
+	toLine := make([]int, len(lines)+1)
+	for i, line := range lines {
+		fromLine[i+1], toLine[i+1] = fromLine[i], toLine[i]
+		if line.kind != '+' {
+			fromLine[i+1]++
+		}
+		if line.kind != '-' {
+			toLine[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	for start := 0; start < len(lines); {
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		// Changes separated by less than twice the context share a hunk
+		end := first
+		for {
+			for end < len(lines) && lines[end].kind != ' ' {
+				end++
+			}
+			next := end
+			for next < len(lines) && lines[next].kind == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*diffContext {
+				break
+			}
+			end = next
+		}
+		hunkStart, hunkEnd := max(first-diffContext, start), min(end+diffContext, len(lines))
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(fromLine[hunkStart], fromLine[hunkEnd]), hunkRange(toLine[hunkStart], toLine[hunkEnd]))
+		for _, line := range lines[hunkStart:hunkEnd] {
+			out.WriteByte(line.kind)
+			out.WriteString(line.text)
+			out.WriteByte('\n')
+		}
+		start = hunkEnd
+	}
+
+	diff := out.String()
+	if len(diff) > maxDiffSize {
+		diff = diff[:strings.LastIndexByte(diff[:maxDiffSize], '\n')+1] + "... diff truncated\n"
+	}
+	return diff
+}
+
+// hunkRange returns the range of the lines after the start line up to the end line of a hunk, in
+// the format of unified diffs.
+func hunkRange(start, end int) string {
+	if start == end {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
+
+// splitLines splits a configuration into its lines.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the lines of a diff from the lines a to the lines b. The lines common to the
+// start and the end of both are skipped before diffing the rest, which is usually small between
+// configurations.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b)-prefix-suffix)
+	for _, text := range a[:prefix] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	lines = append(lines, diffChanged(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	return lines
+}
+
+// diffChanged returns the lines of a diff from the lines a to the lines b, keeping their longest
+// common subsequence unchanged.
+func diffChanged(a, b []string) []diffLine {
+	lines := make([]diffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	if len(a)*len(b) <= maxDiffCells {
+		// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+		lcs := make([][]int, len(a)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		for i < len(a) && j < len(b) {
+			switch {
+			case a[i] == b[j]:
+				lines = append(lines, diffLine{' ', a[i]})
+				i++
+				j++
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lines = append(lines, diffLine{'-', a[i]})
+				i++
+			default:
+				lines = append(lines, diffLine{'+', b[j]})
+				j++
+			}
+		}
+	}
+	for _, text := range a[i:] {
+		lines = append(lines, diffLine{'-', text})
+	}
+	for _, text := range b[j:] {
+		lines = append(lines, diffLine{'+', text})
+	}
+	return lines
+}
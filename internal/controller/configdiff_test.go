@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "global\n  daemon\n\nbackend web\n  mode http\n  balance roundrobin\n  server web-0 10.0.0.1:80\n  server web-1 10.0.0.2:80\n  server web-2 10.0.0.3:80\n  server web-3 10.0.0.4:80\n\nbackend api\n  mode http\n"
+	to := "global\n  daemon\n\nbackend web\n  mode http\n  balance leastconn\n  server web-0 10.0.0.1:80\n  server web-1 10.0.0.2:80\n  server web-2 10.0.0.3:80\n  server web-3 10.0.0.4:80\n\nbackend api\n  mode http\n  server api-0 10.0.1.1:80\n"
+	want := `--- haproxy-config-1
++++ haproxy-config-2
+@@ -3,7 +3,7 @@
+ 
+ backend web
+   mode http
+-  balance roundrobin
++  balance leastconn
+   server web-0 10.0.0.1:80
+   server web-1 10.0.0.2:80
+   server web-2 10.0.0.3:80
+@@ -11,3 +11,4 @@
+ 
+ backend api
+   mode http
++  server api-0 10.0.1.1:80
+`
+	if got := unifiedDiff("haproxy-config-1", "haproxy-config-2", from, to); got != want {
+		t.Errorf("got diff\n%s\nexpected\n%s", got, want)
+	}
+	if got := unifiedDiff("haproxy-config-1", "haproxy-config-2", from, from); got != "" {
+		t.Errorf("expected no diff between equal configurations, got\n%s", got)
+	}
+	if got := unifiedDiff("a", "b", "", "global\n"); got != "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+global\n" {
+		t.Errorf("unexpected diff from an empty configuration:\n%s", got)
+	}
+}
+
+func TestUnifiedDiff_Truncated(t *testing.T) {
+	var to strings.Builder
+	for to.Len() <= maxDiffSize {
+		to.WriteString("  server web 10.0.0.1:80 check\n")
+	}
+	got := unifiedDiff("a", "b", "", to.String())
+	if len(got) > maxDiffSize+len("... diff truncated\n") || !strings.HasSuffix(got, "\n... diff truncated\n") {
+		t.Errorf("expected the diff to be truncated, got %d bytes ending with %q", len(got), got[len(got)-40:])
+	}
+}
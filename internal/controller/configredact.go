@@ -0,0 +1,100 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces the secrets of the configurations recorded in revisions.
+const redactedValue = "<redacted>"
+
+// configToken matches the tokens of a line of HAProxy configuration.
+var configToken = regexp.MustCompile(`\S+`)
+
+// redactConfig returns the raw configuration with its secrets replaced by redactedValue: the
+// passwords of the users of userlists, and the password of the stats page.
+func redactConfig(raw string) string {
+	lines := strings.SplitAfter(raw, "\n")
+	for i, line := range lines {
+		lines[i] = redactLine(line)
+	}
+	return strings.Join(lines, "")
+}
+
+// redactLine returns a line of configuration with its secrets replaced by redactedValue.
+func redactLine(line string) string {
+	tokens := configToken.FindAllStringIndex(line, -1)
+	// Tokens are replaced from the last, so that the positions of the previous ones are kept
+	for i := len(tokens) - 2; i >= 0; i-- {
+		keyword := line[tokens[i][0]:tokens[i][1]]
+		start, end := tokens[i+1][0], tokens[i+1][1]
+		switch {
+		case keyword == "password" || keyword == "insecure-password":
+		case keyword == "auth" && i > 0 && line[tokens[i-1][0]:tokens[i-1][1]] == "stats":
+			// stats auth <user>:<password>
+			colon := strings.IndexByte(line[start:end], ':')
+			if colon < 0 {
+				continue
+			}
+			start += colon + 1
+		default:
+			continue
+		}
+		line = line[:start] + redactedValue + line[end:]
+	}
+	return line
+}
+
+// unredactConfig returns a redacted configuration with its secrets taken from the current
+// configuration, where the same line in the same section holds them. It fails if a secret is no
+// longer in the current configuration, such as when the user was deleted since.
+func unredactConfig(redacted, current string) (string, error) {
+	secrets := map[string]string{}
+	section := ""
+	for _, line := range strings.SplitAfter(current, "\n") {
+		section = configSection(section, line)
+		if masked := redactLine(line); masked != line {
+			secrets[section+"\n"+strings.TrimSpace(masked)] = line
+		}
+	}
+
+	lines := strings.SplitAfter(redacted, "\n")
+	section = ""
+	for i, line := range lines {
+		section = configSection(section, line)
+		if !strings.Contains(line, redactedValue) {
+			continue
+		}
+		original, ok := secrets[section+"\n"+strings.TrimSpace(line)]
+		if !ok {
+			return "", fmt.Errorf("the secret of %q in %q is no longer in the configuration", strings.TrimSpace(line), section)
+		}
+		lines[i] = original
+	}
+	return strings.Join(lines, ""), nil
+}
+
+// configSection returns the header of the section of a line, given the header of the previous line.
+func configSection(section, line string) string {
+	if strings.TrimSpace(line) != "" && line[0] != ' ' && line[0] != '\t' && line[0] != '#' {
+		return strings.TrimSpace(line)
+	}
+	return section
+}
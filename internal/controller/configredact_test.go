@@ -0,0 +1,34 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactConfig(t *testing.T) {
+	raw := "userlist admins\n  user alice password $6$salt$hash groups ops\n  user bob insecure-password s3cret\n" +
+		"frontend stats\n  stats auth admin:hunter2\n  stats realm password\n"
+	want := "userlist admins\n  user alice password <redacted> groups ops\n  user bob insecure-password <redacted>\n" +
+		"frontend stats\n  stats auth admin:<redacted>\n  stats realm password\n"
+	if got := redactConfig(raw); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestUnredactConfig(t *testing.T) {
+	redacted := "userlist admins\n  user alice password <redacted>\nbackend web\n  mode http\n"
+	current := "userlist admins\n  user alice password $6$current\n  user bob password $6$bob\nbackend web\n  mode tcp\n"
+
+	raw, err := unredactConfig(redacted, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "userlist admins\n  user alice password $6$current\nbackend web\n  mode http\n"; raw != want {
+		t.Errorf("expected the secret of the current configuration, got %q", raw)
+	}
+
+	// The user was deleted since
+	if _, err := unredactConfig(redacted, "backend web\n"); err == nil || !strings.Contains(err.Error(), "user alice password") {
+		t.Errorf("expected the missing secret to fail the restore, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+// ConfigRevisions records the HAProxy configuration after each change committed by the operator as
+// a HAProxyConfigRevision, keeping the latest ones.
+type ConfigRevisions struct {
+	client.Client
+	HAProxyClient haproxyclient.HAProxyClient
+
+	// Retention is the number of revisions kept.
+	Retention int
+}
+
+// configRevisionName returns the name of the revision of a version of the HAProxy configuration.
+func configRevisionName(version int64) string {
+	return fmt.Sprintf("haproxy-config-%d", version)
+}
+
+// Record records the current HAProxy configuration after the changes to source were committed,
+// with the ID of the reload applying them, if any. Recording is disabled on a nil ConfigRevisions.
+// A failure is only logged, as HAProxy runs the configuration anyway. Callers must hold
+// haproxyTransactionMu, so that the configuration is the one they committed.
+func (c *ConfigRevisions) Record(ctx context.Context, source, reloadID string) {
+	if c == nil {
+		return
+	}
+	if err := c.record(ctx, source, reloadID); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to record the HAProxy configuration revision", "source", source)
+	}
+}
+
+// record creates the revision of the current HAProxy configuration with its diff from the previous
+// revision, and deletes the revisions beyond the retention. The secrets of the configuration are
+// redacted.
+func (c *ConfigRevisions) record(ctx context.Context, source, reloadID string) error {
+	snapshot, err := c.HAProxyClient.GetRawConfiguration(ctx)
+	if err != nil {
+		return fmt.Errorf("getting the raw configuration: %w", err)
+	}
+	revisions := &externalhaproxyoperatorv1alpha1.HAProxyConfigRevisionList{}
+	if err := c.List(ctx, revisions); err != nil {
+		return fmt.Errorf("listing revisions: %w", err)
+	}
+	items := revisions.Items
+	sort.Slice(items, func(i, j int) bool { return items[i].Spec.Version < items[j].Spec.Version })
+
+	raw := redactConfig(snapshot.Raw)
+	config, err := externalhaproxyoperatorv1alpha1.CompressConfig(raw)
+	if err != nil {
+		return fmt.Errorf("compressing the configuration: %w", err)
+	}
+	checksum := sha256.Sum256([]byte(raw))
+	revision := &externalhaproxyoperatorv1alpha1.HAProxyConfigRevision{
+		ObjectMeta: metav1.ObjectMeta{Name: configRevisionName(snapshot.Version)},
+		Spec: externalhaproxyoperatorv1alpha1.HAProxyConfigRevisionSpec{
+			Version:  snapshot.Version,
+			Source:   source,
+			ReloadID: reloadID,
+			Checksum: hex.EncodeToString(checksum[:]),
+			Size:     int64(len(raw)),
+			Config:   config,
+		},
+	}
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].Spec.Version == snapshot.Version {
+			// Recorded already, as the cache may lag behind the revisions created
+			return nil
+		}
+		if items[i].Spec.Version < snapshot.Version {
+			previous, err := items[i].RawConfig()
+			if err != nil {
+				return fmt.Errorf("reading revision %s: %w", items[i].Name, err)
+			}
+			revision.Spec.Diff = unifiedDiff(items[i].Name, revision.Name, previous, raw)
+			break
+		}
+	}
+	if err := c.Create(ctx, revision); err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating revision %s: %w", revision.Name, err)
+	}
+	logf.FromContext(ctx).V(1).Info("Recorded the HAProxy configuration revision", "revision", revision.Name, "source", source)
+
+	items = append(items, *revision)
+	sort.Slice(items, func(i, j int) bool { return items[i].Spec.Version < items[j].Spec.Version })
+	for i := 0; i < len(items)-c.Retention; i++ {
+		if err := c.Delete(ctx, &items[i]); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("deleting revision %s: %w", items[i].Name, err)
+		}
+	}
+	return nil
+}
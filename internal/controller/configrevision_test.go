@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+// fakeRawConfiguration is a Data Plane API serving a raw configuration, which a restore replaces.
+type fakeRawConfiguration struct {
+	mu      sync.Mutex
+	version int64
+	raw     string
+}
+
+func (d *fakeRawConfiguration) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/v3/services/haproxy/configuration/version":
+		fmt.Fprint(w, d.version)
+	case r.URL.Path == "/v3/services/haproxy/configuration/raw" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Configuration-Version", fmt.Sprint(d.version))
+		fmt.Fprintf(w, "# _version=%d\n%s", d.version, d.raw)
+	case r.URL.Path == "/v3/services/haproxy/configuration/raw" && r.Method == http.MethodPost:
+		body, _ := io.ReadAll(r.Body)
+		d.version++
+		d.raw = string(body)
+		w.Header().Set("Reload-ID", "1-9")
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestConfigRevisions(t *testing.T, dataPlane *fakeRawConfiguration, retention int) *ConfigRevisions {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := externalhaproxyoperatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(dataPlane)
+	t.Cleanup(ts.Close)
+	return &ConfigRevisions{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithStatusSubresource(&externalhaproxyoperatorv1alpha1.HAProxyConfigRevision{}).
+			Build(),
+		HAProxyClient: haproxyclient.NewHAProxyClient(haproxyclient.HAProxyConfig{BaseURL: ts.URL, Timeout: 5 * time.Second}),
+		Retention:     retention,
+	}
+}
+
+// getTestConfigRevision returns the revision of a configuration version, or nil if there is none.
+func getTestConfigRevision(t *testing.T, c client.Client, version int64) *externalhaproxyoperatorv1alpha1.HAProxyConfigRevision {
+	t.Helper()
+	revision := &externalhaproxyoperatorv1alpha1.HAProxyConfigRevision{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: configRevisionName(version)}, revision); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			t.Fatal(err)
+		}
+		return nil
+	}
+	return revision
+}
+
+func TestConfigRevisionsRecord(t *testing.T) {
+	dataPlane := &fakeRawConfiguration{version: 1, raw: "backend web\n  mode http\n"}
+	revisions := newTestConfigRevisions(t, dataPlane, 2)
+	ctx := context.Background()
+
+	revisions.Record(ctx, "Backend default/web", "")
+	first := getTestConfigRevision(t, revisions, 1)
+	if first == nil || first.Spec.Source != "Backend default/web" || first.Spec.Diff != "" {
+		t.Fatalf("expected the first revision without a diff, got %+v", first)
+	}
+	if raw, err := first.RawConfig(); err != nil || raw != "# _version=1\nbackend web\n  mode http\n" || first.Spec.Size != int64(len(raw)) {
+		t.Errorf("unexpected configuration %q of size %d: %v", raw, first.Spec.Size, err)
+	}
+
+	dataPlane.version, dataPlane.raw = 2, "backend web\n  mode tcp\n"
+	revisions.Record(ctx, "Backend default/web", "1-2")
+	second := getTestConfigRevision(t, revisions, 2)
+	if second == nil || second.Spec.ReloadID != "1-2" || second.Spec.Checksum == first.Spec.Checksum {
+		t.Fatalf("unexpected second revision %+v", second)
+	}
+	if !strings.Contains(second.Spec.Diff, "--- haproxy-config-1\n+++ haproxy-config-2\n") ||
+		!strings.Contains(second.Spec.Diff, "\n-  mode http\n+  mode tcp\n") {
+		t.Errorf("unexpected diff\n%s", second.Spec.Diff)
+	}
+
+	// Only the latest revisions are kept
+	dataPlane.version = 3
+	revisions.Record(ctx, "Resolver default/dns", "")
+	if getTestConfigRevision(t, revisions, 1) != nil || getTestConfigRevision(t, revisions, 2) == nil || getTestConfigRevision(t, revisions, 3) == nil {
+		t.Error("expected the oldest revision to be deleted")
+	}
+}
+
+func TestHAProxyConfigRevisionReconcile_Restores(t *testing.T) {
+	dataPlane := &fakeRawConfiguration{version: 1, raw: "backend web\n  mode http\n"}
+	revisions := newTestConfigRevisions(t, dataPlane, 5)
+	ctx := context.Background()
+	revisions.Record(ctx, "Backend default/web", "")
+	dataPlane.version, dataPlane.raw = 2, "backend web\n  mode tcp\n"
+	revisions.Record(ctx, "Backend default/web", "")
+
+	r := &HAProxyConfigRevisionReconciler{
+		Client:        revisions.Client,
+		Recorder:      record.NewFakeRecorder(10),
+		HAProxyClient: revisions.HAProxyClient,
+		Revisions:     revisions,
+	}
+	request := ctrl.Request{NamespacedName: client.ObjectKey{Name: configRevisionName(1)}}
+
+	// Revisions are only restored on request
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dataPlane.version != 2 {
+		t.Fatal("expected the configuration not to be restored without the annotation")
+	}
+
+	revision := getTestConfigRevision(t, r.Client, 1)
+	revision.Annotations = map[string]string{externalhaproxyoperatorv1alpha1.RestoreAnnotation: "true"}
+	if err := r.Update(ctx, revision); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, request); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dataPlane.version != 3 || dataPlane.raw != "backend web\n  mode http\n" {
+		t.Errorf("expected the configuration of version 1 to be restored, got version %d with %q", dataPlane.version, dataPlane.raw)
+	}
+
+	revision = getTestConfigRevision(t, r.Client, 1)
+	if _, ok := revision.Annotations[externalhaproxyoperatorv1alpha1.RestoreAnnotation]; ok {
+		t.Error("expected the restore annotation to be removed")
+	}
+	condition := meta.FindStatusCondition(revision.Status.Conditions, "Restored")
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Message != "Restored the configuration of version 1 in reload 1-9" {
+		t.Errorf("unexpected Restored condition %+v", condition)
+	}
+	restored := getTestConfigRevision(t, r.Client, 3)
+	if restored == nil || restored.Spec.Source != "HAProxyConfigRevision haproxy-config-1" || restored.Spec.ReloadID != "1-9" {
+		t.Errorf("expected the restored configuration to be recorded, got %+v", restored)
+	}
+}
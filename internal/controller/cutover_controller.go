@@ -0,0 +1,373 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/haproxytech/client-native/v6/models"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+// cutoverPollInterval is how often a Cutover that waits for servers checks the target backend again.
+const cutoverPollInterval = 10 * time.Second
+
+// CutoverReconciler reconciles a Cutover object
+type CutoverReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	Recorder      record.EventRecorder
+	HAProxyClient haproxyclient.HAProxyClient
+
+	// Revisions records the HAProxy configuration after each switch.
+	Revisions *ConfigRevisions
+}
+
+func (r *CutoverReconciler) setCondition(
+	cutover *externalhaproxyoperatorv1alpha1.Cutover,
+	condition metav1.Condition,
+) {
+	meta.SetStatusCondition(&cutover.Status.Conditions, condition)
+	if condition.Type == "ReconcilingComplete" && condition.Status == metav1.ConditionFalse &&
+		// Waiting for the servers of the target backend is not a failure
+		condition.Reason != "WaitingForServers" {
+		monitoring.ReconcileFailuresTotal.WithLabelValues("cutover", condition.Reason).Inc()
+	}
+}
+
+// +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=cutovers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=cutovers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=backends,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+// Reconcile switches the frontend of a Cutover to its target backend once HAProxy reports enough of
+// the backend's servers UP, or back to the previous backend when a rollback is requested.
+func (r *CutoverReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	reqLogger := logf.FromContext(ctx)
+
+	// Fetch the Cutover instance
+	cutover := &externalhaproxyoperatorv1alpha1.Cutover{}
+	err := r.Get(ctx, req.NamespacedName, cutover)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			reqLogger.Info("Cutover resource not found. Ignoring since object must be deleted.")
+			return ctrl.Result{}, nil
+		}
+		reqLogger.Error(err, "Failed to get Cutover.")
+		return ctrl.Result{}, err
+	}
+	reqLogger.V(1).Info("Reconciling Cutover")
+
+	// Find the HAProxy backend to switch to
+	desired := cutover.Status.PreviousBackend
+	if !cutover.Spec.Rollback {
+		backend := &externalhaproxyoperatorv1alpha1.Backend{}
+		err := r.Get(ctx, client.ObjectKey{Namespace: cutover.Namespace, Name: cutover.Spec.BackendRef.Name}, backend)
+		if err != nil {
+			return ctrl.Result{}, r.failCutover(ctx, reqLogger, cutover, "BackendNotFound",
+				"Failed to get Backend "+cutover.Spec.BackendRef.Name, err)
+		}
+		desired = backend.Spec.Name
+	} else if desired == "" && !cutover.Status.PreviousBackendRecorded {
+		r.setCondition(cutover, metav1.Condition{
+			Type:    "ReconcilingComplete",
+			Status:  metav1.ConditionFalse,
+			Reason:  "NoPreviousBackend",
+			Message: "Cannot roll back since the frontend has not been cut over from another backend",
+		})
+		_ = r.Status().Update(ctx, cutover)
+		return ctrl.Result{}, nil
+	}
+
+	haproxyTransactionMu.Lock()
+	defer haproxyTransactionMu.Unlock()
+
+	frontend, err := r.HAProxyClient.GetFrontend(ctx, cutover.Spec.Frontend)
+	if err == nil && frontend == nil {
+		err = haproxyclient.ErrResourceNotFound{ResourceType: "frontend", ResourceName: cutover.Spec.Frontend}
+	}
+	if err != nil {
+		return ctrl.Result{}, r.failCutover(ctx, reqLogger, cutover, "FrontendNotFound",
+			"Failed to get frontend "+cutover.Spec.Frontend, err)
+	}
+	if frontend.Description != haproxyclient.ManagedDescription {
+		err := haproxyclient.ErrNotManaged{ResourceType: "frontend", ResourceName: cutover.Spec.Frontend}
+		return ctrl.Result{}, r.failCutover(ctx, reqLogger, cutover, "FrontendNotManaged",
+			"Refusing to switch frontend "+cutover.Spec.Frontend, err)
+	}
+
+	current := frontend.DefaultBackend
+	if cutover.Spec.Rule != nil {
+		if current, err = r.currentRuleBackend(ctx, cutover); err != nil {
+			return ctrl.Result{}, r.failCutover(ctx, reqLogger, cutover, "HAProxyClientError",
+				"Failed to list backend switching rules", err)
+		}
+	}
+
+	if current != desired {
+		// Only switch to the new backend once it can take the traffic. A rollback does not
+		// wait, since the current backend is what is being moved away from.
+		if !cutover.Spec.Rollback {
+			up, err := r.upServers(ctx, desired)
+			if err != nil {
+				return ctrl.Result{}, r.failCutover(ctx, reqLogger, cutover, "HAProxyClientError",
+					"Failed to get runtime servers of backend "+desired, err)
+			}
+			if up < cutover.Spec.MinUpServers {
+				reqLogger.V(1).Info("Waiting for servers before cutover", "backend", desired, "up", up)
+				r.setCondition(cutover, metav1.Condition{
+					Type:    "ReconcilingComplete",
+					Status:  metav1.ConditionFalse,
+					Reason:  "WaitingForServers",
+					Message: fmt.Sprintf("%d of %d required servers are UP in backend %s", up, cutover.Spec.MinUpServers, desired),
+				})
+				_ = r.Status().Update(ctx, cutover)
+				return ctrl.Result{RequeueAfter: cutoverPollInterval}, nil
+			}
+
+			// Record the backend to roll back to before switching, so that it is not lost when
+			// updating the status fails after the switch, as the next reconcile finds the frontend
+			// switched already
+			cutover.Status.PreviousBackend = current
+			cutover.Status.PreviousBackendRecorded = true
+			if err := r.Status().Update(ctx, cutover); err != nil {
+				reqLogger.Error(err, "Failed to record the previous backend before the cutover")
+				return ctrl.Result{}, err
+			}
+		}
+
+		if err := r.switchBackend(ctx, cutover, frontend, desired); isInvalidConfiguration(err) {
+			return r.rejectInvalid(ctx, reqLogger, cutover, err)
+		} else if err != nil {
+			return ctrl.Result{}, r.failCutover(ctx, reqLogger, cutover, "HAProxyClientError",
+				"Failed to switch frontend "+frontend.Name+" to "+switchTarget(cutover, desired), err)
+		}
+		reqLogger.Info("Switched frontend", "frontend", frontend.Name, "from", current, "to", desired)
+		if cutover.Spec.Rollback {
+			r.Recorder.Event(cutover, "Normal", "RolledBack", "Rolled back frontend "+frontend.Name+" to "+switchTarget(cutover, desired))
+		} else {
+			r.Recorder.Event(cutover, "Normal", "CutOver", "Switched frontend "+frontend.Name+" to backend "+desired)
+		}
+	}
+	cutover.Status.ActiveBackend = desired
+
+	// Set Reconciling Condition
+	reason, message := "CutoverCompleted", "Frontend is switched to backend "+desired
+	if cutover.Spec.Rollback {
+		reason, message = "RolledBack", "Frontend is rolled back to "+switchTarget(cutover, desired)
+	}
+	r.setCondition(cutover, metav1.Condition{
+		Type:    "ReconcilingComplete",
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: message,
+	})
+	if err := r.Status().Update(ctx, cutover); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// switchTarget describes what the frontend of the Cutover is switched to. An empty backend
+// removes the default backend or the rule of the Cutover, as the frontend had none before it.
+func switchTarget(cutover *externalhaproxyoperatorv1alpha1.Cutover, backend string) string {
+	switch {
+	case backend != "":
+		return "backend " + backend
+	case cutover.Spec.Rule != nil:
+		return "no use_backend rule"
+	default:
+		return "no default backend"
+	}
+}
+
+// currentRuleBackend returns the backend of the use_backend rule of the Cutover, or an empty string
+// if the frontend has no rule with its condition yet.
+func (r *CutoverReconciler) currentRuleBackend(ctx context.Context, cutover *externalhaproxyoperatorv1alpha1.Cutover) (string, error) {
+	rules, err := r.HAProxyClient.ListBackendSwitchingRules(ctx, cutover.Spec.Frontend)
+	if err != nil {
+		return "", err
+	}
+	if i := ruleIndex(rules, cutover); i >= 0 {
+		return rules[i].Name, nil
+	}
+	return "", nil
+}
+
+// ruleIndex returns the index of the use_backend rule with the condition of the Cutover, or -1 if
+// there is none.
+func ruleIndex(rules []*models.BackendSwitchingRule, cutover *externalhaproxyoperatorv1alpha1.Cutover) int {
+	want := externalhaproxyoperatorv1alpha1.SwitchingRuleToModel(*cutover.Spec.Rule, "")
+	for i, rule := range rules {
+		if rule.Cond == want.Cond && rule.CondTest == want.CondTest {
+			return i
+		}
+	}
+	return -1
+}
+
+// upServers returns the number of servers of the backend that HAProxy reports UP.
+func (r *CutoverReconciler) upServers(ctx context.Context, backend string) (int64, error) {
+	servers, err := r.HAProxyClient.ListRuntimeServers(ctx, backend)
+	if err != nil {
+		return 0, err
+	}
+	up := int64(0)
+	for _, server := range servers {
+		if haproxyclient.RuntimeServerUp(server) {
+			up++
+		}
+	}
+	return up, nil
+}
+
+// switchBackend points the default_backend or the use_backend rule of the frontend to the backend in
+// a single transaction, so that HAProxy switches all traffic at once. An empty backend removes the
+// rule, when rolling back to a frontend that had none.
+func (r *CutoverReconciler) switchBackend(ctx context.Context, cutover *externalhaproxyoperatorv1alpha1.Cutover, frontend *models.Frontend, backend string) error {
+	committed, err := r.HAProxyClient.RunTransaction(ctx, func(ctx context.Context) error {
+		if cutover.Spec.Rule != nil && backend == "" {
+			rules, err := r.HAProxyClient.ListBackendSwitchingRules(ctx, frontend.Name)
+			if err != nil {
+				return err
+			}
+			if i := ruleIndex(rules, cutover); i >= 0 {
+				return r.HAProxyClient.DeleteBackendSwitchingRuleByIndex(ctx, frontend.Name, int64(i))
+			}
+			return nil
+		}
+		if cutover.Spec.Rule != nil {
+			return r.HAProxyClient.EnsureBackendSwitchingRule(ctx, frontend.Name,
+				externalhaproxyoperatorv1alpha1.SwitchingRuleToModel(*cutover.Spec.Rule, backend))
+		}
+		switched := *frontend
+		switched.DefaultBackend = backend
+		return r.HAProxyClient.EnsureFrontend(ctx, &switched)
+	})
+	if err == nil && committed.ID != "" {
+		r.Revisions.Record(ctx, "Cutover "+cutover.Namespace+"/"+cutover.Name, committed.ReloadID)
+	}
+	return err
+}
+
+// failCutover records a failure to switch the frontend of a Cutover as an event and a
+// ReconcilingComplete condition, and returns the error.
+func (r *CutoverReconciler) failCutover(
+	ctx context.Context,
+	reqLogger logr.Logger,
+	cutover *externalhaproxyoperatorv1alpha1.Cutover,
+	reason, message string,
+	err error,
+) error {
+	r.Recorder.Event(cutover, "Warning", reason, message+": "+err.Error())
+	reqLogger.Error(err, message, "frontend", cutover.Spec.Frontend)
+	r.setCondition(cutover, metav1.Condition{
+		Type:    "ReconcilingComplete",
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message + ": " + err.Error(),
+	})
+	_ = r.Status().Update(ctx, cutover)
+	return err
+}
+
+// rejectInvalid reports that HAProxy rejected the switch of the frontend of a Cutover before it was
+// committed. The Cutover is only retried when the rejected line is outside of the frontend.
+func (r *CutoverReconciler) rejectInvalid(ctx context.Context, reqLogger logr.Logger, cutover *externalhaproxyoperatorv1alpha1.Cutover, err error) (ctrl.Result, error) {
+	message, own := invalidConfiguration(err, "frontend "+cutover.Spec.Frontend, func(invalid haproxyclient.ErrInvalidConfiguration) string {
+		return cutoverSpecField(cutover.Spec, invalid)
+	})
+	reqLogger.Info("HAProxy rejected the switch of the frontend", "frontend", cutover.Spec.Frontend, "reason", message)
+	r.Recorder.Event(cutover, "Warning", "ValidationFailed", message)
+	r.setCondition(cutover, metav1.Condition{
+		Type:    "ReconcilingComplete",
+		Status:  metav1.ConditionFalse,
+		Reason:  "ValidationFailed",
+		Message: message,
+	})
+	_ = r.Status().Update(ctx, cutover)
+	if !own {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CutoverReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&externalhaproxyoperatorv1alpha1.Cutover{}).
+		Named("cutover").
+		Watches(&externalhaproxyoperatorv1alpha1.Backend{}, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+			return enqueueRequestsFromCutoverBackends(ctx, obj, r)
+		})).
+		WithEventFilter(predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				oldObj, ok1 := e.ObjectOld.(*externalhaproxyoperatorv1alpha1.Cutover)
+				newObj, ok2 := e.ObjectNew.(*externalhaproxyoperatorv1alpha1.Cutover)
+				if !ok1 || !ok2 {
+					return true
+				}
+				// Ignore status-only updates
+				oldCopy := oldObj.DeepCopy()
+				newCopy := newObj.DeepCopy()
+				newCopy.ObjectMeta.ResourceVersion = oldCopy.ObjectMeta.ResourceVersion
+				oldCopy.Status = externalhaproxyoperatorv1alpha1.CutoverStatus{}
+				newCopy.Status = externalhaproxyoperatorv1alpha1.CutoverStatus{}
+				return !reflect.DeepEqual(oldCopy, newCopy)
+			},
+		}).
+		Complete(r)
+}
+
+// enqueueRequestsFromCutoverBackends maps a Backend to the Cutovers that switch to it.
+func enqueueRequestsFromCutoverBackends(ctx context.Context, obj client.Object, r *CutoverReconciler) []reconcile.Request {
+	cutovers := &externalhaproxyoperatorv1alpha1.CutoverList{}
+	if err := r.List(ctx, cutovers, client.InNamespace(obj.GetNamespace())); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list Cutovers", "namespace", obj.GetNamespace())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, cutover := range cutovers.Items {
+		if cutover.Spec.BackendRef.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: cutover.Namespace, Name: cutover.Name},
+			})
+		}
+	}
+	return requests
+}
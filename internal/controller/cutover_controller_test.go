@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/haproxytech/client-native/v6/models"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+// fakeDataPlane is a minimal Data Plane API serving a managed frontend with its use_backend rules
+// and the runtime servers of the app-green backend.
+type fakeDataPlane struct {
+	mu             sync.Mutex
+	defaultBackend string
+	rules          []*models.BackendSwitchingRule
+	greenUp        int
+	commits        int
+}
+
+func (d *fakeDataPlane) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v3/services/haproxy")
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case path == "/configuration/frontends/www" && r.Method == http.MethodGet:
+		fmt.Fprintf(w, `{"name":"www","mode":"http","default_backend":%q,"description":%q}`, d.defaultBackend, haproxyclient.ManagedDescription)
+	case path == "/configuration/frontends/www" && r.Method == http.MethodPut:
+		var body struct {
+			DefaultBackend string `json:"default_backend"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		d.defaultBackend = body.DefaultBackend
+		fmt.Fprint(w, `{}`)
+	case path == "/configuration/frontends/www/backend_switching_rules" && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(d.rules)
+	case path == "/configuration/frontends/www/backend_switching_rules" && r.Method == http.MethodPut:
+		d.rules = nil
+		_ = json.NewDecoder(r.Body).Decode(&d.rules)
+		fmt.Fprint(w, `[]`)
+	case strings.HasPrefix(path, "/configuration/frontends/www/backend_switching_rules/") && r.Method == http.MethodDelete:
+		index, _ := strconv.Atoi(strings.TrimPrefix(path, "/configuration/frontends/www/backend_switching_rules/"))
+		d.rules = append(d.rules[:index], d.rules[index+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/runtime/backends/app-green/servers":
+		servers := make([]string, 0, d.greenUp)
+		for i := 0; i < d.greenUp; i++ {
+			servers = append(servers, fmt.Sprintf(`{"name":"web-%d","operational_state":"up","admin_state":"ready"}`, i))
+		}
+		fmt.Fprint(w, "["+strings.Join(servers, ",")+"]")
+	case path == "/configuration/version":
+		fmt.Fprint(w, "1")
+	case path == "/transactions" && r.Method == http.MethodPost:
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"tx1","status":"in_progress"}`)
+	case path == "/transactions/tx1" && r.Method == http.MethodPut:
+		d.commits++
+		fmt.Fprint(w, `{"id":"tx1","status":"success"}`)
+	case path == "/transactions/tx1" && r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestCutoverReconciler(t *testing.T, dataPlane *fakeDataPlane, objs ...client.Object) *CutoverReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := externalhaproxyoperatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(dataPlane)
+	t.Cleanup(ts.Close)
+	return &CutoverReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&externalhaproxyoperatorv1alpha1.Cutover{}).
+			Build(),
+		Scheme:        scheme,
+		Recorder:      record.NewFakeRecorder(10),
+		HAProxyClient: haproxyclient.NewHAProxyClient(haproxyclient.HAProxyConfig{BaseURL: ts.URL, Timeout: 5 * time.Second}),
+	}
+}
+
+func reconcileTestCutover(t *testing.T, r *CutoverReconciler) (ctrl.Result, *externalhaproxyoperatorv1alpha1.Cutover) {
+	t.Helper()
+	key := client.ObjectKey{Namespace: "default", Name: "www"}
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cutover := &externalhaproxyoperatorv1alpha1.Cutover{}
+	if err := r.Get(context.Background(), key, cutover); err != nil {
+		t.Fatal(err)
+	}
+	return result, cutover
+}
+
+func TestCutoverReconcile_GatesAndRollsBack(t *testing.T) {
+	dataPlane := &fakeDataPlane{defaultBackend: "app-blue", greenUp: 1}
+	backend := &externalhaproxyoperatorv1alpha1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "green"},
+		Spec:       externalhaproxyoperatorv1alpha1.BackendSpec{Name: "app-green"},
+	}
+	cutover := &externalhaproxyoperatorv1alpha1.Cutover{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "www"},
+		Spec: externalhaproxyoperatorv1alpha1.CutoverSpec{
+			Frontend:     "www",
+			BackendRef:   externalhaproxyoperatorv1alpha1.BackendReference{Name: "green"},
+			MinUpServers: 2,
+		},
+	}
+	r := newTestCutoverReconciler(t, dataPlane, backend, cutover)
+
+	// Not enough servers are UP yet
+	result, got := reconcileTestCutover(t, r)
+	if result.RequeueAfter == 0 || dataPlane.defaultBackend != "app-blue" {
+		t.Fatalf("expected the cutover to wait, got result %+v and backend %s", result, dataPlane.defaultBackend)
+	}
+	if c := meta.FindStatusCondition(got.Status.Conditions, "ReconcilingComplete"); c == nil || c.Reason != "WaitingForServers" {
+		t.Errorf("expected WaitingForServers condition, got %+v", c)
+	}
+
+	dataPlane.greenUp = 2
+	_, got = reconcileTestCutover(t, r)
+	if dataPlane.defaultBackend != "app-green" || dataPlane.commits != 1 {
+		t.Fatalf("expected one commit switching to app-green, got backend %s and %d commits", dataPlane.defaultBackend, dataPlane.commits)
+	}
+	if got.Status.ActiveBackend != "app-green" || got.Status.PreviousBackend != "app-blue" {
+		t.Errorf("unexpected status: %+v", got.Status)
+	}
+
+	// A rollback does not wait for the servers of the previous backend
+	got.Spec.Rollback = true
+	if err := r.Update(context.Background(), got); err != nil {
+		t.Fatal(err)
+	}
+	_, got = reconcileTestCutover(t, r)
+	if dataPlane.defaultBackend != "app-blue" || got.Status.ActiveBackend != "app-blue" || got.Status.PreviousBackend != "app-blue" {
+		t.Errorf("expected a rollback to app-blue, got backend %s and status %+v", dataPlane.defaultBackend, got.Status)
+	}
+	if c := meta.FindStatusCondition(got.Status.Conditions, "ReconcilingComplete"); c == nil || c.Reason != "RolledBack" {
+		t.Errorf("expected RolledBack condition, got %+v", c)
+	}
+}
+
+func TestCutoverReconcile_RollsBackRule(t *testing.T) {
+	dataPlane := &fakeDataPlane{defaultBackend: "app-blue", greenUp: 1}
+	backend := &externalhaproxyoperatorv1alpha1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "green"},
+		Spec:       externalhaproxyoperatorv1alpha1.BackendSpec{Name: "app-green"},
+	}
+	cutover := &externalhaproxyoperatorv1alpha1.Cutover{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "www"},
+		Spec: externalhaproxyoperatorv1alpha1.CutoverSpec{
+			Frontend:     "www",
+			BackendRef:   externalhaproxyoperatorv1alpha1.BackendReference{Name: "green"},
+			Rule:         &externalhaproxyoperatorv1alpha1.SwitchingRuleCondition{CondTest: "{ hdr(x-canary) -m found }"},
+			MinUpServers: 1,
+		},
+	}
+	r := newTestCutoverReconciler(t, dataPlane, backend, cutover)
+
+	_, got := reconcileTestCutover(t, r)
+	if len(dataPlane.rules) != 1 || dataPlane.rules[0].Name != "app-green" {
+		t.Fatalf("expected a rule switching to app-green, got %+v", dataPlane.rules)
+	}
+	if !got.Status.PreviousBackendRecorded || got.Status.PreviousBackend != "" {
+		t.Errorf("expected the frontend to be recorded without a previous rule, got %+v", got.Status)
+	}
+
+	// Rolling back removes the rule the frontend did not have before
+	got.Spec.Rollback = true
+	if err := r.Update(context.Background(), got); err != nil {
+		t.Fatal(err)
+	}
+	_, got = reconcileTestCutover(t, r)
+	if len(dataPlane.rules) != 0 || dataPlane.defaultBackend != "app-blue" {
+		t.Errorf("expected the rule to be removed, got %+v", dataPlane.rules)
+	}
+	if c := meta.FindStatusCondition(got.Status.Conditions, "ReconcilingComplete"); c == nil || c.Reason != "RolledBack" {
+		t.Errorf("expected RolledBack condition, got %+v", c)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"hash/fnv"
+
+	"github.com/haproxytech/client-native/v6/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+// backendFingerprint returns a hash of the desired configuration of a backend. Maps are encoded
+// with sorted keys, so equal configurations have equal fingerprints.
+func backendFingerprint(backend *models.Backend) uint64 {
+	data, err := json.Marshal(backend)
+	if err != nil {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return h.Sum64()
+}
+
+// detectDrift records the configuration applied to HAProxy for a Backend, and reports whether
+// HAProxy had to be changed although the configuration is the one applied last time, meaning
+// that the backend was changed outside of the operator. The first configuration applied after
+// the operator starts is never reported as drift. Callers must hold haproxyTransactionMu.
+func (r *BackendReconciler) detectDrift(
+	backend *externalhaproxyoperatorv1alpha1.Backend,
+	fingerprint uint64,
+	changed bool,
+) bool {
+	if r.appliedFingerprints == nil {
+		r.appliedFingerprints = make(map[types.NamespacedName]uint64)
+	}
+	key := types.NamespacedName{Namespace: backend.Namespace, Name: backend.Name}
+	previous, applied := r.appliedFingerprints[key]
+	r.appliedFingerprints[key] = fingerprint
+	if !changed || !applied || previous != fingerprint {
+		return false
+	}
+	monitoring.BackendDriftCorrectionsTotal.With(prometheus.Labels{
+		"namespace": backend.Namespace, "name": backend.Name, "backend": backend.Spec.Name,
+	}).Inc()
+	return true
+}
+
+// forgetBackend removes the state kept for a deleted Backend. Callers must hold
+// haproxyTransactionMu.
+func (r *BackendReconciler) forgetBackend(backend *externalhaproxyoperatorv1alpha1.Backend) {
+	key := types.NamespacedName{Namespace: backend.Namespace, Name: backend.Name}
+	delete(r.appliedFingerprints, key)
+	delete(r.rollbacks, key)
+	monitoring.DeleteBackendReconcileMetrics(backend.Namespace, backend.Name)
+}
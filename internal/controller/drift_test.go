@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+func TestDetectDrift(t *testing.T) {
+	r := &BackendReconciler{}
+	backend := &externalhaproxyoperatorv1alpha1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-drift"},
+		Spec:       externalhaproxyoperatorv1alpha1.BackendSpec{Name: "web"},
+	}
+	desired := backendFingerprint(externalhaproxyoperatorv1alpha1.BackendSpecToModel(backend.Spec))
+	changed := desired + 1
+
+	if r.detectDrift(backend, desired, true) {
+		t.Error("expected the first configuration applied not to be drift")
+	}
+	if r.detectDrift(backend, desired, false) {
+		t.Error("expected an unchanged HAProxy not to be drift")
+	}
+	if r.detectDrift(backend, changed, true) {
+		t.Error("expected a changed configuration not to be drift")
+	}
+	if !r.detectDrift(backend, changed, true) {
+		t.Error("expected changing HAProxy for the configuration applied last time to be drift")
+	}
+	counter := monitoring.BackendDriftCorrectionsTotal.WithLabelValues("shop", "web-drift", "web")
+	if got := testutil.ToFloat64(counter); got != 1 {
+		t.Errorf("expected 1 drift correction, got %v", got)
+	}
+
+	r.forgetBackend(backend)
+	if r.detectDrift(backend, changed, true) {
+		t.Error("expected the first configuration applied after the Backend is forgotten not to be drift")
+	}
+	if got := testutil.ToFloat64(monitoring.BackendDriftCorrectionsTotal.WithLabelValues("shop", "web-drift", "web")); got != 0 {
+		t.Errorf("expected the drift corrections of the forgotten Backend to be deleted, got %v", got)
+	}
+}
+
+func TestBackendFingerprint(t *testing.T) {
+	spec := externalhaproxyoperatorv1alpha1.BackendSpec{
+		Name: "web",
+		Servers: externalhaproxyoperatorv1alpha1.Servers{
+			{Name: "web-0", Address: "10.0.0.1"},
+			{Name: "web-1", Address: "10.0.0.2"},
+		},
+	}
+	reordered := *spec.DeepCopy()
+	reordered.Servers[0], reordered.Servers[1] = reordered.Servers[1], reordered.Servers[0]
+	if backendFingerprint(externalhaproxyoperatorv1alpha1.BackendSpecToModel(spec)) !=
+		backendFingerprint(externalhaproxyoperatorv1alpha1.BackendSpecToModel(reordered)) {
+		t.Error("expected the fingerprint not to depend on the order of the servers")
+	}
+
+	moved := *spec.DeepCopy()
+	moved.Servers[1].Address = "10.0.0.3"
+	if backendFingerprint(externalhaproxyoperatorv1alpha1.BackendSpecToModel(spec)) ==
+		backendFingerprint(externalhaproxyoperatorv1alpha1.BackendSpecToModel(moved)) {
+		t.Error("expected the fingerprint to change with the address of a server")
+	}
+}
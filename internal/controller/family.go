@@ -0,0 +1,215 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"net/netip"
+	"strings"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+// ipv6NameSuffix is appended to the names of IPv6 servers when both families are used, so they
+// do not collide with the IPv4 server for the same pod or node.
+const ipv6NameSuffix = "-ipv6"
+
+// familyAddress is an address selected for a server, with the suffix for the server name.
+type familyAddress struct {
+	address    string
+	nameSuffix string
+}
+
+// addressType returns the EndpointSlice address type of an address: IPv4, IPv6, or FQDN for
+// anything that is not an IP.
+func addressType(address string) discoveryv1.AddressType {
+	addr, err := netip.ParseAddr(strings.Trim(address, "[]"))
+	if err != nil {
+		return discoveryv1.AddressTypeFQDN
+	}
+	if addr.Unmap().Is4() {
+		return discoveryv1.AddressTypeIPv4
+	}
+	return discoveryv1.AddressTypeIPv6
+}
+
+// selectAddresses picks the addresses of a single pod, node or load balancer to use for the given
+// address family. Hostnames are always used.
+func selectAddresses(addresses []string, family externalhaproxyoperatorv1alpha1.AddressFamily) []familyAddress {
+	var ipv4, ipv6, fqdn []string
+	for _, address := range addresses {
+		switch addressType(address) {
+		case discoveryv1.AddressTypeIPv4:
+			ipv4 = append(ipv4, address)
+		case discoveryv1.AddressTypeIPv6:
+			ipv6 = append(ipv6, address)
+		default:
+			fqdn = append(fqdn, address)
+		}
+	}
+
+	var selected []familyAddress
+	add := func(addresses []string, suffix string) {
+		for _, address := range addresses {
+			selected = append(selected, familyAddress{address: address, nameSuffix: suffix})
+		}
+	}
+	switch family {
+	case externalhaproxyoperatorv1alpha1.AddressFamilyIPv4:
+		add(first(ipv4), "")
+	case externalhaproxyoperatorv1alpha1.AddressFamilyIPv6:
+		add(first(ipv6), "")
+	case externalhaproxyoperatorv1alpha1.AddressFamilyDualStack:
+		add(first(ipv4), "")
+		if len(ipv4) > 0 {
+			add(first(ipv6), ipv6NameSuffix)
+		} else {
+			add(first(ipv6), "")
+		}
+	default:
+		if len(ipv4) > 0 {
+			add(first(ipv4), "")
+		} else {
+			add(first(ipv6), "")
+		}
+	}
+	if len(selected) == 0 {
+		add(first(fqdn), "")
+	}
+	return selected
+}
+
+// first returns a slice holding the first address, if any.
+func first(addresses []string) []string {
+	if len(addresses) == 0 {
+		return nil
+	}
+	return addresses[:1]
+}
+
+// filterEndpointFamilies applies the address family to endpoints read from EndpointSlices of all
+// address types. Endpoints of the same pod are considered together, so a dual-stack pod yields one
+// server per selected family. Endpoints without a pod are filtered together, see filterAddressGroup.
+func filterEndpointFamilies(endpoints []resolvedEndpoint, family externalhaproxyoperatorv1alpha1.AddressFamily) []resolvedEndpoint {
+	var (
+		order  []string
+		groups = map[string][]resolvedEndpoint{}
+	)
+	for _, endpoint := range endpoints {
+		// Endpoints without a pod share the empty key
+		key := endpoint.podName
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], endpoint)
+	}
+
+	var filtered []resolvedEndpoint
+	for _, key := range order {
+		group := groups[key]
+		if key == "" {
+			filtered = append(filtered, filterAddressGroup(group, family)...)
+			continue
+		}
+		addresses := make([]string, 0, len(group))
+		for _, endpoint := range group {
+			addresses = append(addresses, endpoint.address)
+		}
+		for _, selected := range selectAddresses(addresses, family) {
+			for _, endpoint := range group {
+				if endpoint.address == selected.address {
+					endpoint.nameSuffix = selected.nameSuffix
+					filtered = append(filtered, endpoint)
+					break
+				}
+			}
+		}
+	}
+	return filtered
+}
+
+// filterAddressGroup applies the address family to endpoints without a pod, see filterAddresses.
+func filterAddressGroup(group []resolvedEndpoint, family externalhaproxyoperatorv1alpha1.AddressFamily) []resolvedEndpoint {
+	addresses := make([]string, 0, len(group))
+	for _, endpoint := range group {
+		addresses = append(addresses, endpoint.address)
+	}
+	keep := map[string]bool{}
+	for _, address := range filterAddresses(addresses, family) {
+		keep[address] = true
+	}
+	var filtered []resolvedEndpoint
+	for _, endpoint := range group {
+		if keep[endpoint.address] {
+			filtered = append(filtered, endpoint)
+		}
+	}
+	return filtered
+}
+
+// filterAddresses applies the address family to addresses that each become their own server.
+// With PreferIPv4, IPv6 addresses are only used when there is no IPv4 address. Hostnames are
+// always used.
+func filterAddresses(addresses []string, family externalhaproxyoperatorv1alpha1.AddressFamily) []string {
+	hasIPv4 := false
+	for _, address := range addresses {
+		if addressType(address) == discoveryv1.AddressTypeIPv4 {
+			hasIPv4 = true
+		}
+	}
+	var filtered []string
+	for _, address := range addresses {
+		switch addressType(address) {
+		case discoveryv1.AddressTypeIPv4:
+			if family == externalhaproxyoperatorv1alpha1.AddressFamilyIPv6 {
+				continue
+			}
+		case discoveryv1.AddressTypeIPv6:
+			if family == externalhaproxyoperatorv1alpha1.AddressFamilyIPv4 {
+				continue
+			}
+			if hasIPv4 && family != externalhaproxyoperatorv1alpha1.AddressFamilyIPv6 &&
+				family != externalhaproxyoperatorv1alpha1.AddressFamilyDualStack {
+				continue
+			}
+		}
+		filtered = append(filtered, address)
+	}
+	return filtered
+}
+
+// formatServerAddress returns the address in the form HAProxy expects in a server line: IPs in
+// canonical form without zone, with IPv6 addresses in brackets so that the port appended to them
+// is not read as part of the address. Hostnames are returned unchanged.
+func formatServerAddress(address string) string {
+	addr, err := netip.ParseAddr(strings.Trim(address, "[]"))
+	if err != nil {
+		return address
+	}
+	addr = addr.WithZone("").Unmap()
+	if addr.Is6() {
+		return "[" + addr.String() + "]"
+	}
+	return addr.String()
+}
+
+// addressName returns a server name for an address: the canonical IP without brackets or zone,
+// or the hostname.
+func addressName(address string) string {
+	return strings.Trim(formatServerAddress(address), "[]")
+}
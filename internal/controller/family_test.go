@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+func TestFormatServerAddress(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.1":                "10.0.0.1",
+		"2001:DB8:0:0::1":         "[2001:db8::1]",
+		"[2001:db8::1]":           "[2001:db8::1]",
+		"fe80::1%eth0":            "[fe80::1]",
+		"::ffff:192.0.2.1":        "192.0.2.1",
+		"legacy.example.com":      "legacy.example.com",
+		"web-0.web.default.svc":   "web-0.web.default.svc",
+		"2001:db8:1:2:3:4:5:6789": "[2001:db8:1:2:3:4:5:6789]",
+	}
+	for address, want := range tests {
+		if got := formatServerAddress(address); got != want {
+			t.Errorf("formatServerAddress(%q) = %q, expected %q", address, got, want)
+		}
+	}
+}
+
+func dualStackEndpoints() []resolvedEndpoint {
+	return []resolvedEndpoint{
+		{podName: "web-0", address: "10.0.0.1"},
+		{podName: "web-1", address: "10.0.0.2"},
+		{podName: "web-0", address: "2001:db8::1"},
+		{podName: "web-1", address: "2001:db8::2"},
+		{podName: "web-2", address: "2001:db8::3"},
+	}
+}
+
+func endpointNames(endpoints []resolvedEndpoint) []string {
+	names := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		names = append(names, endpoint.name()+"="+formatServerAddress(endpoint.address))
+	}
+	return names
+}
+
+func TestFilterEndpointFamilies(t *testing.T) {
+	tests := []struct {
+		family externalhaproxyoperatorv1alpha1.AddressFamily
+		want   []string
+	}{
+		{
+			family: externalhaproxyoperatorv1alpha1.AddressFamilyIPv4,
+			want:   []string{"web-0=10.0.0.1", "web-1=10.0.0.2"},
+		},
+		{
+			family: externalhaproxyoperatorv1alpha1.AddressFamilyIPv6,
+			want:   []string{"web-0=[2001:db8::1]", "web-1=[2001:db8::2]", "web-2=[2001:db8::3]"},
+		},
+		{
+			family: externalhaproxyoperatorv1alpha1.AddressFamilyPreferIPv4,
+			want:   []string{"web-0=10.0.0.1", "web-1=10.0.0.2", "web-2=[2001:db8::3]"},
+		},
+		{
+			family: externalhaproxyoperatorv1alpha1.AddressFamilyDualStack,
+			want: []string{
+				"web-0=10.0.0.1", "web-0-ipv6=[2001:db8::1]",
+				"web-1=10.0.0.2", "web-1-ipv6=[2001:db8::2]",
+				"web-2=[2001:db8::3]",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.family), func(t *testing.T) {
+			got := endpointNames(filterEndpointFamilies(dualStackEndpoints(), tt.family))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFilterEndpointFamilies_WithoutPods(t *testing.T) {
+	endpoints := []resolvedEndpoint{
+		{address: "192.0.2.1"},
+		{address: "2001:db8::10"},
+		{address: "vm.example.com"},
+	}
+
+	got := endpointNames(filterEndpointFamilies(endpoints, ""))
+	want := []string{"192.0.2.1=192.0.2.1", "vm.example.com=vm.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	got = endpointNames(filterEndpointFamilies(endpoints, externalhaproxyoperatorv1alpha1.AddressFamilyIPv6))
+	want = []string{"2001:db8::10=[2001:db8::10]", "vm.example.com=vm.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
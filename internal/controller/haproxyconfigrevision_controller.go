@@ -0,0 +1,135 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+// HAProxyConfigRevisionReconciler restores the HAProxy configuration of a HAProxyConfigRevision
+// annotated with the restore annotation.
+type HAProxyConfigRevisionReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	Recorder      record.EventRecorder
+	HAProxyClient haproxyclient.HAProxyClient
+
+	// Revisions records the restored configuration as a new revision.
+	Revisions *ConfigRevisions
+}
+
+// +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=haproxyconfigrevisions,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=haproxyconfigrevisions/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+// Reconcile replaces the HAProxy configuration with the configuration of a revision annotated with
+// the restore annotation, and removes the annotation. The secrets redacted from the revision are
+// taken from the current configuration. The outcome is reported in the Restored condition of the
+// revision.
+func (r *HAProxyConfigRevisionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	reqLogger := logf.FromContext(ctx)
+
+	revision := &externalhaproxyoperatorv1alpha1.HAProxyConfigRevision{}
+	if err := r.Get(ctx, req.NamespacedName, revision); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		reqLogger.Error(err, "Failed to get HAProxyConfigRevision.")
+		return ctrl.Result{}, err
+	}
+	if _, ok := revision.Annotations[externalhaproxyoperatorv1alpha1.RestoreAnnotation]; !ok {
+		return ctrl.Result{}, nil
+	}
+
+	haproxyTransactionMu.Lock()
+	defer haproxyTransactionMu.Unlock()
+
+	condition := metav1.Condition{Type: "Restored"}
+	raw, err := r.restoredConfig(ctx, revision)
+	var reloadID string
+	if err == nil {
+		reloadID, err = r.HAProxyClient.ReplaceConfiguration(ctx, raw)
+	}
+	if haproxyclient.IsUnavailable(err) {
+		reqLogger.Info("HAProxy Data Plane API is unavailable, requeueing", "requeueAfter", haproxyUnavailableRequeueAfter)
+		return ctrl.Result{RequeueAfter: haproxyUnavailableRequeueAfter}, nil
+	}
+	if err == nil {
+		condition.Status, condition.Reason = metav1.ConditionTrue, "Restored"
+		condition.Message = fmt.Sprintf("Restored the configuration of version %d", revision.Spec.Version)
+		if reloadID != "" {
+			condition.Message += " in reload " + reloadID
+		}
+		reqLogger.Info("Restored the HAProxy configuration", "revision", revision.Name, "reloadID", reloadID)
+		r.Recorder.Event(revision, "Normal", "Restored", condition.Message)
+		r.Revisions.Record(ctx, "HAProxyConfigRevision "+revision.Name, reloadID)
+	} else {
+		condition.Status, condition.Reason = metav1.ConditionFalse, "RestoreFailed"
+		condition.Message = "Failed to restore the configuration: " + err.Error()
+		reqLogger.Error(err, "Failed to restore the HAProxy configuration", "revision", revision.Name)
+		r.Recorder.Event(revision, "Warning", "RestoreFailed", condition.Message)
+	}
+
+	// The restore is attempted once per annotation, as HAProxy has moved on when it is retried
+	delete(revision.Annotations, externalhaproxyoperatorv1alpha1.RestoreAnnotation)
+	if err := r.Update(ctx, revision); err != nil {
+		// Recording the restored configuration may have deleted the oldest revision
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	meta.SetStatusCondition(&revision.Status.Conditions, condition)
+	if err := r.Status().Update(ctx, revision); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// restoredConfig returns the configuration of the revision, with the secrets redacted from it taken
+// from the current configuration.
+func (r *HAProxyConfigRevisionReconciler) restoredConfig(ctx context.Context, revision *externalhaproxyoperatorv1alpha1.HAProxyConfigRevision) (string, error) {
+	raw, err := revision.RawConfig()
+	if err != nil {
+		return "", err
+	}
+	current, err := r.HAProxyClient.GetRawConfiguration(ctx)
+	if err != nil {
+		return "", err
+	}
+	return unredactConfig(raw, current.Raw)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HAProxyConfigRevisionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&externalhaproxyoperatorv1alpha1.HAProxyConfigRevision{}).
+		Named("haproxyconfigrevision").
+		WithEventFilter(predicate.AnnotationChangedPredicate{}).
+		Complete(r)
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+const (
+	// backendServiceIndex indexes Backends by the "namespace/name" keys of the Services they reference.
+	backendServiceIndex = "spec.servers.valueFrom.service"
+	// backendServiceNamespaceIndex indexes Backends by the namespaces of Services they reference
+	// outside of their own namespace.
+	backendServiceNamespaceIndex = "spec.servers.valueFrom.crossNamespace"
+	// backendNodeAddressIndex indexes Backends that address servers by node IPs.
+	backendNodeAddressIndex = "spec.servers.valueFrom.nodeAddress"
+	// backendResolverIndex indexes Backends by the "namespace/name" keys of the Resolvers their
+	// servers and server templates reference.
+	backendResolverIndex = "spec.resolversRef"
+	// backendResolversSectionIndex indexes Backends by the resolvers sections their servers and
+	// server templates name directly.
+	backendResolversSectionIndex = "spec.resolvers"
+
+	// nodeAddressIndexValue is the only value stored in backendNodeAddressIndex.
+	nodeAddressIndexValue = "true"
+)
+
+// setupIndexers registers the field indexers used to map watched objects to Backends without
+// listing and inspecting every Backend on each event.
+func setupIndexers(ctx context.Context, mgr ctrl.Manager) error {
+	indexer := mgr.GetFieldIndexer()
+	backend := &externalhaproxyoperatorv1alpha1.Backend{}
+	if err := indexer.IndexField(ctx, backend, backendServiceIndex, indexBackendServices); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, backend, backendServiceNamespaceIndex, indexBackendServiceNamespaces); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, backend, backendNodeAddressIndex, indexBackendNodeAddresses); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, backend, backendResolverIndex, indexBackendResolvers); err != nil {
+		return err
+	}
+	return indexer.IndexField(ctx, backend, backendResolversSectionIndex, indexBackendResolversSections)
+}
+
+// objectIndexKey returns the key of a namespaced object in backendServiceIndex and backendResolverIndex.
+func objectIndexKey(namespace, name string) string {
+	return types.NamespacedName{Namespace: namespace, Name: name}.String()
+}
+
+func indexBackendServices(obj client.Object) []string {
+	backend, ok := obj.(*externalhaproxyoperatorv1alpha1.Backend)
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for _, server := range backend.Spec.Servers {
+		if namespace, name, ok := serverServiceRef(backend, server); ok {
+			keys = append(keys, objectIndexKey(namespace, name))
+		}
+	}
+	return keys
+}
+
+func indexBackendServiceNamespaces(obj client.Object) []string {
+	backend, ok := obj.(*externalhaproxyoperatorv1alpha1.Backend)
+	if !ok {
+		return nil
+	}
+	var namespaces []string
+	for _, server := range backend.Spec.Servers {
+		if namespace, _, ok := serverServiceRef(backend, server); ok && namespace != backend.Namespace {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
+
+func indexBackendNodeAddresses(obj client.Object) []string {
+	backend, ok := obj.(*externalhaproxyoperatorv1alpha1.Backend)
+	if !ok || !usesNodeAddresses(backend) {
+		return nil
+	}
+	return []string{nodeAddressIndexValue}
+}
+
+func indexBackendResolvers(obj client.Object) []string {
+	backend, ok := obj.(*externalhaproxyoperatorv1alpha1.Backend)
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for _, server := range backend.Spec.Servers {
+		if server.ResolversRef != "" {
+			keys = append(keys, objectIndexKey(backend.Namespace, server.ResolversRef))
+		}
+	}
+	for _, template := range backend.Spec.ServerTemplates {
+		if template.ResolversRef != "" {
+			keys = append(keys, objectIndexKey(backend.Namespace, template.ResolversRef))
+		}
+	}
+	return keys
+}
+
+func indexBackendResolversSections(obj client.Object) []string {
+	backend, ok := obj.(*externalhaproxyoperatorv1alpha1.Backend)
+	if !ok {
+		return nil
+	}
+	var sections []string
+	for _, server := range backend.Spec.Servers {
+		if server.Resolvers != "" {
+			sections = append(sections, server.Resolvers)
+		}
+	}
+	for _, template := range backend.Spec.ServerTemplates {
+		if template.Resolvers != "" {
+			sections = append(sections, template.Resolvers)
+		}
+	}
+	return sections
+}
+
+// requestsForIndexedBackends returns a reconcile request for every Backend whose index field has the given value.
+func requestsForIndexedBackends(ctx context.Context, r *BackendReconciler, field, value string) []reconcile.Request {
+	backendList := &externalhaproxyoperatorv1alpha1.BackendList{}
+	if err := r.List(ctx, backendList, client.MatchingFields{field: value}); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to list Backends", "field", field, "value", value)
+		return nil
+	}
+	reqs := make([]reconcile.Request, 0, len(backendList.Items))
+	for _, backend := range backendList.Items {
+		reqs = append(reqs, reconcile.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      backend.Name,
+				Namespace: backend.Namespace,
+			},
+		})
+	}
+	return reqs
+}
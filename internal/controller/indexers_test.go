@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+func newIndexedTestReconciler(tb testing.TB, objs ...client.Object) *BackendReconciler {
+	tb.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		tb.Fatal(err)
+	}
+	if err := externalhaproxyoperatorv1alpha1.AddToScheme(scheme); err != nil {
+		tb.Fatal(err)
+	}
+	backend := &externalhaproxyoperatorv1alpha1.Backend{}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithIndex(backend, backendServiceIndex, indexBackendServices).
+		WithIndex(backend, backendServiceNamespaceIndex, indexBackendServiceNamespaces).
+		WithIndex(backend, backendNodeAddressIndex, indexBackendNodeAddresses).
+		WithIndex(backend, backendResolverIndex, indexBackendResolvers).
+		WithIndex(backend, backendResolversSectionIndex, indexBackendResolversSections).
+		Build()
+	return &BackendReconciler{Client: c, Scheme: scheme}
+}
+
+func newServiceRefBackend(namespace, name string, ref externalhaproxyoperatorv1alpha1.K8sServiceRef) *externalhaproxyoperatorv1alpha1.Backend {
+	return &externalhaproxyoperatorv1alpha1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: externalhaproxyoperatorv1alpha1.BackendSpec{
+			Name: name,
+			Servers: externalhaproxyoperatorv1alpha1.Servers{
+				{ValueFrom: &externalhaproxyoperatorv1alpha1.ServerValueFromSource{ServiceRef: &ref}},
+			},
+		},
+	}
+}
+
+func newTestEndpointSlice(namespace, service string) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      service + "-abc",
+			Labels:    map[string]string{"kubernetes.io/service-name": service},
+		},
+	}
+}
+
+func requestNames(reqs []reconcile.Request) []string {
+	names := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		names = append(names, req.String())
+	}
+	return names
+}
+
+func TestEnqueueRequests_UseIndexes(t *testing.T) {
+	r := newIndexedTestReconciler(t,
+		newServiceRefBackend("tenant-a", "local", externalhaproxyoperatorv1alpha1.K8sServiceRef{Name: "web"}),
+		newServiceRefBackend("tenant-b", "remote", externalhaproxyoperatorv1alpha1.K8sServiceRef{Namespace: "tenant-a", Name: "web"}),
+		newServiceRefBackend("tenant-b", "nodeport", externalhaproxyoperatorv1alpha1.K8sServiceRef{
+			Name: "api", AddressMode: externalhaproxyoperatorv1alpha1.ServiceAddressModeNodePort,
+		}),
+	)
+	ctx := context.Background()
+
+	got := requestNames(enqueueRequestsFromEndpointSlices(ctx, newTestEndpointSlice("tenant-a", "web"), r))
+	if len(got) != 2 {
+		t.Errorf("expected both Backends referencing tenant-a/web, got %v", got)
+	}
+
+	got = requestNames(enqueueRequestsFromServices(ctx, &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-b", Name: "web"}}, r))
+	if len(got) != 0 {
+		t.Errorf("expected no Backends for tenant-b/web, got %v", got)
+	}
+
+	got = requestNames(enqueueRequestsFromNodes(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}, r))
+	if len(got) != 1 || got[0] != "tenant-b/nodeport" {
+		t.Errorf("expected only the NodePort Backend, got %v", got)
+	}
+
+	grant := &externalhaproxyoperatorv1alpha1.ReferenceGrant{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant-a", Name: "grant"}}
+	got = requestNames(enqueueRequestsFromReferenceGrants(ctx, grant, r))
+	if len(got) != 1 || got[0] != "tenant-b/remote" {
+		t.Errorf("expected only the cross-namespace Backend, got %v", got)
+	}
+}
+
+// listAndGetRequestsFromEndpointSlice maps an EndpointSlice to Backends by listing every Backend
+// and getting each referenced Service, which is what the indexes replace. It is kept as the
+// baseline for the benchmarks.
+func listAndGetRequestsFromEndpointSlice(ctx context.Context, obj client.Object, r *BackendReconciler) []reconcile.Request {
+	var reqs []reconcile.Request
+	backendList := &externalhaproxyoperatorv1alpha1.BackendList{}
+	if err := r.List(ctx, backendList); err != nil {
+		return nil
+	}
+	for _, backend := range backendList.Items {
+		for _, server := range backend.Spec.Servers {
+			namespace, name, ok := serverServiceRef(&backend, server)
+			if !ok {
+				continue
+			}
+			service := &corev1.Service{}
+			if err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, service); err != nil {
+				continue
+			}
+			if obj.GetLabels()["kubernetes.io/service-name"] == service.Name && obj.GetNamespace() == service.Namespace {
+				reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&backend)})
+				break
+			}
+		}
+	}
+	return reqs
+}
+
+func newBenchmarkReconciler(b *testing.B, backends int) *BackendReconciler {
+	b.Helper()
+	objs := make([]client.Object, 0, 2*backends)
+	for i := 0; i < backends; i++ {
+		namespace := fmt.Sprintf("tenant-%d", i%50)
+		service := fmt.Sprintf("svc-%d", i)
+		objs = append(objs,
+			newServiceRefBackend(namespace, fmt.Sprintf("backend-%d", i), externalhaproxyoperatorv1alpha1.K8sServiceRef{Name: service}),
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: service}},
+		)
+	}
+	return newIndexedTestReconciler(b, objs...)
+}
+
+func benchmarkEndpointSliceMapping(b *testing.B, mapFunc func(context.Context, client.Object, *BackendReconciler) []reconcile.Request) {
+	r := newBenchmarkReconciler(b, 1000)
+	slice := newTestEndpointSlice("tenant-7", "svc-507")
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if reqs := mapFunc(ctx, slice, r); len(reqs) != 1 {
+			b.Fatalf("expected 1 request, got %d", len(reqs))
+		}
+	}
+}
+
+func BenchmarkEnqueueRequestsFromEndpointSlices_Indexed(b *testing.B) {
+	benchmarkEndpointSliceMapping(b, enqueueRequestsFromEndpointSlices)
+}
+
+func BenchmarkEnqueueRequestsFromEndpointSlices_ListAndGet(b *testing.B) {
+	benchmarkEndpointSliceMapping(b, listAndGetRequestsFromEndpointSlice)
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+// nodePortRefServers creates a server for each eligible node, addressed by the node IP and the
+// NodePort of the referenced Service. Nodes are filtered by the reference's selector, readiness and
+// schedulability. With externalTrafficPolicy Local, only nodes hosting a ready endpoint are used,
+// since other nodes drop the traffic.
+func (r *BackendReconciler) nodePortRefServers(
+	ctx context.Context,
+	reqLogger logr.Logger,
+	ref *externalhaproxyoperatorv1alpha1.K8sNodePortRef,
+	service *corev1.Service,
+	parent *externalhaproxyoperatorv1alpha1.Server,
+) (externalhaproxyoperatorv1alpha1.Servers, error) {
+	servicePort, err := resolveServicePort(service, ref.Port, parent.Port)
+	if err != nil {
+		return nil, err
+	}
+	if servicePort == nil || servicePort.NodePort == 0 {
+		return nil, fmt.Errorf("the referenced port of Service %s/%s has no NodePort", service.Namespace, service.Name)
+	}
+	port := int64(servicePort.NodePort)
+
+	selector := labels.Everything()
+	if ref.NodeSelector != nil {
+		selector, err = metav1.LabelSelectorAsSelector(ref.NodeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nodeSelector: %w", err)
+		}
+	}
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("listing nodes: %w", err)
+	}
+
+	var endpointNodes map[string]bool
+	if service.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyLocal {
+		slices := &discoveryv1.EndpointSliceList{}
+		if err := r.List(ctx, slices, client.InNamespace(service.Namespace), client.MatchingLabels{"kubernetes.io/service-name": service.Name}); err != nil {
+			return nil, fmt.Errorf("listing endpoints: %w", err)
+		}
+		endpointNodes = map[string]bool{}
+		for _, endpoint := range r.readyEndpoints(ctx, reqLogger, slices.Items) {
+			endpointNodes[endpoint.nodeName] = true
+		}
+	}
+
+	addressType := ref.AddressType
+	if addressType == "" {
+		addressType = corev1.NodeInternalIP
+	}
+
+	var servers externalhaproxyoperatorv1alpha1.Servers
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if !ref.IncludeNotReady && !nodeReady(node) {
+			reqLogger.V(2).Info("Skipping non-ready node", "node", node.Name)
+			continue
+		}
+		if !ref.IncludeUnschedulable && node.Spec.Unschedulable {
+			reqLogger.V(2).Info("Skipping unschedulable node", "node", node.Name)
+			continue
+		}
+		if endpointNodes != nil && !endpointNodes[node.Name] {
+			reqLogger.V(2).Info("Skipping node without local endpoints", "node", node.Name)
+			continue
+		}
+		addresses := selectAddresses(nodeAddresses(node, addressType), ref.AddressFamily)
+		if len(addresses) == 0 {
+			reqLogger.V(2).Info("Skipping node without address of type and family", "node", node.Name,
+				"addressType", addressType, "addressFamily", ref.AddressFamily)
+			continue
+		}
+		for _, selected := range addresses {
+			servers = append(servers, generatedServer(parent, node.Name+selected.nameSuffix, selected.address, &port))
+		}
+	}
+	return servers, nil
+}
+
+// nodeReady reports whether the node's Ready condition is true.
+func nodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// nodeAddresses returns the addresses of the node with the given type. Dual-stack nodes have one
+// of each family.
+func nodeAddresses(node *corev1.Node, addressType corev1.NodeAddressType) []string {
+	var addresses []string
+	for _, address := range node.Status.Addresses {
+		if address.Type == addressType {
+			addresses = append(addresses, address.Address)
+		}
+	}
+	return addresses
+}
+
+// nodeChangedPredicate only passes Node updates that can change which nodes are eligible or how
+// they are addressed, ignoring the frequent status heartbeats.
+var nodeChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldNode, ok1 := e.ObjectOld.(*corev1.Node)
+		newNode, ok2 := e.ObjectNew.(*corev1.Node)
+		if !ok1 || !ok2 {
+			return true
+		}
+		if nodeReady(oldNode) != nodeReady(newNode) ||
+			oldNode.Spec.Unschedulable != newNode.Spec.Unschedulable ||
+			!labels.Equals(oldNode.Labels, newNode.Labels) ||
+			len(oldNode.Status.Addresses) != len(newNode.Status.Addresses) {
+			return true
+		}
+		for i := range oldNode.Status.Addresses {
+			if oldNode.Status.Addresses[i] != newNode.Status.Addresses[i] {
+				return true
+			}
+		}
+		return false
+	},
+}
+
+// usesNodeAddresses reports whether any of the backend's servers are addressed by node IPs.
+func usesNodeAddresses(backend *externalhaproxyoperatorv1alpha1.Backend) bool {
+	for _, server := range backend.Spec.Servers {
+		if server.ValueFrom == nil {
+			continue
+		}
+		if server.ValueFrom.NodePortRef != nil {
+			return true
+		}
+		if server.ValueFrom.ServiceRef != nil &&
+			server.ValueFrom.ServiceRef.AddressMode == externalhaproxyoperatorv1alpha1.ServiceAddressModeNodePort {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+func newTestNode(name string, ready, unschedulable bool, labels map[string]string) *corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec:       corev1.NodeSpec{Unschedulable: unschedulable},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.0.0." + name[len(name)-1:]},
+				{Type: corev1.NodeExternalIP, Address: "192.0.2." + name[len(name)-1:]},
+			},
+		},
+	}
+}
+
+func newNodePortService(policy corev1.ServiceExternalTrafficPolicy) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			Type:                  corev1.ServiceTypeNodePort,
+			ExternalTrafficPolicy: policy,
+			Ports:                 []corev1.ServicePort{{Name: "http", Port: 80, NodePort: 30080}},
+		},
+	}
+}
+
+func resolveTestNodePortRef(t *testing.T, ref *externalhaproxyoperatorv1alpha1.K8sNodePortRef, service *corev1.Service, objs ...client.Object) []string {
+	t.Helper()
+	r := &BackendReconciler{Client: fake.NewClientBuilder().WithObjects(objs...).Build()}
+	servers, err := r.nodePortRefServers(context.Background(), logr.Discard(), ref, service, &externalhaproxyoperatorv1alpha1.Server{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var addresses []string
+	for _, s := range servers {
+		if s.Port == nil || *s.Port != 30080 {
+			t.Errorf("expected NodePort 30080 for %s, got %v", s.Name, s.Port)
+		}
+		addresses = append(addresses, s.Address)
+	}
+	return addresses
+}
+
+func TestNodePortRefServers_FiltersNodes(t *testing.T) {
+	objs := []client.Object{
+		newTestNode("node1", true, false, map[string]string{"role": "edge"}),
+		newTestNode("node2", false, false, map[string]string{"role": "edge"}),
+		newTestNode("node3", true, true, map[string]string{"role": "edge"}),
+		newTestNode("node4", true, false, nil),
+	}
+	service := newNodePortService(corev1.ServiceExternalTrafficPolicyCluster)
+
+	got := resolveTestNodePortRef(t, &externalhaproxyoperatorv1alpha1.K8sNodePortRef{Name: "web"}, service, objs...)
+	if len(got) != 2 || got[0] != "10.0.0.1" || got[1] != "10.0.0.4" {
+		t.Errorf("expected ready, schedulable nodes, got %v", got)
+	}
+
+	ref := &externalhaproxyoperatorv1alpha1.K8sNodePortRef{
+		Name:                 "web",
+		NodeSelector:         &metav1.LabelSelector{MatchLabels: map[string]string{"role": "edge"}},
+		AddressType:          corev1.NodeExternalIP,
+		IncludeNotReady:      true,
+		IncludeUnschedulable: true,
+	}
+	got = resolveTestNodePortRef(t, ref, service, objs...)
+	if len(got) != 3 || got[0] != "192.0.2.1" || got[1] != "192.0.2.2" || got[2] != "192.0.2.3" {
+		t.Errorf("expected external IPs of all edge nodes, got %v", got)
+	}
+}
+
+func TestNodePortRefServers_LocalTrafficPolicy(t *testing.T) {
+	node1 := "node1"
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta:  metav1.ObjectMeta{Name: "web-abc", Namespace: "default", Labels: map[string]string{"kubernetes.io/service-name": "web"}},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"10.244.0.5"}, NodeName: &node1}},
+	}
+	service := newNodePortService(corev1.ServiceExternalTrafficPolicyLocal)
+
+	got := resolveTestNodePortRef(t, &externalhaproxyoperatorv1alpha1.K8sNodePortRef{Name: "web"}, service,
+		newTestNode("node1", true, false, nil), newTestNode("node2", true, false, nil), slice)
+	if len(got) != 1 || got[0] != "10.0.0.1" {
+		t.Errorf("expected only the node hosting an endpoint, got %v", got)
+	}
+}
+
+func TestNodePortRefServers_RequiresNodePort(t *testing.T) {
+	service := newNodePortService(corev1.ServiceExternalTrafficPolicyCluster)
+	service.Spec.Ports[0].NodePort = 0
+	r := &BackendReconciler{Client: fake.NewClientBuilder().Build()}
+	_, err := r.nodePortRefServers(context.Background(), logr.Discard(), &externalhaproxyoperatorv1alpha1.K8sNodePortRef{Name: "web"}, service, &externalhaproxyoperatorv1alpha1.Server{})
+	if err == nil {
+		t.Fatal("expected error for a Service port without NodePort")
+	}
+}
+
+func TestNodePortRefServers_AddressFamily(t *testing.T) {
+	node := newTestNode("node1", true, false, nil)
+	node.Status.Addresses = append([]corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "fd00::1"}}, node.Status.Addresses...)
+	service := newNodePortService(corev1.ServiceExternalTrafficPolicyCluster)
+
+	tests := []struct {
+		family externalhaproxyoperatorv1alpha1.AddressFamily
+		want   []string
+	}{
+		{family: "", want: []string{"10.0.0.1"}},
+		{family: externalhaproxyoperatorv1alpha1.AddressFamilyIPv6, want: []string{"[fd00::1]"}},
+		{family: externalhaproxyoperatorv1alpha1.AddressFamilyDualStack, want: []string{"10.0.0.1", "[fd00::1]"}},
+	}
+	for _, tt := range tests {
+		ref := &externalhaproxyoperatorv1alpha1.K8sNodePortRef{Name: "web", AddressFamily: tt.family}
+		got := resolveTestNodePortRef(t, ref, service, node.DeepCopy())
+		if len(got) != len(tt.want) || got[0] != tt.want[0] || got[len(got)-1] != tt.want[len(tt.want)-1] {
+			t.Errorf("family %q: expected %v, got %v", tt.family, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+// orphanCheckInterval is how often the backends in HAProxy are checked for orphans.
+const orphanCheckInterval = 5 * time.Minute
+
+// OrphanedBackendMonitor periodically counts the backends in HAProxy that are marked as managed
+// by the operator but that no Backend resource manages, and exports the count as a metric. Only
+// the Backends the operator watches are considered, so backends managed by another operator
+// instance with a different watch namespace or label selector are counted as orphans.
+type OrphanedBackendMonitor struct {
+	Client        client.Reader
+	HAProxyClient haproxyclient.HAProxyClient
+}
+
+// Start checks for orphaned backends until the context is cancelled.
+func (m *OrphanedBackendMonitor) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx).WithName("orphaned-backends")
+	ticker := time.NewTicker(orphanCheckInterval)
+	defer ticker.Stop()
+	for {
+		orphans, err := m.countOrphans(ctx)
+		if err != nil {
+			logger.Error(err, "Failed to check HAProxy for orphaned backends")
+		} else {
+			monitoring.OrphanedBackends.Set(float64(len(orphans)))
+			if len(orphans) > 0 {
+				logger.Info("Found orphaned backends in HAProxy", "backends", orphans)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection makes only the leader check for orphaned backends.
+func (m *OrphanedBackendMonitor) NeedLeaderElection() bool {
+	return true
+}
+
+// countOrphans returns the names of the managed backends in HAProxy without a Backend resource.
+func (m *OrphanedBackendMonitor) countOrphans(ctx context.Context) ([]string, error) {
+	backends := &externalhaproxyoperatorv1alpha1.BackendList{}
+	if err := m.Client.List(ctx, backends); err != nil {
+		return nil, err
+	}
+	owned := make(map[string]bool, len(backends.Items))
+	for _, backend := range backends.Items {
+		owned[backend.Spec.Name] = true
+	}
+
+	// Listing outside of a transaction of the reconcilers reads the committed configuration
+	haproxyTransactionMu.Lock()
+	managed, err := m.HAProxyClient.ListBackends(ctx)
+	haproxyTransactionMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []string
+	for _, backend := range managed {
+		if !owned[backend.Name] {
+			orphans = append(orphans, backend.Name)
+		}
+	}
+	return orphans, nil
+}
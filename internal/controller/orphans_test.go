@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+func TestOrphanedBackendMonitor_CountOrphans(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `[{"name":"web","description":%[1]q},{"name":"old","description":%[1]q},{"name":"manual"}]`,
+			haproxyclient.ManagedDescription)
+	}))
+	defer ts.Close()
+
+	scheme := runtime.NewScheme()
+	if err := externalhaproxyoperatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	backend := &externalhaproxyoperatorv1alpha1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+		Spec:       externalhaproxyoperatorv1alpha1.BackendSpec{Name: "web"},
+	}
+	m := &OrphanedBackendMonitor{
+		Client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(backend).Build(),
+		HAProxyClient: haproxyclient.NewHAProxyClient(haproxyclient.HAProxyConfig{BaseURL: ts.URL, Timeout: 5 * time.Second}),
+	}
+
+	orphans, err := m.countOrphans(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orphans) != 1 || orphans[0] != "old" {
+		t.Errorf("expected only the managed backend without a Backend to be an orphan, got %v", orphans)
+	}
+}
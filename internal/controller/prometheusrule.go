@@ -0,0 +1,95 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheusrules,verbs=get;create;update
+
+// PrometheusRuleInstaller installs the PrometheusRule with the alerts and recording rules of the
+// operator when the manager starts, if the Prometheus Operator CRDs are installed. The rule is
+// overwritten on every start, so that it follows the version of the operator.
+type PrometheusRuleInstaller struct {
+	Client client.Client
+	// Reader reads the PrometheusRule without a cache, as the operator does not watch them.
+	Reader    client.Reader
+	Namespace string
+}
+
+// Start installs the PrometheusRule. Failing to install it is logged rather than stopping the
+// manager, as the operator works without it.
+func (i *PrometheusRuleInstaller) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx).WithName("prometheusrule")
+	installed, err := i.Install(ctx)
+	if err != nil {
+		logger.Error(err, "Failed to install PrometheusRule", "namespace", i.Namespace)
+		return nil
+	}
+	if !installed {
+		logger.Info("PrometheusRule CRD not found, skipping installation of the PrometheusRule")
+		return nil
+	}
+	logger.Info("Installed PrometheusRule", "namespace", i.Namespace)
+	return nil
+}
+
+// NeedLeaderElection makes only the leader install the PrometheusRule.
+func (i *PrometheusRuleInstaller) NeedLeaderElection() bool {
+	return true
+}
+
+// Install creates or updates the PrometheusRule, and reports whether the PrometheusRule CRD is
+// installed.
+func (i *PrometheusRuleInstaller) Install(ctx context.Context) (bool, error) {
+	gk := schema.GroupKind{Group: monitoringv1.SchemeGroupVersion.Group, Kind: monitoringv1.PrometheusRuleKind}
+	if _, err := i.Client.RESTMapper().RESTMapping(gk, monitoringv1.SchemeGroupVersion.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	desired := monitoring.NewPrometheusRule(i.Namespace)
+	existing := &monitoringv1.PrometheusRule{}
+	err := i.Reader.Get(ctx, client.ObjectKeyFromObject(desired), existing)
+	if errors.IsNotFound(err) {
+		return true, i.Client.Create(ctx, desired)
+	}
+	if err != nil {
+		return true, err
+	}
+
+	if existing.Labels == nil {
+		existing.Labels = map[string]string{}
+	}
+	for key, value := range desired.Labels {
+		existing.Labels[key] = value
+	}
+	existing.Spec = desired.Spec
+	return true, i.Client.Update(ctx, existing)
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	monitoringv1 "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+func TestPrometheusRuleInstaller_Install(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := monitoringv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	stale := &monitoringv1.PrometheusRule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      monitoring.NewPrometheusRule("monitoring").Name,
+			Namespace: "monitoring",
+			Labels:    map[string]string{"team": "platform"},
+		},
+	}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(monitoringv1.SchemeGroupVersion.WithKind(monitoringv1.PrometheusRuleKind), meta.RESTScopeNamespace)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(stale).Build()
+	installer := &PrometheusRuleInstaller{Client: c, Reader: c, Namespace: "monitoring"}
+
+	installed, err := installer.Install(context.Background())
+	if err != nil || !installed {
+		t.Fatalf("expected the PrometheusRule to be installed, got %v, %v", installed, err)
+	}
+
+	rule := &monitoringv1.PrometheusRule{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(stale), rule); err != nil {
+		t.Fatal(err)
+	}
+	if len(rule.Spec.Groups) != 2 {
+		t.Fatalf("expected the recording and alerting rule groups, got %+v", rule.Spec.Groups)
+	}
+	if len(rule.Spec.Groups[1].Rules) != len(monitoring.ListAlerts()) {
+		t.Errorf("expected %d alerts, got %d", len(monitoring.ListAlerts()), len(rule.Spec.Groups[1].Rules))
+	}
+	if rule.Labels["team"] != "platform" || rule.Labels["app.kubernetes.io/managed-by"] != "external-haproxy-operator" {
+		t.Errorf("expected the labels to be merged, got %v", rule.Labels)
+	}
+}
+
+func TestPrometheusRuleInstaller_NoCRD(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build()
+	installer := &PrometheusRuleInstaller{Client: c, Reader: c, Namespace: "monitoring"}
+
+	installed, err := installer.Install(context.Background())
+	if err != nil || installed {
+		t.Errorf("expected the installation to be skipped without the CRD, got %v, %v", installed, err)
+	}
+}
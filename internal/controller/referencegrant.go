@@ -0,0 +1,64 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+// serviceNamespace returns the namespace of a referenced Service, defaulting to the Backend's namespace.
+func serviceNamespace(backend *externalhaproxyoperatorv1alpha1.Backend, namespace string) string {
+	if namespace == "" {
+		return backend.Namespace
+	}
+	return namespace
+}
+
+// serviceReferencePermitted reports whether the Backend may reference the Service. References within
+// the Backend's namespace are always permitted; references to other namespaces need a ReferenceGrant
+// in the Service's namespace, so that one tenant cannot expose another tenant's Services.
+func (r *BackendReconciler) serviceReferencePermitted(
+	ctx context.Context,
+	backend *externalhaproxyoperatorv1alpha1.Backend,
+	namespace, name string,
+) (bool, error) {
+	if namespace == backend.Namespace {
+		return true, nil
+	}
+	grants := &externalhaproxyoperatorv1alpha1.ReferenceGrantList{}
+	if err := r.List(ctx, grants, client.InNamespace(namespace)); err != nil {
+		return false, err
+	}
+	for i := range grants.Items {
+		if grants.Items[i].PermitsServiceReference(backend.Namespace, name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func enqueueRequestsFromReferenceGrants(ctx context.Context, obj client.Object, r *BackendReconciler) []reconcile.Request {
+	reqLogger := logf.FromContext(ctx)
+	reqLogger.V(2).Info("Processing ReferenceGrant for Backend reconciliation", "referenceGrantName", obj.GetName(), "namespace", obj.GetNamespace())
+	return requestsForIndexedBackends(ctx, r, backendServiceNamespaceIndex, obj.GetNamespace())
+}
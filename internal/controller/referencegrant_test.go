@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+func TestResolveServerObjects_RevokedGrantRemovesServers(t *testing.T) {
+	port := int64(5432)
+	backend := newServiceRefBackend("shop", "db", externalhaproxyoperatorv1alpha1.K8sServiceRef{Namespace: "shared", Name: "postgres"})
+	backend.Spec.Servers = append(backend.Spec.Servers, &externalhaproxyoperatorv1alpha1.Server{
+		Name: "standby", Address: "192.0.2.10", Port: &port,
+	})
+	service := newTestService(corev1.ServicePort{Name: "postgres", Port: 5432})
+	service.Namespace, service.Name = "shared", "postgres"
+	slice := newTestEndpointSlice("shared", "postgres")
+	portName, targetPort := "postgres", int32(5432)
+	slice.Endpoints = []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.1"}}}
+	slice.Ports = []discoveryv1.EndpointPort{{Name: &portName, Port: &targetPort}}
+	grant := &externalhaproxyoperatorv1alpha1.ReferenceGrant{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shared", Name: "shop"},
+		Spec: externalhaproxyoperatorv1alpha1.ReferenceGrantSpec{
+			From: []externalhaproxyoperatorv1alpha1.ReferenceGrantFrom{{Namespace: "shop"}},
+			To:   []externalhaproxyoperatorv1alpha1.ReferenceGrantTo{{Name: "postgres"}},
+		},
+	}
+	r := newIndexedTestReconciler(t, backend, service, slice, grant)
+	r.Recorder = record.NewFakeRecorder(10)
+	ctx := context.Background()
+
+	resolved := backend.DeepCopy()
+	unresolved, err := resolveServerObjects(ctx, backend, logr.Discard(), r, resolved)
+	if err != nil || unresolved != nil {
+		t.Fatalf("unexpected failure to resolve the servers: %v, %+v", err, unresolved)
+	}
+	if len(resolved.Spec.Servers) != 2 {
+		t.Fatalf("expected the granted and the static server, got %+v", resolved.Spec.Servers)
+	}
+
+	// Revoking the grant removes the servers of the Service, while the backend is still applied
+	if err := r.Delete(ctx, grant); err != nil {
+		t.Fatal(err)
+	}
+	resolved = backend.DeepCopy()
+	unresolved, err = resolveServerObjects(ctx, backend, logr.Discard(), r, resolved)
+	if err != nil {
+		t.Fatalf("expected the backend to be applied without the servers, got %v", err)
+	}
+	if unresolved == nil || unresolved.reason != "RefNotPermitted" {
+		t.Errorf("expected the reference to be reported as not permitted, got %+v", unresolved)
+	}
+	if len(resolved.Spec.Servers) != 1 || resolved.Spec.Servers[0].Name != "standby" {
+		t.Errorf("expected only the static server to remain, got %+v", resolved.Spec.Servers)
+	}
+
+	// So does deleting the Service
+	restored := grant.DeepCopy()
+	restored.ResourceVersion = ""
+	if err := r.Create(ctx, restored); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete(ctx, service); err != nil {
+		t.Fatal(err)
+	}
+	resolved = backend.DeepCopy()
+	unresolved, err = resolveServerObjects(ctx, backend, logr.Discard(), r, resolved)
+	if err != nil || unresolved == nil || unresolved.reason != "ServiceNotFound" {
+		t.Errorf("expected the deleted Service to be reported, got %v, %+v", err, unresolved)
+	}
+	if len(resolved.Spec.Servers) != 1 {
+		t.Errorf("expected only the static server to remain, got %+v", resolved.Spec.Servers)
+	}
+}
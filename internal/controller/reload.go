@@ -0,0 +1,132 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+// reloadPollInterval is how often a pending HAProxy reload of the changes to a Backend is checked.
+const reloadPollInterval = 2 * time.Second
+
+// checkReload checks the HAProxy reload applying the last changes to the Backend, and reports it in
+// the Reloaded condition. While the reload is pending or after it failed, it also reports it in the
+// ReconcilingComplete condition, and returns false with the result requeueing the Backend to check
+// it again. When the reload failed, the configuration from before the changes is restored if
+// possible, and the reload of the rollback is checked next; otherwise the failed reload is kept until the next changes are committed, as HAProxy
+// runs the previous configuration until then. Callers must hold haproxyTransactionMu.
+func (r *BackendReconciler) checkReload(ctx context.Context, reqLogger logr.Logger, backend *externalhaproxyoperatorv1alpha1.Backend) (ctrl.Result, bool) {
+	id := backend.Status.ReloadID
+	reload, err := r.HAProxyClient.GetReload(ctx, id)
+	if err != nil {
+		reqLogger.Error(err, "Failed to get the HAProxy reload", "reloadID", id)
+		r.setCondition(backend, metav1.Condition{
+			Type:    "ReconcilingComplete",
+			Status:  metav1.ConditionUnknown,
+			Reason:  "ReloadPending",
+			Message: "Failed to get the HAProxy reload " + id + ": " + err.Error(),
+		})
+		return ctrl.Result{RequeueAfter: reloadPollInterval}, false
+	}
+
+	switch {
+	case reload == nil:
+		// The Data Plane API restarted since, and the outcome of the reload is lost
+		r.setCondition(backend, metav1.Condition{
+			Type:    "Reloaded",
+			Status:  metav1.ConditionUnknown,
+			Reason:  "ReloadUnknown",
+			Message: "The HAProxy Data Plane API no longer knows reload " + id,
+		})
+	case reload.Status == haproxyclient.ReloadSucceeded:
+		reqLogger.V(1).Info("HAProxy reloaded", "reloadID", id)
+		r.setCondition(backend, metav1.Condition{
+			Type:    "Reloaded",
+			Status:  metav1.ConditionTrue,
+			Reason:  "Reloaded",
+			Message: "HAProxy reloaded with the changes in reload " + id,
+		})
+	case reload.Status == haproxyclient.ReloadFailed:
+		message := "HAProxy failed to reload with the changes in reload " + id + ": " + reload.Response
+		if previous := meta.FindStatusCondition(backend.Status.Conditions, "Reloaded"); previous == nil || previous.Reason != "ReloadFailed" {
+			reqLogger.Info("HAProxy failed to reload", "reloadID", id, "response", reload.Response)
+			r.Recorder.Event(backend, "Warning", "ReloadFailed", message)
+		}
+		r.setCondition(backend, metav1.Condition{
+			Type:    "Reloaded",
+			Status:  metav1.ConditionFalse,
+			Reason:  "ReloadFailed",
+			Message: message,
+		})
+		if r.rollBack(ctx, reqLogger, backend, reload) {
+			r.setCondition(backend, metav1.Condition{
+				Type:    "ReconcilingComplete",
+				Status:  metav1.ConditionFalse,
+				Reason:  "RolledBack",
+				Message: message,
+			})
+			if backend.Status.ReloadID != "" {
+				// The reload of the rollback is checked next
+				return ctrl.Result{RequeueAfter: reloadPollInterval}, false
+			}
+			return ctrl.Result{}, false
+		}
+		r.setCondition(backend, metav1.Condition{
+			Type:    "ReconcilingComplete",
+			Status:  metav1.ConditionFalse,
+			Reason:  "ReloadFailed",
+			Message: message,
+		})
+		return ctrl.Result{}, false
+	default:
+		r.setCondition(backend, metav1.Condition{
+			Type:    "Reloaded",
+			Status:  metav1.ConditionUnknown,
+			Reason:  "ReloadPending",
+			Message: "Waiting for HAProxy to reload with the changes in reload " + id,
+		})
+		r.setCondition(backend, metav1.Condition{
+			Type:    "ReconcilingComplete",
+			Status:  metav1.ConditionUnknown,
+			Reason:  "ReloadPending",
+			Message: "Waiting for HAProxy to reload with the changes in reload " + id,
+		})
+		return ctrl.Result{RequeueAfter: reloadPollInterval}, false
+	}
+	r.forgetSnapshot(backend)
+	backend.Status.ReloadID = ""
+	return ctrl.Result{}, true
+}
+
+// reloadPending reports whether the changes to the Backend with the fingerprint were committed and
+// the reload applying them is still tracked, in which case only the reload is checked instead of
+// applying the changes again. Callers must hold haproxyTransactionMu.
+func (r *BackendReconciler) reloadPending(backend *externalhaproxyoperatorv1alpha1.Backend, fingerprint uint64) bool {
+	state := r.rollbacks[types.NamespacedName{Namespace: backend.Namespace, Name: backend.Name}]
+	return backend.Status.ReloadID != "" && state != nil && !state.rolledBack &&
+		state.reloadID == backend.Status.ReloadID && state.fingerprint == fingerprint
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+// newReloadTestReconciler returns a reconciler whose Data Plane API reports the reload as reload,
+// or does not know it when reload is empty.
+func newReloadTestReconciler(t *testing.T, reload *string) (*BackendReconciler, *record.FakeRecorder) {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/services/haproxy/reloads/1-2" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		if *reload == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, *reload)
+	}))
+	t.Cleanup(ts.Close)
+	recorder := record.NewFakeRecorder(10)
+	return &BackendReconciler{
+		Recorder:      recorder,
+		HAProxyClient: haproxyclient.NewHAProxyClient(haproxyclient.HAProxyConfig{BaseURL: ts.URL, Timeout: 5 * time.Second}),
+	}, recorder
+}
+
+func TestCheckReload(t *testing.T) {
+	reload := `{"id":"1-2","status":"in_progress"}`
+	r, recorder := newReloadTestReconciler(t, &reload)
+	backend := &externalhaproxyoperatorv1alpha1.Backend{
+		Spec:   externalhaproxyoperatorv1alpha1.BackendSpec{Name: "web"},
+		Status: externalhaproxyoperatorv1alpha1.BackendStatus{ReloadID: "1-2"},
+	}
+
+	result, done := r.checkReload(context.Background(), logr.Discard(), backend)
+	if done || result.RequeueAfter != reloadPollInterval {
+		t.Errorf("expected the pending reload to be checked again, got %+v, %v", result, done)
+	}
+	if conditionStatus(backend, "Reloaded") != metav1.ConditionUnknown || conditionStatus(backend, "ReconcilingComplete") != metav1.ConditionUnknown {
+		t.Errorf("expected the reload to be pending, got %+v", backend.Status.Conditions)
+	}
+
+	reload = `{"id":"1-2","status":"failed","response":"[ALERT] backend 'web' has no server available"}`
+	for range 2 {
+		if _, done := r.checkReload(context.Background(), logr.Discard(), backend); done {
+			t.Error("expected the failed reload to keep the backend from completing")
+		}
+	}
+	condition := meta.FindStatusCondition(backend.Status.Conditions, "Reloaded")
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != "ReloadFailed" ||
+		condition.Message != "HAProxy failed to reload with the changes in reload 1-2: [ALERT] backend 'web' has no server available" {
+		t.Errorf("expected the reload to have failed with the HAProxy error, got %+v", condition)
+	}
+	if conditionStatus(backend, "ReconcilingComplete") != metav1.ConditionFalse || backend.Status.ReloadID != "1-2" {
+		t.Errorf("expected the failed reload to be kept, got %+v", backend.Status)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("expected a single event for the failed reload, got %d", len(recorder.Events))
+	}
+
+	reload = `{"id":"1-2","status":"succeeded"}`
+	if _, done := r.checkReload(context.Background(), logr.Discard(), backend); !done {
+		t.Error("expected the reload to be done")
+	}
+	if conditionStatus(backend, "Reloaded") != metav1.ConditionTrue || backend.Status.ReloadID != "" {
+		t.Errorf("expected the reload to have succeeded, got %+v", backend.Status)
+	}
+}
+
+func TestCheckReload_Unknown(t *testing.T) {
+	reload := ""
+	r, _ := newReloadTestReconciler(t, &reload)
+	backend := &externalhaproxyoperatorv1alpha1.Backend{Status: externalhaproxyoperatorv1alpha1.BackendStatus{ReloadID: "1-2"}}
+
+	if _, done := r.checkReload(context.Background(), logr.Discard(), backend); !done {
+		t.Error("expected a reload the Data Plane API does not know to be done")
+	}
+	if conditionStatus(backend, "Reloaded") != metav1.ConditionUnknown || backend.Status.ReloadID != "" {
+		t.Errorf("expected the outcome of the reload to be unknown, got %+v", backend.Status)
+	}
+}
+
+func TestReloadPending(t *testing.T) {
+	r := &BackendReconciler{}
+	backend := &externalhaproxyoperatorv1alpha1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+		Status:     externalhaproxyoperatorv1alpha1.BackendStatus{ReloadID: "1-2"},
+	}
+	if r.reloadPending(backend, 42) {
+		t.Error("expected an untracked reload not to be pending, such as after a restart")
+	}
+
+	r.rememberSnapshot(backend, haproxyclient.Transaction{ReloadID: "1-2"}, 42)
+	if !r.reloadPending(backend, 42) {
+		t.Error("expected the reload of the committed changes to be pending")
+	}
+	if r.reloadPending(backend, 43) {
+		t.Error("expected new changes to be applied while the reload is pending")
+	}
+	backend.Status.ReloadID = ""
+	if r.reloadPending(backend, 42) {
+		t.Error("expected a completed reload not to be pending")
+	}
+}
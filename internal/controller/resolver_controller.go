@@ -0,0 +1,251 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+// resolverInUseRequeueAfter is how often a deleted Resolver checks whether Backends still use its
+// resolvers section.
+const resolverInUseRequeueAfter = 30 * time.Second
+
+// ResolverReconciler reconciles a Resolver object
+type ResolverReconciler struct {
+	client.Client
+	Scheme        *runtime.Scheme
+	Recorder      record.EventRecorder
+	HAProxyClient haproxyclient.HAProxyClient
+
+	// Revisions records the HAProxy configuration after each change to a Resolver.
+	Revisions *ConfigRevisions
+}
+
+func (r *ResolverReconciler) setCondition(
+	resolver *externalhaproxyoperatorv1alpha1.Resolver,
+	condition metav1.Condition,
+) {
+	meta.SetStatusCondition(&resolver.Status.Conditions, condition)
+	if condition.Type == "ReconcilingComplete" && condition.Status == metav1.ConditionFalse {
+		monitoring.ReconcileFailuresTotal.WithLabelValues("resolver", condition.Reason).Inc()
+	}
+}
+
+// +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=resolvers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=resolvers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=external-haproxy-operator.ullberg.us,resources=resolvers/finalizers,verbs=update
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+// Reconcile ensures the HAProxy resolvers section described by a Resolver exists, and removes it
+// when the Resolver is deleted and no Backend uses it.
+func (r *ResolverReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	reqLogger := logf.FromContext(ctx)
+
+	// Fetch the Resolver instance
+	resolver := &externalhaproxyoperatorv1alpha1.Resolver{}
+	err := r.Get(ctx, req.NamespacedName, resolver)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			reqLogger.Info("Resolver resource not found. Ignoring since object must be deleted.")
+			return ctrl.Result{}, nil
+		}
+		reqLogger.Error(err, "Failed to get Resolver.")
+		return ctrl.Result{}, err
+	}
+
+	reqLogger.V(1).Info("Reconciling Resolver")
+
+	haproxyTransactionMu.Lock()
+	defer haproxyTransactionMu.Unlock()
+
+	// Remove the resolvers section from HAProxy when the Resolver is deleted, once no Backend uses it
+	if resolver.GetDeletionTimestamp() != nil {
+		if controllerutil.ContainsFinalizer(resolver, backendFinalizer) {
+			users, err := backendsUsingResolver(ctx, r.Client, resolver)
+			if err != nil {
+				reqLogger.Error(err, "Failed to list the Backends using the resolver")
+				return ctrl.Result{}, err
+			}
+			if len(users) > 0 {
+				// HAProxy rejects a configuration whose servers use a missing resolvers section
+				reqLogger.Info("Resolver is still used by Backends, requeueing", "backends", users, "requeueAfter", resolverInUseRequeueAfter)
+				r.setCondition(resolver, metav1.Condition{
+					Type:    "InUse",
+					Status:  metav1.ConditionTrue,
+					Reason:  "UsedByBackends",
+					Message: "The resolvers section is kept while Backends use it: " + strings.Join(users, ", "),
+				})
+				_ = r.Status().Update(ctx, resolver)
+				return ctrl.Result{RequeueAfter: resolverInUseRequeueAfter}, nil
+			}
+			if err := r.finalizeResolver(ctx, reqLogger, resolver); err != nil {
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(resolver, backendFinalizer)
+			if err := r.Update(ctx, resolver); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Add finalizer for this CR
+	if !controllerutil.ContainsFinalizer(resolver, backendFinalizer) {
+		controllerutil.AddFinalizer(resolver, backendFinalizer)
+		if err := r.Update(ctx, resolver); err != nil {
+			r.setCondition(resolver, metav1.Condition{
+				Type:    "ReconcilingComplete",
+				Status:  metav1.ConditionFalse,
+				Reason:  "AddFinalizerFailed",
+				Message: "Failed to add finalizer to Resolver",
+			})
+			_ = r.Status().Update(ctx, resolver)
+			return ctrl.Result{}, err
+		}
+	}
+
+	committed, err := r.HAProxyClient.RunTransaction(ctx, func(ctx context.Context) error {
+		return r.HAProxyClient.EnsureResolver(ctx, externalhaproxyoperatorv1alpha1.ResolverSpecToModel(resolver.Spec))
+	})
+	if err != nil {
+		return r.failHAProxyOperation(ctx, reqLogger, resolver, "Failed to apply resolver in HAProxy", err)
+	}
+	if committed.ID != "" {
+		r.Revisions.Record(ctx, "Resolver "+req.String(), committed.ReloadID)
+	}
+
+	r.setCondition(resolver, metav1.Condition{
+		Type:    "ReconcilingComplete",
+		Status:  metav1.ConditionTrue,
+		Reason:  "ReconcileCompleted",
+		Message: "Reconciliation completed successfully",
+	})
+	_ = r.Status().Update(ctx, resolver)
+
+	return ctrl.Result{}, nil
+}
+
+// failHAProxyOperation records a failed HAProxy operation as a ReconcilingComplete condition and returns the error.
+// While the Data Plane API is unavailable, the Resolver is requeued instead.
+func (r *ResolverReconciler) failHAProxyOperation(
+	ctx context.Context,
+	reqLogger logr.Logger,
+	resolver *externalhaproxyoperatorv1alpha1.Resolver,
+	message string,
+	err error,
+) (ctrl.Result, error) {
+	if haproxyclient.IsUnavailable(err) {
+		reqLogger.Info("HAProxy Data Plane API is unavailable, requeueing", "requeueAfter", haproxyUnavailableRequeueAfter)
+		r.setCondition(resolver, metav1.Condition{
+			Type:    "ReconcilingComplete",
+			Status:  metav1.ConditionFalse,
+			Reason:  "HAProxyUnavailable",
+			Message: "Waiting for the HAProxy Data Plane API to become available: " + err.Error(),
+		})
+		_ = r.Status().Update(ctx, resolver)
+		return ctrl.Result{RequeueAfter: haproxyUnavailableRequeueAfter}, nil
+	}
+
+	if isInvalidConfiguration(err) {
+		// HAProxy rejected the configuration before it was committed
+		message, own := invalidConfiguration(err, "resolvers "+resolver.Spec.Name, func(invalid haproxyclient.ErrInvalidConfiguration) string {
+			return resolverSpecField(resolver.Spec, invalid)
+		})
+		reqLogger.Info("HAProxy rejected the configuration of the resolver", "name", resolver.Spec.Name, "reason", message)
+		r.Recorder.Event(resolver, "Warning", "ValidationFailed", message)
+		r.setCondition(resolver, metav1.Condition{
+			Type:    "ReconcilingComplete",
+			Status:  metav1.ConditionFalse,
+			Reason:  "ValidationFailed",
+			Message: message,
+		})
+		_ = r.Status().Update(ctx, resolver)
+		if own {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	reqLogger.Error(err, message, "name", resolver.Spec.Name)
+	r.setCondition(resolver, metav1.Condition{
+		Type:    "ReconcilingComplete",
+		Status:  metav1.ConditionFalse,
+		Reason:  "HAProxyClientError",
+		Message: message + ": " + err.Error(),
+	})
+	_ = r.Status().Update(ctx, resolver)
+	return ctrl.Result{}, err
+}
+
+func (r *ResolverReconciler) finalizeResolver(ctx context.Context, reqLogger logr.Logger, resolver *externalhaproxyoperatorv1alpha1.Resolver) error {
+	reqLogger.Info("Finalizing Resolver", "name", resolver.Spec.Name)
+
+	if err := r.HAProxyClient.DeleteResolver(ctx, resolver.Spec.Name); err != nil {
+		monitoring.ReconcileFailuresTotal.WithLabelValues("resolver", "FinalizeFailed").Inc()
+		reqLogger.Error(err, "Failed to delete resolver from HAProxy", "name", resolver.Spec.Name)
+		return err
+	}
+	r.Revisions.Record(ctx, "Resolver "+resolver.Namespace+"/"+resolver.Name, "")
+
+	r.Recorder.Event(resolver, "Normal", "Finalized", "Successfully finalized resolver")
+	reqLogger.Info("Successfully finalized resolver")
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ResolverReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&externalhaproxyoperatorv1alpha1.Resolver{}).
+		Named("resolver").
+		WithEventFilter(predicate.Funcs{
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				oldObj, ok1 := e.ObjectOld.(*externalhaproxyoperatorv1alpha1.Resolver)
+				newObj, ok2 := e.ObjectNew.(*externalhaproxyoperatorv1alpha1.Resolver)
+				if !ok1 || !ok2 {
+					return true
+				}
+				// Ignore status-only updates
+				oldCopy := oldObj.DeepCopy()
+				newCopy := newObj.DeepCopy()
+				newCopy.ObjectMeta.ResourceVersion = oldCopy.ObjectMeta.ResourceVersion
+				oldCopy.Status = externalhaproxyoperatorv1alpha1.ResolverStatus{}
+				newCopy.Status = externalhaproxyoperatorv1alpha1.ResolverStatus{}
+				return !reflect.DeepEqual(oldCopy, newCopy)
+			},
+		}).
+		Complete(r)
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+// fakeResolverDataPlane is a Data Plane API recording the resolvers sections it deletes.
+type fakeResolverDataPlane struct {
+	mu      sync.Mutex
+	deleted []string
+}
+
+func (d *fakeResolverDataPlane) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v3/services/haproxy")
+	switch {
+	case path == "/configuration/version":
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("1"))
+	case strings.HasPrefix(path, "/configuration/resolvers/") && r.Method == http.MethodDelete:
+		d.deleted = append(d.deleted, strings.TrimPrefix(path, "/configuration/resolvers/"))
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestResolverReconciler(t *testing.T, dataPlane *fakeResolverDataPlane, objs ...client.Object) *ResolverReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := externalhaproxyoperatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(dataPlane)
+	t.Cleanup(ts.Close)
+	backend := &externalhaproxyoperatorv1alpha1.Backend{}
+	return &ResolverReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&externalhaproxyoperatorv1alpha1.Resolver{}).
+			WithIndex(backend, backendResolverIndex, indexBackendResolvers).
+			WithIndex(backend, backendResolversSectionIndex, indexBackendResolversSections).
+			Build(),
+		Scheme:        scheme,
+		Recorder:      record.NewFakeRecorder(10),
+		HAProxyClient: haproxyclient.NewHAProxyClient(haproxyclient.HAProxyConfig{BaseURL: ts.URL, Timeout: 5 * time.Second}),
+	}
+}
+
+func newDeletedTestResolver(name, section string) *externalhaproxyoperatorv1alpha1.Resolver {
+	now := metav1.Now()
+	return &externalhaproxyoperatorv1alpha1.Resolver{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              name,
+			Finalizers:        []string{backendFinalizer},
+			DeletionTimestamp: &now,
+		},
+		Spec: externalhaproxyoperatorv1alpha1.ResolverSpec{Name: section},
+	}
+}
+
+func TestResolverReconcile_KeepsResolverInUse(t *testing.T) {
+	backend := &externalhaproxyoperatorv1alpha1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "legacy"},
+		Spec: externalhaproxyoperatorv1alpha1.BackendSpec{
+			Name: "legacy",
+			Servers: externalhaproxyoperatorv1alpha1.Servers{
+				{ServerParams: externalhaproxyoperatorv1alpha1.ServerParams{ResolversRef: "corp"}, Name: "vm1", Address: "vm1.example.com"},
+			},
+		},
+	}
+	dataPlane := &fakeResolverDataPlane{}
+	r := newTestResolverReconciler(t, dataPlane, newDeletedTestResolver("corp", "corp-dns"), backend)
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "default", Name: "corp"}
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.RequeueAfter != resolverInUseRequeueAfter {
+		t.Errorf("expected a requeue after %v, got %+v", resolverInUseRequeueAfter, result)
+	}
+	if len(dataPlane.deleted) != 0 {
+		t.Errorf("expected the resolvers section in use to be kept, got %v deleted", dataPlane.deleted)
+	}
+	resolver := &externalhaproxyoperatorv1alpha1.Resolver{}
+	if err := r.Get(ctx, key, resolver); err != nil {
+		t.Fatalf("expected the Resolver to be kept: %v", err)
+	}
+	inUse := meta.FindStatusCondition(resolver.Status.Conditions, "InUse")
+	if inUse == nil || inUse.Status != metav1.ConditionTrue || !strings.Contains(inUse.Message, "default/legacy") {
+		t.Errorf("expected an InUse condition naming the Backend, got %+v", inUse)
+	}
+
+	// The section is deleted once the Backend no longer uses it
+	if err := r.Delete(ctx, backend); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(dataPlane.deleted) != 1 || dataPlane.deleted[0] != "corp-dns" {
+		t.Errorf("expected the resolvers section to be deleted, got %v", dataPlane.deleted)
+	}
+	if err := r.Get(ctx, key, resolver); !errors.IsNotFound(err) {
+		t.Errorf("expected the Resolver to be deleted, got %v", err)
+	}
+}
+
+func TestResolverReconcile_KeepsDefaultResolverOfExternalNameServers(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "legacy"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: "legacy.example.com"},
+	}
+	dataPlane := &fakeResolverDataPlane{}
+	r := newTestResolverReconciler(t, dataPlane,
+		newDeletedTestResolver("dns", defaultResolvers),
+		service,
+		newServiceRefBackend("default", "legacy", externalhaproxyoperatorv1alpha1.K8sServiceRef{Name: "legacy"}),
+	)
+
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "dns"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.RequeueAfter != resolverInUseRequeueAfter || len(dataPlane.deleted) != 0 {
+		t.Errorf("expected the default resolvers section of the ExternalName servers to be kept, got %+v and %v deleted", result, dataPlane.deleted)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sort"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+// resolveResolverRefs replaces the resolversRef of each server and server template of the modified
+// Backend with the name of the resolvers section managed by the referenced Resolver.
+func (r *BackendReconciler) resolveResolverRefs(
+	ctx context.Context,
+	reqLogger logr.Logger,
+	backend *externalhaproxyoperatorv1alpha1.Backend,
+	modifiedBackend *externalhaproxyoperatorv1alpha1.Backend,
+) error {
+	sections := map[string]string{}
+	resolve := func(params *externalhaproxyoperatorv1alpha1.ServerParams) error {
+		if params.ResolversRef == "" {
+			return nil
+		}
+		section, ok := sections[params.ResolversRef]
+		if !ok {
+			resolver := &externalhaproxyoperatorv1alpha1.Resolver{}
+			err := r.Get(ctx, client.ObjectKey{Namespace: backend.Namespace, Name: params.ResolversRef}, resolver)
+			if err != nil {
+				if errors.IsNotFound(err) {
+					err = errors.NewNotFound(externalhaproxyoperatorv1alpha1.GroupVersion.WithResource("resolvers").GroupResource(), params.ResolversRef)
+				}
+				return r.failServerResolution(ctx, reqLogger, backend, "ResolverNotFound",
+					"Referenced Resolver not found: "+params.ResolversRef, err)
+			}
+			section = resolver.Spec.Name
+			sections[params.ResolversRef] = section
+		}
+		params.Resolvers = section
+		params.ResolversRef = ""
+		return nil
+	}
+
+	for _, server := range modifiedBackend.Spec.Servers {
+		if err := resolve(&server.ServerParams); err != nil {
+			return err
+		}
+	}
+	for _, template := range modifiedBackend.Spec.ServerTemplates {
+		if err := resolve(&template.ServerParams); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func enqueueRequestsFromResolvers(ctx context.Context, obj client.Object, r *BackendReconciler) []reconcile.Request {
+	reqLogger := logf.FromContext(ctx)
+	reqLogger.V(2).Info("Processing Resolver for Backend reconciliation", "resolverName", obj.GetName(), "namespace", obj.GetNamespace())
+	return requestsForIndexedBackends(ctx, r, backendResolverIndex, objectIndexKey(obj.GetNamespace(), obj.GetName()))
+}
+
+// backendsUsingResolver returns the "namespace/name" keys of the Backends whose servers use the
+// resolvers section of the Resolver, through resolversRef, by its name, or as the default resolvers
+// section of the servers generated from ExternalName Services.
+func backendsUsingResolver(ctx context.Context, c client.Client, resolver *externalhaproxyoperatorv1alpha1.Resolver) ([]string, error) {
+	users := map[string]bool{}
+	for field, value := range map[string]string{
+		backendResolverIndex:         objectIndexKey(resolver.Namespace, resolver.Name),
+		backendResolversSectionIndex: resolver.Spec.Name,
+	} {
+		backendList := &externalhaproxyoperatorv1alpha1.BackendList{}
+		if err := c.List(ctx, backendList, client.MatchingFields{field: value}); err != nil {
+			return nil, err
+		}
+		for _, backend := range backendList.Items {
+			users[objectIndexKey(backend.Namespace, backend.Name)] = true
+		}
+	}
+
+	if resolver.Spec.Name == defaultResolvers {
+		backendList := &externalhaproxyoperatorv1alpha1.BackendList{}
+		if err := c.List(ctx, backendList); err != nil {
+			return nil, err
+		}
+		for i := range backendList.Items {
+			backend := &backendList.Items[i]
+			external, err := hasExternalNameServers(ctx, c, backend)
+			if err != nil {
+				return nil, err
+			}
+			if external {
+				users[objectIndexKey(backend.Namespace, backend.Name)] = true
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(users))
+	for key := range users {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// hasExternalNameServers returns whether servers of the Backend without resolvers are generated from
+// ExternalName Services, and so use the default resolvers section.
+func hasExternalNameServers(ctx context.Context, c client.Client, backend *externalhaproxyoperatorv1alpha1.Backend) (bool, error) {
+	for _, server := range backend.Spec.Servers {
+		if server.Resolvers != "" || server.ResolversRef != "" {
+			continue
+		}
+		namespace, name, ok := serverServiceRef(backend, server)
+		if !ok {
+			continue
+		}
+		service := &corev1.Service{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, service); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		if service.Spec.Type == corev1.ServiceTypeExternalName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
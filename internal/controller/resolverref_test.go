@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+func TestResolveResolverRefs(t *testing.T) {
+	resolver := &externalhaproxyoperatorv1alpha1.Resolver{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "corp"},
+		Spec:       externalhaproxyoperatorv1alpha1.ResolverSpec{Name: "corp-dns"},
+	}
+	backend := &externalhaproxyoperatorv1alpha1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "legacy"},
+		Spec: externalhaproxyoperatorv1alpha1.BackendSpec{
+			Servers: externalhaproxyoperatorv1alpha1.Servers{
+				{ServerParams: externalhaproxyoperatorv1alpha1.ServerParams{ResolversRef: "corp"}, Name: "vm1", Address: "vm1.example.com"},
+				{Name: "vm2", Address: "10.0.0.2"},
+			},
+			ServerTemplates: externalhaproxyoperatorv1alpha1.ServerTemplates{
+				{ServerParams: externalhaproxyoperatorv1alpha1.ServerParams{ResolversRef: "corp"}, Prefix: "www", NumOrRange: "3", Fqdn: "www.example.com"},
+			},
+		},
+	}
+	r := newIndexedTestReconciler(t, resolver, backend)
+	r.Recorder = record.NewFakeRecorder(10)
+	modified := backend.DeepCopy()
+
+	if err := r.resolveResolverRefs(context.Background(), logr.Discard(), backend, modified); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if modified.Spec.Servers[0].Resolvers != "corp-dns" || modified.Spec.Servers[0].ResolversRef != "" {
+		t.Errorf("expected server to use resolvers corp-dns, got %+v", modified.Spec.Servers[0].ServerParams)
+	}
+	if modified.Spec.Servers[1].Resolvers != "" {
+		t.Errorf("expected server without reference to be unchanged, got %+v", modified.Spec.Servers[1].ServerParams)
+	}
+	if modified.Spec.ServerTemplates[0].Resolvers != "corp-dns" {
+		t.Errorf("expected server template to use resolvers corp-dns, got %+v", modified.Spec.ServerTemplates[0].ServerParams)
+	}
+
+	got := requestNames(enqueueRequestsFromResolvers(context.Background(), resolver, r))
+	if len(got) != 1 || got[0] != "default/legacy" {
+		t.Errorf("expected the referencing Backend to be enqueued, got %v", got)
+	}
+
+	backend.Spec.Servers[0].ResolversRef = "missing"
+	if err := r.resolveResolverRefs(context.Background(), logr.Discard(), backend, backend.DeepCopy()); err == nil {
+		t.Error("expected error for a missing Resolver")
+	}
+}
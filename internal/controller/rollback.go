@@ -0,0 +1,189 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/haproxytech/client-native/v6/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+// rollbackState is the state kept to roll back the last changes to a Backend when HAProxy fails to
+// reload with them. It is only kept in memory: after the operator restarts, a reload failing is no
+// longer rolled back, and the changes rolled back are applied again.
+type rollbackState struct {
+	// reloadID is the ID of the reload of the changes
+	reloadID string
+	// snapshot is the configuration from before the changes, if it could be taken
+	snapshot *haproxyclient.ConfigSnapshot
+	// fingerprint is the fingerprint of the configuration of the changes
+	fingerprint uint64
+	// rolledBack is set once the changes were rolled back, so that they are not applied again
+	rolledBack bool
+}
+
+// rememberSnapshot keeps the snapshot of the configuration from before changes to the Backend whose
+// reload is pending, with the fingerprint of the changes. Callers must hold haproxyTransactionMu.
+func (r *BackendReconciler) rememberSnapshot(backend *externalhaproxyoperatorv1alpha1.Backend, committed haproxyclient.Transaction, fingerprint uint64) {
+	if r.rollbacks == nil {
+		r.rollbacks = make(map[types.NamespacedName]*rollbackState)
+	}
+	key := types.NamespacedName{Namespace: backend.Namespace, Name: backend.Name}
+	r.rollbacks[key] = &rollbackState{reloadID: committed.ReloadID, snapshot: committed.Snapshot, fingerprint: fingerprint}
+}
+
+// forgetSnapshot drops the snapshot kept for changes to the Backend once their reload completed.
+// Callers must hold haproxyTransactionMu.
+func (r *BackendReconciler) forgetSnapshot(backend *externalhaproxyoperatorv1alpha1.Backend) {
+	key := types.NamespacedName{Namespace: backend.Namespace, Name: backend.Name}
+	if state := r.rollbacks[key]; state != nil && !state.rolledBack {
+		delete(r.rollbacks, key)
+	}
+}
+
+// rolledBack reports whether the configuration of the Backend is the one that was rolled back, and
+// must not be applied again until it changes. Once it changes, the RolledBack condition is cleared.
+// Callers must hold haproxyTransactionMu.
+func (r *BackendReconciler) rolledBack(backend *externalhaproxyoperatorv1alpha1.Backend, fingerprint uint64) bool {
+	key := types.NamespacedName{Namespace: backend.Namespace, Name: backend.Name}
+	state := r.rollbacks[key]
+	if state == nil || !state.rolledBack {
+		if state == nil && meta.IsStatusConditionTrue(backend.Status.Conditions, "RolledBack") {
+			// Rolled back before the operator restarted, the changes are applied again
+			meta.RemoveStatusCondition(&backend.Status.Conditions, "RolledBack")
+		}
+		return false
+	}
+	if state.fingerprint == fingerprint {
+		return true
+	}
+	delete(r.rollbacks, key)
+	meta.RemoveStatusCondition(&backend.Status.Conditions, "RolledBack")
+	return false
+}
+
+// rollBack restores the backend from before the changes to the Backend that HAProxy failed to reload
+// with, and reports it in the RolledBack condition with the output of HAProxy. Only the section of
+// the backend is restored, the changes committed since by other resources are kept. The reload of
+// the rollback replaces the failed one in the status of the Backend. It returns false if there is
+// no snapshot to restore, such as after the operator restarted, or restoring it failed. Callers
+// must hold haproxyTransactionMu.
+func (r *BackendReconciler) rollBack(ctx context.Context, reqLogger logr.Logger, backend *externalhaproxyoperatorv1alpha1.Backend, reload *models.Reload) bool {
+	key := types.NamespacedName{Namespace: backend.Namespace, Name: backend.Name}
+	state := r.rollbacks[key]
+	if state == nil || state.snapshot == nil || state.rolledBack || state.reloadID != backend.Status.ReloadID {
+		return false
+	}
+
+	restoreID, err := r.HAProxyClient.RestoreBackend(ctx, state.snapshot, backend.Spec.Name)
+	if err != nil {
+		// The rollback is attempted once, the failed reload is reported otherwise
+		delete(r.rollbacks, key)
+		reqLogger.Error(err, "Failed to roll back the HAProxy configuration", "reloadID", reload.ID)
+		r.setCondition(backend, metav1.Condition{
+			Type:    "RolledBack",
+			Status:  metav1.ConditionFalse,
+			Reason:  "RollbackFailed",
+			Message: "Failed to restore the backend from before reload " + reload.ID + ": " + err.Error(),
+		})
+		return false
+	}
+
+	state.rolledBack = true
+	backend.Status.ReloadID = restoreID
+	monitoring.BackendRollbacksTotal.With(prometheus.Labels{
+		"namespace": backend.Namespace, "name": backend.Name, "backend": backend.Spec.Name,
+	}).Inc()
+	message := "Restored the backend from before reload " + reload.ID + ", which HAProxy failed with: " + reload.Response
+	reqLogger.Info("Rolled back the HAProxy configuration", "reloadID", reload.ID, "restoreReloadID", restoreID)
+	r.Recorder.Event(backend, "Warning", "RolledBack", message)
+	r.setCondition(backend, metav1.Condition{
+		Type:    "RolledBack",
+		Status:  metav1.ConditionTrue,
+		Reason:  "ReloadFailed",
+		Message: message,
+	})
+	return true
+}
+
+// checkRollbackReload checks the HAProxy reload of the rollback of the changes to the Backend, and
+// reports it in the Reloaded condition, and in the RolledBack condition when it failed. It returns
+// the result requeueing the Backend while the reload is pending. A failed reload is kept until the
+// next changes are committed, as HAProxy runs the failed configuration until then. Callers must hold
+// haproxyTransactionMu.
+func (r *BackendReconciler) checkRollbackReload(ctx context.Context, reqLogger logr.Logger, backend *externalhaproxyoperatorv1alpha1.Backend) ctrl.Result {
+	id := backend.Status.ReloadID
+	reload, err := r.HAProxyClient.GetReload(ctx, id)
+	switch {
+	case err != nil:
+		reqLogger.Error(err, "Failed to get the HAProxy reload of the rollback", "reloadID", id)
+		return ctrl.Result{RequeueAfter: reloadPollInterval}
+	case reload == nil:
+		r.setCondition(backend, metav1.Condition{
+			Type:    "Reloaded",
+			Status:  metav1.ConditionUnknown,
+			Reason:  "ReloadUnknown",
+			Message: "The HAProxy Data Plane API no longer knows reload " + id + " of the rollback",
+		})
+	case reload.Status == haproxyclient.ReloadSucceeded:
+		r.setCondition(backend, metav1.Condition{
+			Type:    "Reloaded",
+			Status:  metav1.ConditionTrue,
+			Reason:  "Reloaded",
+			Message: "HAProxy reloaded with the rollback in reload " + id,
+		})
+	case reload.Status == haproxyclient.ReloadFailed:
+		message := "HAProxy failed to reload with the rollback in reload " + id + ": " + reload.Response
+		if previous := meta.FindStatusCondition(backend.Status.Conditions, "RolledBack"); previous == nil || previous.Reason != "RollbackReloadFailed" {
+			reqLogger.Info("HAProxy failed to reload with the rollback", "reloadID", id, "response", reload.Response)
+			r.Recorder.Event(backend, "Warning", "RollbackFailed", message)
+		}
+		r.setCondition(backend, metav1.Condition{
+			Type:    "Reloaded",
+			Status:  metav1.ConditionFalse,
+			Reason:  "ReloadFailed",
+			Message: message,
+		})
+		r.setCondition(backend, metav1.Condition{
+			Type:    "RolledBack",
+			Status:  metav1.ConditionFalse,
+			Reason:  "RollbackReloadFailed",
+			Message: message,
+		})
+		return ctrl.Result{}
+	default:
+		r.setCondition(backend, metav1.Condition{
+			Type:    "Reloaded",
+			Status:  metav1.ConditionUnknown,
+			Reason:  "ReloadPending",
+			Message: "Waiting for HAProxy to reload with the rollback in reload " + id,
+		})
+		return ctrl.Result{RequeueAfter: reloadPollInterval}
+	}
+	backend.Status.ReloadID = ""
+	return ctrl.Result{}
+}
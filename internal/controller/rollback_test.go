@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+func TestCheckReload_RollsBack(t *testing.T) {
+	var restored string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v3/services/haproxy/reloads/1-2":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"1-2","status":"failed","response":"[ALERT] cannot bind socket"}`)
+		case r.URL.Path == "/v3/services/haproxy/reloads/1-3":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"1-3","status":"failed","response":"[ALERT] cannot bind socket"}`)
+		case r.URL.Path == "/v3/services/haproxy/configuration/raw" && r.Method == http.MethodGet:
+			// Another backend was added since the changes
+			w.Header().Set("Configuration-Version", "10")
+			fmt.Fprint(w, "global\n  daemon\nbackend web\n  balance source\nbackend api\n  balance roundrobin\n")
+		case r.URL.Path == "/v3/services/haproxy/configuration/raw" && r.URL.Query().Get("version") == "10":
+			body, _ := io.ReadAll(r.Body)
+			restored = string(body)
+			w.Header().Set("Reload-ID", "1-3")
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	r := &BackendReconciler{
+		Recorder:      record.NewFakeRecorder(10),
+		HAProxyClient: haproxyclient.NewHAProxyClient(haproxyclient.HAProxyConfig{BaseURL: ts.URL, Timeout: 5 * time.Second}),
+	}
+	backend := &externalhaproxyoperatorv1alpha1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+		Spec:       externalhaproxyoperatorv1alpha1.BackendSpec{Name: "web"},
+		Status:     externalhaproxyoperatorv1alpha1.BackendStatus{ReloadID: "1-2"},
+	}
+	r.rememberSnapshot(backend, haproxyclient.Transaction{
+		ReloadID: "1-2",
+		Snapshot: &haproxyclient.ConfigSnapshot{Version: 8, Raw: "global\n  daemon\nbackend web\n  balance roundrobin\n"},
+	}, 42)
+
+	result, done := r.checkReload(context.Background(), logr.Discard(), backend)
+	if done || result.RequeueAfter != reloadPollInterval {
+		t.Errorf("expected the reload of the rollback to be checked next, got %+v, %v", result, done)
+	}
+	if want := "global\n  daemon\nbackend web\n  balance roundrobin\nbackend api\n  balance roundrobin\n"; restored != want {
+		t.Errorf("expected only the backend to be restored, got %q", restored)
+	}
+	condition := meta.FindStatusCondition(backend.Status.Conditions, "RolledBack")
+	if condition == nil || condition.Status != metav1.ConditionTrue ||
+		condition.Message != "Restored the backend from before reload 1-2, which HAProxy failed with: [ALERT] cannot bind socket" {
+		t.Errorf("expected the backend to be rolled back with the HAProxy output, got %+v", condition)
+	}
+	if backend.Status.ReloadID != "1-3" || conditionStatus(backend, "ReconcilingComplete") != metav1.ConditionFalse {
+		t.Errorf("expected the failed reload to be replaced by the rollback, got %+v", backend.Status)
+	}
+
+	// The rollback failing to reload is reported
+	if result := r.checkRollbackReload(context.Background(), logr.Discard(), backend); result.RequeueAfter != 0 {
+		t.Errorf("expected the failed reload of the rollback not to be checked again, got %+v", result)
+	}
+	condition = meta.FindStatusCondition(backend.Status.Conditions, "RolledBack")
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != "RollbackReloadFailed" ||
+		condition.Message != "HAProxy failed to reload with the rollback in reload 1-3: [ALERT] cannot bind socket" {
+		t.Errorf("expected the rollback to have failed with the HAProxy output, got %+v", condition)
+	}
+	if backend.Status.ReloadID != "1-3" {
+		t.Errorf("expected the failed reload of the rollback to be kept, got %q", backend.Status.ReloadID)
+	}
+
+	// The changes are not applied again until they change
+	if !r.rolledBack(backend, 42) {
+		t.Error("expected the rolled back configuration not to be applied again")
+	}
+	if r.rolledBack(backend, 43) || conditionStatus(backend, "RolledBack") != "" {
+		t.Errorf("expected a new configuration to be applied, got %+v", backend.Status.Conditions)
+	}
+}
+
+func TestRolledBack_ClearedAfterRestart(t *testing.T) {
+	r := &BackendReconciler{}
+	backend := &externalhaproxyoperatorv1alpha1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+		Status: externalhaproxyoperatorv1alpha1.BackendStatus{Conditions: []metav1.Condition{
+			{Type: "RolledBack", Status: metav1.ConditionTrue, Reason: "ReloadFailed"},
+		}},
+	}
+
+	if r.rolledBack(backend, 42) || conditionStatus(backend, "RolledBack") != "" {
+		t.Errorf("expected the changes to be applied again without a rollback state, got %+v", backend.Status.Conditions)
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/haproxytech/client-native/v6/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+// serverStatusInterval is how often the live state of the servers of a Backend is refreshed.
+const serverStatusInterval = 30 * time.Second
+
+// ServerStatusMonitor periodically publishes the live state of the servers of the Backends from the
+// HAProxy stats. Reading the stats needs no transaction, so the Backends are not reconciled for it
+// and the changes of the reconcilers are not held up.
+type ServerStatusMonitor struct {
+	Client        client.Client
+	HAProxyClient haproxyclient.HAProxyClient
+}
+
+// Start refreshes the status of the Backends until the context is cancelled. The reconciler
+// publishes it after each change.
+func (m *ServerStatusMonitor) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx).WithName("server-status")
+	ticker := time.NewTicker(serverStatusInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := m.refresh(ctx, logger); err != nil {
+			logger.Error(err, "Failed to refresh the server status of Backends")
+		}
+	}
+}
+
+// NeedLeaderElection makes only the leader update the status of the Backends.
+func (m *ServerStatusMonitor) NeedLeaderElection() bool {
+	return true
+}
+
+// refresh updates the server status of the Backends whose servers changed state. A Backend updated
+// by its reconciler in the meantime is refreshed on the next tick.
+func (m *ServerStatusMonitor) refresh(ctx context.Context, logger logr.Logger) error {
+	backends := &externalhaproxyoperatorv1alpha1.BackendList{}
+	if err := m.Client.List(ctx, backends); err != nil {
+		return err
+	}
+	for i := range backends.Items {
+		backend := &backends.Items[i]
+		if backend.GetDeletionTimestamp() != nil {
+			continue
+		}
+		previous := backend.Status.DeepCopy()
+		updateServerStatus(ctx, logger, m.HAProxyClient, backend)
+		if equality.Semantic.DeepEqual(previous, &backend.Status) {
+			continue
+		}
+		if err := m.Client.Status().Update(ctx, backend); err != nil && !errors.IsConflict(err) && !errors.IsNotFound(err) {
+			logger.Error(err, "Failed to update the server status of Backend", "namespace", backend.Namespace, "name", backend.Name)
+		}
+	}
+	return nil
+}
+
+// updateServerStatus publishes the live state of the servers of the Backend from the HAProxy stats,
+// and derives the Available and Degraded conditions from it.
+func updateServerStatus(ctx context.Context, reqLogger logr.Logger, haproxy haproxyclient.HAProxyClient, backend *externalhaproxyoperatorv1alpha1.Backend) {
+	stats, err := haproxy.ListServerStats(ctx, backend.Spec.Name)
+	if err != nil {
+		reqLogger.Error(err, "Failed to get server stats from HAProxy", "name", backend.Spec.Name)
+		meta.SetStatusCondition(&backend.Status.Conditions, metav1.Condition{
+			Type:    "Available",
+			Status:  metav1.ConditionUnknown,
+			Reason:  "StatsUnavailable",
+			Message: "Failed to get server stats from HAProxy: " + err.Error(),
+		})
+		monitoring.DeleteBackendMetrics(backend.Namespace, backend.Name)
+		return
+	}
+
+	backend.Status.Servers = serverStatuses(stats)
+	backend.Status.TotalServers = int64(len(backend.Status.Servers))
+	backend.Status.UpServers = 0
+	down := 0
+	for _, server := range backend.Status.Servers {
+		switch server.State {
+		case externalhaproxyoperatorv1alpha1.ServerStateUp:
+			backend.Status.UpServers++
+		case externalhaproxyoperatorv1alpha1.ServerStateDown:
+			down++
+		}
+	}
+
+	available := metav1.Condition{
+		Type:    "Available",
+		Status:  metav1.ConditionTrue,
+		Reason:  "ServersAvailable",
+		Message: fmt.Sprintf("%d of %d servers are UP", backend.Status.UpServers, backend.Status.TotalServers),
+	}
+	if backend.Status.TotalServers == 0 {
+		available.Status, available.Reason, available.Message = metav1.ConditionFalse, "NoServers", "HAProxy reports no servers for the backend"
+	} else if backend.Status.UpServers == 0 {
+		available.Status, available.Reason = metav1.ConditionFalse, "NoServersAvailable"
+	}
+	meta.SetStatusCondition(&backend.Status.Conditions, available)
+
+	// Servers in maintenance or draining are taken out on purpose and do not degrade the backend
+	degraded := metav1.Condition{
+		Type:    "Degraded",
+		Status:  metav1.ConditionFalse,
+		Reason:  "NoServersDown",
+		Message: "No servers are DOWN",
+	}
+	if down > 0 {
+		degraded.Status, degraded.Reason = metav1.ConditionTrue, "ServersDown"
+		degraded.Message = fmt.Sprintf("%d of %d servers are DOWN", down, backend.Status.TotalServers)
+	}
+	meta.SetStatusCondition(&backend.Status.Conditions, degraded)
+
+	recordServerMetrics(backend, stats)
+}
+
+// recordServerMetrics exports the stats of the servers as gauges labelled by the Backend resource.
+// The gauges of removed servers and renamed HAProxy backends are then removed, those of the other
+// servers are updated in place.
+func recordServerMetrics(backend *externalhaproxyoperatorv1alpha1.Backend, stats []*models.NativeStat) {
+	backendLabels := prometheus.Labels{"namespace": backend.Namespace, "name": backend.Name, "backend": backend.Spec.Name}
+	monitoring.BackendServers.With(backendLabels).Set(float64(backend.Status.TotalServers))
+	monitoring.BackendUpServers.With(backendLabels).Set(float64(backend.Status.UpServers))
+
+	servers := make([]string, 0, len(stats))
+	for _, stat := range stats {
+		s := stat.Stats
+		if s == nil {
+			continue
+		}
+		servers = append(servers, stat.Name)
+		labels := prometheus.Labels{"namespace": backend.Namespace, "name": backend.Name, "backend": backend.Spec.Name, "server": stat.Name}
+		up := 0.0
+		if haproxyclient.ServerState(s.Status) == string(externalhaproxyoperatorv1alpha1.ServerStateUp) {
+			up = 1
+		}
+		monitoring.ServerUp.With(labels).Set(up)
+		setGauge(monitoring.ServerCurrentSessions, labels, s.Scur, 1)
+		setGauge(monitoring.ServerCurrentQueue, labels, s.Qcur, 1)
+		// HAProxy reports the average times in milliseconds
+		setGauge(monitoring.ServerQueueTimeAverage, labels, s.Qtime, 0.001)
+		setGauge(monitoring.ServerConnectTimeAverage, labels, s.Ctime, 0.001)
+		setGauge(monitoring.ServerResponseTimeAverage, labels, s.Rtime, 0.001)
+		setGauge(monitoring.ServerTotalTimeAverage, labels, s.Ttime, 0.001)
+		setGauge(monitoring.ServerHTTPResponses5xxTotal, labels, s.Hrsp5xx, 1)
+		setGauge(monitoring.ServerCheckFailures, labels, s.ChkFail, 1)
+	}
+	monitoring.DeleteVanishedServerMetrics(backend.Namespace, backend.Name, backend.Spec.Name, servers)
+}
+
+// setGauge sets the gauge to the scaled value, if HAProxy reported one.
+func setGauge(gauge *prometheus.GaugeVec, labels prometheus.Labels, value *int64, scale float64) {
+	if value != nil {
+		gauge.With(labels).Set(float64(*value) * scale)
+	}
+}
+
+// serverStatuses converts the native stats of servers to their status, sorted by name.
+func serverStatuses(stats []*models.NativeStat) []externalhaproxyoperatorv1alpha1.ServerStatus {
+	servers := make([]externalhaproxyoperatorv1alpha1.ServerStatus, 0, len(stats))
+	for _, stat := range stats {
+		server := externalhaproxyoperatorv1alpha1.ServerStatus{Name: stat.Name}
+		if s := stat.Stats; s != nil {
+			server.State = externalhaproxyoperatorv1alpha1.ServerState(haproxyclient.ServerState(s.Status))
+			server.CheckStatus = s.CheckStatus
+			if s.LastChk != nil {
+				server.LastCheck = *s.LastChk
+			}
+			if s.Scur != nil {
+				server.CurrentSessions = *s.Scur
+			}
+			if s.Weight != nil {
+				server.Weight = *s.Weight
+			}
+		}
+		servers = append(servers, server)
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Name < servers[j].Name })
+	return servers
+}
@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/haproxytech/client-native/v6/models"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+func newStatsTestReconciler(t *testing.T, servers string) *BackendReconciler {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"stats":[{"stats":[`+servers+`]}]}`)
+	}))
+	t.Cleanup(ts.Close)
+	return &BackendReconciler{
+		HAProxyClient: haproxyclient.NewHAProxyClient(haproxyclient.HAProxyConfig{BaseURL: ts.URL, Timeout: 5 * time.Second}),
+	}
+}
+
+func conditionStatus(backend *externalhaproxyoperatorv1alpha1.Backend, conditionType string) metav1.ConditionStatus {
+	condition := meta.FindStatusCondition(backend.Status.Conditions, conditionType)
+	if condition == nil {
+		return ""
+	}
+	return condition.Status
+}
+
+func TestUpdateServerStatus(t *testing.T) {
+	r := newStatsTestReconciler(t,
+		`{"type":"server","name":"web-1","backend_name":"web","stats":{"status":"DOWN","check_status":"L4CON","last_chk":"Connection refused"}},`+
+			`{"type":"server","name":"web-0","backend_name":"web","stats":{"status":"UP","check_status":"L7OK","scur":4,"weight":100}},`+
+			`{"type":"server","name":"web-2","backend_name":"web","stats":{"status":"MAINT"}}`)
+	backend := &externalhaproxyoperatorv1alpha1.Backend{Spec: externalhaproxyoperatorv1alpha1.BackendSpec{Name: "web"}}
+
+	updateServerStatus(context.Background(), logr.Discard(), r.HAProxyClient, backend)
+
+	if backend.Status.UpServers != 1 || backend.Status.TotalServers != 3 {
+		t.Errorf("expected 1 of 3 servers UP, got %d of %d", backend.Status.UpServers, backend.Status.TotalServers)
+	}
+	want := externalhaproxyoperatorv1alpha1.ServerStatus{Name: "web-0", State: "UP", CheckStatus: "L7OK", CurrentSessions: 4, Weight: 100}
+	if backend.Status.Servers[0] != want {
+		t.Errorf("expected servers sorted by name starting with %+v, got %+v", want, backend.Status.Servers[0])
+	}
+	if backend.Status.Servers[1].LastCheck != "Connection refused" {
+		t.Errorf("expected the last check of web-1, got %+v", backend.Status.Servers[1])
+	}
+	if conditionStatus(backend, "Available") != metav1.ConditionTrue || conditionStatus(backend, "Degraded") != metav1.ConditionTrue {
+		t.Errorf("expected the backend to be available and degraded, got %+v", backend.Status.Conditions)
+	}
+}
+
+func TestUpdateServerStatus_Maintenance(t *testing.T) {
+	r := newStatsTestReconciler(t, `{"type":"server","name":"web-0","backend_name":"web","stats":{"status":"MAINT"}}`)
+	backend := &externalhaproxyoperatorv1alpha1.Backend{Spec: externalhaproxyoperatorv1alpha1.BackendSpec{Name: "web"}}
+
+	updateServerStatus(context.Background(), logr.Discard(), r.HAProxyClient, backend)
+
+	if conditionStatus(backend, "Available") != metav1.ConditionFalse || conditionStatus(backend, "Degraded") != metav1.ConditionFalse {
+		t.Errorf("expected the backend to be unavailable but not degraded, got %+v", backend.Status.Conditions)
+	}
+}
+
+func TestUpdateServerStatus_Metrics(t *testing.T) {
+	r := newStatsTestReconciler(t,
+		`{"type":"server","name":"web-0","backend_name":"web","stats":{"status":"UP","scur":4,"rtime":25,"hrsp_5xx":7}},`+
+			`{"type":"server","name":"web-1","backend_name":"web","stats":{"status":"DOWN","chkfail":3}}`)
+	backend := &externalhaproxyoperatorv1alpha1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-metrics"},
+		Spec:       externalhaproxyoperatorv1alpha1.BackendSpec{Name: "web"},
+	}
+	t.Cleanup(func() { monitoring.DeleteBackendMetrics("shop", "web-metrics") })
+
+	updateServerStatus(context.Background(), logr.Discard(), r.HAProxyClient, backend)
+
+	web0 := []string{"shop", "web-metrics", "web", "web-0"}
+	web1 := []string{"shop", "web-metrics", "web", "web-1"}
+	if got := testutil.ToFloat64(monitoring.ServerUp.WithLabelValues(web0...)); got != 1 {
+		t.Errorf("expected web-0 to be up, got %v", got)
+	}
+	if got := testutil.ToFloat64(monitoring.ServerUp.WithLabelValues(web1...)); got != 0 {
+		t.Errorf("expected web-1 to be down, got %v", got)
+	}
+	if got := testutil.ToFloat64(monitoring.ServerResponseTimeAverage.WithLabelValues(web0...)); got != 0.025 {
+		t.Errorf("expected a response time of 25ms, got %vs", got)
+	}
+	if got := testutil.ToFloat64(monitoring.ServerHTTPResponses5xxTotal.WithLabelValues(web0...)); got != 7 {
+		t.Errorf("expected 7 5xx responses, got %v", got)
+	}
+	if got := testutil.ToFloat64(monitoring.ServerCheckFailures.WithLabelValues(web1...)); got != 3 {
+		t.Errorf("expected 3 check failures, got %v", got)
+	}
+	if got := testutil.ToFloat64(monitoring.BackendUpServers.WithLabelValues("shop", "web-metrics", "web")); got != 1 {
+		t.Errorf("expected 1 server up, got %v", got)
+	}
+
+	// Only the gauges of the servers that are gone are removed
+	recordServerMetrics(backend, []*models.NativeStat{{Name: "web-0", Stats: &models.NativeStatStats{Status: "UP"}}})
+	if got := testutil.ToFloat64(monitoring.ServerHTTPResponses5xxTotal.WithLabelValues(web0...)); got != 7 {
+		t.Errorf("expected the 5xx responses of web-0 to be kept, got %v", got)
+	}
+	if monitoring.ServerUp.DeleteLabelValues(web1...) {
+		t.Error("expected the gauges of web-1 to be removed")
+	}
+
+	monitoring.DeleteBackendMetrics("shop", "web-metrics")
+	if monitoring.ServerUp.DeleteLabelValues(web0...) || monitoring.BackendServers.DeleteLabelValues("shop", "web-metrics", "web") {
+		t.Error("expected the gauges of the Backend to be removed")
+	}
+}
+
+func TestServerStatusMonitor(t *testing.T) {
+	r := newStatsTestReconciler(t, `{"type":"server","name":"web-0","backend_name":"web","stats":{"status":"UP"}}`)
+	scheme := runtime.NewScheme()
+	if err := externalhaproxyoperatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	backend := &externalhaproxyoperatorv1alpha1.Backend{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-monitor"},
+		Spec:       externalhaproxyoperatorv1alpha1.BackendSpec{Name: "web"},
+	}
+	t.Cleanup(func() { monitoring.DeleteBackendMetrics("shop", "web-monitor") })
+	monitor := &ServerStatusMonitor{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(backend).
+			WithStatusSubresource(&externalhaproxyoperatorv1alpha1.Backend{}).
+			Build(),
+		HAProxyClient: r.HAProxyClient,
+	}
+
+	if err := monitor.refresh(context.Background(), logr.Discard()); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := &externalhaproxyoperatorv1alpha1.Backend{}
+	if err := monitor.Client.Get(context.Background(), types.NamespacedName{Namespace: "shop", Name: "web-monitor"}, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.UpServers != 1 || conditionStatus(updated, "Available") != metav1.ConditionTrue {
+		t.Errorf("expected the status of the servers to be published, got %+v", updated.Status)
+	}
+	if updated.Generation != backend.Generation {
+		t.Errorf("expected only the status to be updated, got generation %d", updated.Generation)
+	}
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+const (
+	// defaultResolvers is the resolvers section used for servers addressed by DNS name.
+	defaultResolvers = "default"
+	// defaultExternalNameInitAddr lets HAProxy start even when the DNS name does not resolve yet.
+	defaultExternalNameInitAddr = "last,libc,none"
+)
+
+// resolvedEndpoint is a ready endpoint of a Service, as read from its EndpointSlices.
+type resolvedEndpoint struct {
+	address  string
+	nodeName string
+	podName  string
+	ports    []discoveryv1.EndpointPort
+
+	// nameSuffix tells apart the servers of a pod that is used with both address families.
+	nameSuffix string
+
+	// zone and forZones are the zone of the endpoint and the zones it is hinted to serve.
+	zone     string
+	forZones []string
+}
+
+// name returns the server name used for the endpoint in HAProxy.
+func (e resolvedEndpoint) name() string {
+	if e.podName != "" {
+		return e.podName + e.nameSuffix
+	}
+	if e.nodeName != "" {
+		return e.nodeName + e.nameSuffix
+	}
+	return addressName(e.address) + e.nameSuffix
+}
+
+// resolveServicePort finds the Service port referenced by ref, which may be a port name or number.
+// When ref is empty, the only port of the Service is used, or the port matching fallback if the
+// Service exposes several. A nil port without error means the Service port could not be
+// determined and the server's own port should be used instead, which fallback must then set.
+func resolveServicePort(service *corev1.Service, ref string, fallback *int64) (*corev1.ServicePort, error) {
+	if ref != "" {
+		number, numErr := strconv.ParseInt(ref, 10, 32)
+		for i := range service.Spec.Ports {
+			port := &service.Spec.Ports[i]
+			if port.Name == ref || (numErr == nil && int64(port.Port) == number) {
+				return port, nil
+			}
+		}
+		return nil, fmt.Errorf("port %q not found in Service %s/%s", ref, service.Namespace, service.Name)
+	}
+
+	if len(service.Spec.Ports) == 1 {
+		return &service.Spec.Ports[0], nil
+	}
+	if fallback != nil {
+		for i := range service.Spec.Ports {
+			if int64(service.Spec.Ports[i].Port) == *fallback {
+				return &service.Spec.Ports[i], nil
+			}
+		}
+		return nil, nil
+	}
+	if len(service.Spec.Ports) > 1 {
+		return nil, fmt.Errorf("the Service %s/%s exposes several ports, the port of the serviceRef or of the server must select one",
+			service.Namespace, service.Name)
+	}
+	return nil, nil
+}
+
+// endpointPort returns the port an endpoint serves the given Service port on. EndpointSlice ports
+// carry the name of the Service port they were resolved from, so they are matched by name.
+func endpointPort(endpoint resolvedEndpoint, servicePort *corev1.ServicePort) *int64 {
+	for _, port := range endpoint.ports {
+		if port.Port == nil {
+			continue
+		}
+		name := ""
+		if port.Name != nil {
+			name = *port.Name
+		}
+		if name == servicePort.Name {
+			p := int64(*port.Port)
+			return &p
+		}
+	}
+	return nil
+}
+
+// readyEndpoints returns the endpoints of the given EndpointSlices, skipping those where the pod
+// is terminating, not running, or not ready. Endpoints without a pod, such as those in
+// EndpointSlices managed by hand, are used unless their ready condition is false.
+func (r *BackendReconciler) readyEndpoints(ctx context.Context, reqLogger logr.Logger, slices []discoveryv1.EndpointSlice) []resolvedEndpoint {
+	var endpoints []resolvedEndpoint
+	for _, endpointSlice := range slices {
+		for _, endpoint := range endpointSlice.Endpoints {
+			if len(endpoint.Addresses) == 0 {
+				continue
+			}
+			resolved := resolvedEndpoint{
+				address: endpoint.Addresses[0],
+				ports:   endpointSlice.Ports,
+			}
+			if endpoint.NodeName != nil {
+				resolved.nodeName = *endpoint.NodeName
+			}
+			if endpoint.Zone != nil {
+				resolved.zone = *endpoint.Zone
+			}
+			if endpoint.Hints != nil {
+				for _, hint := range endpoint.Hints.ForZones {
+					resolved.forZones = append(resolved.forZones, hint.Name)
+				}
+			}
+			if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
+				resolved.podName = endpoint.TargetRef.Name
+				pod := &corev1.Pod{}
+				err := r.Get(ctx, client.ObjectKey{Namespace: endpoint.TargetRef.Namespace, Name: endpoint.TargetRef.Name}, pod)
+				if err == nil && !podReady(reqLogger, pod) {
+					continue
+				}
+			} else if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				reqLogger.V(2).Info("Skipping non-ready endpoint", "address", resolved.address, "endpointSlice", endpointSlice.Name)
+				continue
+			}
+			endpoints = append(endpoints, resolved)
+		}
+	}
+	return endpoints
+}
+
+// podReady reports whether a pod is running, ready and not terminating.
+func podReady(reqLogger logr.Logger, pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		reqLogger.V(2).Info("Skipping endpoint for terminating pod", "podName", pod.Name, "namespace", pod.Namespace)
+		return false
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		reqLogger.V(2).Info("Skipping endpoint for non-running pod", "podName", pod.Name, "namespace", pod.Namespace, "podPhase", pod.Status.Phase)
+		return false
+	}
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	reqLogger.V(2).Info("Skipping endpoint for non-ready pod", "podName", pod.Name, "namespace", pod.Namespace)
+	return false
+}
+
+// podIPServers creates a server for each endpoint, addressed by the endpoint IP and the port the
+// endpoint serves the Service port on. Without a resolvable Service port the parent server's port is
+// used, and an error is returned if it has none either.
+func podIPServers(endpoints []resolvedEndpoint, servicePort *corev1.ServicePort, parent *externalhaproxyoperatorv1alpha1.Server) (externalhaproxyoperatorv1alpha1.Servers, error) {
+	servers := make(externalhaproxyoperatorv1alpha1.Servers, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		port := parent.Port
+		if servicePort != nil {
+			if p := endpointPort(endpoint, servicePort); p != nil {
+				port = p
+			}
+		}
+		if port == nil {
+			return nil, fmt.Errorf("endpoint %s serves no port of the Service, a port must be set on the server", endpoint.address)
+		}
+		servers = append(servers, generatedServer(parent, endpoint.name(), endpoint.address, port))
+	}
+	return servers, nil
+}
+
+// nodePortServers creates a server for each node hosting one of the endpoints, addressed by the
+// node IP of the given family and the Service NodePort.
+func (r *BackendReconciler) nodePortServers(
+	ctx context.Context,
+	endpoints []resolvedEndpoint,
+	servicePort *corev1.ServicePort,
+	parent *externalhaproxyoperatorv1alpha1.Server,
+	family externalhaproxyoperatorv1alpha1.AddressFamily,
+) (externalhaproxyoperatorv1alpha1.Servers, error) {
+	if servicePort == nil || servicePort.NodePort == 0 {
+		return nil, fmt.Errorf("the referenced Service port has no NodePort")
+	}
+	port := int64(servicePort.NodePort)
+
+	seen := map[string]bool{}
+	var servers externalhaproxyoperatorv1alpha1.Servers
+	for _, endpoint := range endpoints {
+		if endpoint.nodeName == "" || seen[endpoint.nodeName] {
+			continue
+		}
+		seen[endpoint.nodeName] = true
+
+		node := &corev1.Node{}
+		if err := r.Get(ctx, client.ObjectKey{Name: endpoint.nodeName}, node); err != nil {
+			return nil, fmt.Errorf("getting node %s: %w", endpoint.nodeName, err)
+		}
+		addresses := nodeAddresses(node, corev1.NodeInternalIP)
+		if len(addresses) == 0 {
+			addresses = nodeAddresses(node, corev1.NodeExternalIP)
+		}
+		for _, selected := range selectAddresses(addresses, family) {
+			servers = append(servers, generatedServer(parent, node.Name+selected.nameSuffix, selected.address, &port))
+		}
+	}
+	return servers, nil
+}
+
+// loadBalancerServers creates a server for each load balancer ingress of the Service of the given
+// family, addressed on the Service port.
+func loadBalancerServers(
+	service *corev1.Service,
+	servicePort *corev1.ServicePort,
+	parent *externalhaproxyoperatorv1alpha1.Server,
+	family externalhaproxyoperatorv1alpha1.AddressFamily,
+) (externalhaproxyoperatorv1alpha1.Servers, error) {
+	port := parent.Port
+	if servicePort != nil {
+		p := int64(servicePort.Port)
+		port = &p
+	}
+	if port == nil {
+		return nil, fmt.Errorf("a port must be set on the server or the Service port must be resolvable")
+	}
+
+	var addresses []string
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		address := ingress.IP
+		if address == "" {
+			address = ingress.Hostname
+		}
+		if address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	var servers externalhaproxyoperatorv1alpha1.Servers
+	for _, address := range filterAddresses(addresses, family) {
+		servers = append(servers, generatedServer(parent, service.Name+"-"+addressName(address), address, port))
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no load balancer ingress for Service %s/%s", service.Namespace, service.Name)
+	}
+	return servers, nil
+}
+
+// externalNameServer creates a server for an ExternalName Service, addressed by its DNS name and
+// resolved by HAProxy at runtime through the "default" resolvers section unless the server names
+// another. The Service port is used when the Service declares ports.
+func externalNameServer(service *corev1.Service, servicePort *corev1.ServicePort, parent *externalhaproxyoperatorv1alpha1.Server) (*externalhaproxyoperatorv1alpha1.Server, error) {
+	port := parent.Port
+	if servicePort != nil {
+		p := int64(servicePort.Port)
+		port = &p
+	}
+	if port == nil {
+		return nil, fmt.Errorf("a port must be set on the server or the Service port must be resolvable")
+	}
+
+	server := generatedServer(parent, service.Name, service.Spec.ExternalName, port)
+	if server.Resolvers == "" && server.ResolversRef == "" {
+		server.Resolvers = defaultResolvers
+	}
+	if server.InitAddr == nil {
+		initAddr := defaultExternalNameInitAddr
+		server.InitAddr = &initAddr
+	}
+	return server, nil
+}
+
+// generatedServer creates a server that inherits the parameters of the dynamic server it was generated from.
+func generatedServer(parent *externalhaproxyoperatorv1alpha1.Server, name, address string, port *int64) *externalhaproxyoperatorv1alpha1.Server {
+	return &externalhaproxyoperatorv1alpha1.Server{
+		ServerParams: parent.ServerParams,
+		Name:         name,
+		Address:      formatServerAddress(address),
+		Port:         port,
+	}
+}
+
+// appendServers appends the generated servers, adding the port to names already in use so that
+// several ports of the same Service can be used in one backend.
+func appendServers(servers, generated externalhaproxyoperatorv1alpha1.Servers) externalhaproxyoperatorv1alpha1.Servers {
+	names := make(map[string]bool, len(servers))
+	for _, s := range servers {
+		names[s.Name] = true
+	}
+	for _, s := range generated {
+		if names[s.Name] && s.Port != nil {
+			s.Name = s.Name + "-" + strconv.FormatInt(*s.Port, 10)
+		}
+		names[s.Name] = true
+		servers = append(servers, s)
+	}
+	return servers
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+func newTestService(ports ...corev1.ServicePort) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Ports: ports},
+	}
+}
+
+func TestResolveServicePort(t *testing.T) {
+	service := newTestService(
+		corev1.ServicePort{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080), NodePort: 30080},
+		corev1.ServicePort{Name: "https", Port: 443, TargetPort: intstr.FromString("tls"), NodePort: 30443},
+	)
+	fallback := int64(443)
+	unmatched := int64(8080)
+
+	tests := []struct {
+		name     string
+		ref      string
+		fallback *int64
+		want     string
+		wantErr  bool
+	}{
+		{name: "by name", ref: "https", want: "https"},
+		{name: "by number", ref: "80", want: "http"},
+		{name: "by server port", fallback: &fallback, want: "https"},
+		{name: "unmatched server port", fallback: &unmatched, want: ""},
+		{name: "ambiguous", wantErr: true},
+		{name: "unknown", ref: "grpc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port, err := resolveServicePort(service, tt.ref, tt.fallback)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := ""
+			if port != nil {
+				got = port.Name
+			}
+			if got != tt.want {
+				t.Errorf("expected port %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolveServicePort_SinglePort(t *testing.T) {
+	service := newTestService(corev1.ServicePort{Port: 80})
+	port, err := resolveServicePort(service, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if port == nil || port.Port != 80 {
+		t.Errorf("expected the only Service port, got %+v", port)
+	}
+}
+
+func TestPodIPServers_UsesTargetPort(t *testing.T) {
+	name := "https"
+	targetPort := int32(8443)
+	endpoints := []resolvedEndpoint{{
+		address: "10.0.0.1",
+		podName: "web-0",
+		ports:   []discoveryv1.EndpointPort{{Name: &name, Port: &targetPort}},
+	}}
+	serverPort := int64(443)
+	parent := &externalhaproxyoperatorv1alpha1.Server{Port: &serverPort}
+
+	servers, err := podIPServers(endpoints, &corev1.ServicePort{Name: "https", Port: 443}, parent)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(servers) != 1 {
+		t.Fatalf("expected 1 server, got %d", len(servers))
+	}
+	if servers[0].Name != "web-0" || servers[0].Address != "10.0.0.1" || *servers[0].Port != 8443 {
+		t.Errorf("unexpected server: %+v", servers[0])
+	}
+}
+
+func TestPodIPServers_WithoutPort(t *testing.T) {
+	endpoints := []resolvedEndpoint{{address: "10.0.0.1", podName: "web-0"}}
+	parent := &externalhaproxyoperatorv1alpha1.Server{}
+
+	if _, err := podIPServers(endpoints, nil, parent); err == nil {
+		t.Error("expected an error without a Service port or server port")
+	}
+}
+
+func TestLoadBalancerServers(t *testing.T) {
+	service := newTestService(corev1.ServicePort{Name: "http", Port: 80})
+	service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "192.0.2.10"}}
+
+	servers, err := loadBalancerServers(service, &service.Spec.Ports[0], &externalhaproxyoperatorv1alpha1.Server{}, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(servers) != 1 || servers[0].Address != "192.0.2.10" || *servers[0].Port != 80 {
+		t.Errorf("unexpected servers: %+v", servers)
+	}
+
+	service.Status.LoadBalancer.Ingress = nil
+	if _, err := loadBalancerServers(service, &service.Spec.Ports[0], &externalhaproxyoperatorv1alpha1.Server{}, ""); err == nil {
+		t.Error("expected error when the Service has no ingress")
+	}
+}
+
+func TestAppendServers_DisambiguatesNames(t *testing.T) {
+	http := int64(80)
+	https := int64(443)
+	servers := appendServers(nil, externalhaproxyoperatorv1alpha1.Servers{{Name: "web-0", Port: &http}})
+	servers = appendServers(servers, externalhaproxyoperatorv1alpha1.Servers{{Name: "web-0", Port: &https}})
+
+	if len(servers) != 2 {
+		t.Fatalf("expected 2 servers, got %d", len(servers))
+	}
+	if servers[0].Name != "web-0" || servers[1].Name != "web-0-443" {
+		t.Errorf("unexpected server names: %q, %q", servers[0].Name, servers[1].Name)
+	}
+}
+
+func TestExternalNameServer(t *testing.T) {
+	service := newTestService()
+	service.Spec.Type = corev1.ServiceTypeExternalName
+	service.Spec.ExternalName = "legacy.example.com"
+	port := int64(8080)
+
+	server, err := externalNameServer(service, nil, &externalhaproxyoperatorv1alpha1.Server{Port: &port})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if server.Name != "web" || server.Address != "legacy.example.com" || *server.Port != 8080 {
+		t.Errorf("unexpected server: %+v", server)
+	}
+	if server.Resolvers != defaultResolvers || server.InitAddr == nil || *server.InitAddr != defaultExternalNameInitAddr {
+		t.Errorf("expected default resolvers and init-addr, got %q and %v", server.Resolvers, server.InitAddr)
+	}
+
+	initAddr := "libc"
+	parent := &externalhaproxyoperatorv1alpha1.Server{
+		ServerParams: externalhaproxyoperatorv1alpha1.ServerParams{Resolvers: "corp", InitAddr: &initAddr},
+	}
+	server, err = externalNameServer(service, &corev1.ServicePort{Port: 443}, parent)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if server.Resolvers != "corp" || *server.InitAddr != "libc" || *server.Port != 443 {
+		t.Errorf("expected server parameters and Service port to be kept, got %+v", server)
+	}
+
+	if _, err := externalNameServer(service, nil, &externalhaproxyoperatorv1alpha1.Server{}); err == nil {
+		t.Error("expected error without a port")
+	}
+}
+
+func TestReadyEndpoints_WithoutTargetRef(t *testing.T) {
+	ready := true
+	notReady := false
+	slices := []discoveryv1.EndpointSlice{{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-manual", Namespace: "default"},
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"192.0.2.1"}},
+			{Addresses: []string{"192.0.2.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}},
+			{Addresses: []string{"192.0.2.3"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+		},
+	}}
+
+	endpoints := (&BackendReconciler{}).readyEndpoints(context.Background(), logr.Discard(), slices)
+	if len(endpoints) != 2 {
+		t.Fatalf("expected 2 endpoints, got %d", len(endpoints))
+	}
+	if endpoints[0].name() != "192.0.2.1" || endpoints[1].name() != "192.0.2.2" {
+		t.Errorf("unexpected endpoints: %+v", endpoints)
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+const (
+	// defaultServerWeight is the weight HAProxy gives servers without an explicit weight.
+	defaultServerWeight = 100
+	// defaultRemoteWeight is the percentage of their weight out-of-zone servers keep in Weight mode.
+	defaultRemoteWeight = 10
+)
+
+// endpointZone is the topology of the endpoint or node behind a server.
+type endpointZone struct {
+	zone     string
+	forZones []string
+}
+
+// local reports whether the endpoint serves the given zone. Topology hints take precedence over
+// the zone of the endpoint, and endpoints in an unknown zone are considered local.
+func (z endpointZone) local(zone string) bool {
+	if len(z.forZones) > 0 {
+		return slices.Contains(z.forZones, zone)
+	}
+	return z.zone == "" || z.zone == zone
+}
+
+// zoneTopology is the topology policy of a Backend, resolved against the zone of the HAProxy instance.
+type zoneTopology struct {
+	mode         externalhaproxyoperatorv1alpha1.TopologyMode
+	localZone    string
+	remoteWeight int64
+}
+
+// backendTopology returns the topology policy of the Backend, or nil if it has none or the local
+// zone is not known.
+func (r *BackendReconciler) backendTopology(reqLogger logr.Logger, backend *externalhaproxyoperatorv1alpha1.Backend) *zoneTopology {
+	policy := backend.Spec.Topology
+	if policy == nil {
+		return nil
+	}
+	localZone := policy.LocalZone
+	if localZone == "" {
+		localZone = r.LocalZone
+	}
+	if localZone == "" {
+		reqLogger.Info("Ignoring topology policy since the local zone is not set in the Backend or HAPROXY_ZONE")
+		return nil
+	}
+	remoteWeight := int64(defaultRemoteWeight)
+	if policy.RemoteWeight != nil {
+		remoteWeight = *policy.RemoteWeight
+	}
+	return &zoneTopology{mode: policy.Mode, localZone: localZone, remoteWeight: remoteWeight}
+}
+
+// apply marks the servers outside of the local zone as backup servers or lowers their weight.
+// Servers are matched to their zones by name. Nothing is changed when no server is local, since
+// there would be no primary servers left to prefer.
+func (t *zoneTopology) apply(reqLogger logr.Logger, servers externalhaproxyoperatorv1alpha1.Servers, zones map[string]endpointZone) {
+	local := make([]bool, len(servers))
+	anyLocal := false
+	for i, server := range servers {
+		local[i] = zones[server.Name].local(t.localZone)
+		anyLocal = anyLocal || local[i]
+	}
+	if !anyLocal {
+		reqLogger.V(1).Info("No servers in the local zone, ignoring topology policy", "zone", t.localZone)
+		return
+	}
+
+	for i, server := range servers {
+		if local[i] {
+			continue
+		}
+		switch t.mode {
+		case externalhaproxyoperatorv1alpha1.TopologyModeBackup:
+			server.Backup = "enabled"
+		case externalhaproxyoperatorv1alpha1.TopologyModeWeight:
+			weight := int64(defaultServerWeight)
+			if server.Weight != nil {
+				weight = *server.Weight
+			}
+			weight = weight * t.remoteWeight / 100
+			server.Weight = &weight
+		}
+	}
+}
+
+// endpointZones returns the zones of the endpoints, keyed by server name. Endpoints without a zone
+// in their EndpointSlice get the zone of their node.
+func (r *BackendReconciler) endpointZones(ctx context.Context, endpoints []resolvedEndpoint) map[string]endpointZone {
+	nodeZones := map[string]string{}
+	zones := make(map[string]endpointZone, len(endpoints))
+	for _, endpoint := range endpoints {
+		zone := endpoint.zone
+		if zone == "" && endpoint.nodeName != "" {
+			if _, ok := nodeZones[endpoint.nodeName]; !ok {
+				nodeZones[endpoint.nodeName] = r.nodeZone(ctx, endpoint.nodeName)
+			}
+			zone = nodeZones[endpoint.nodeName]
+		}
+		zones[endpoint.name()] = endpointZone{zone: zone, forZones: endpoint.forZones}
+	}
+	return zones
+}
+
+// nodeServerZones returns the zones of the nodes hosting the endpoints, keyed by the names of the
+// servers generated for the nodes.
+func (r *BackendReconciler) nodeServerZones(ctx context.Context, endpoints []resolvedEndpoint) map[string]endpointZone {
+	zones := map[string]endpointZone{}
+	for _, endpoint := range endpoints {
+		if endpoint.nodeName == "" {
+			continue
+		}
+		if _, ok := zones[endpoint.nodeName]; ok {
+			continue
+		}
+		zone := endpointZone{zone: r.nodeZone(ctx, endpoint.nodeName)}
+		zones[endpoint.nodeName] = zone
+		zones[endpoint.nodeName+ipv6NameSuffix] = zone
+	}
+	return zones
+}
+
+// nodeZone returns the value of the node's zone label, or an empty string if it is unknown.
+func (r *BackendReconciler) nodeZone(ctx context.Context, name string) string {
+	node := &corev1.Node{}
+	if err := r.Get(ctx, client.ObjectKey{Name: name}, node); err != nil {
+		return ""
+	}
+	return node.Labels[corev1.LabelTopologyZone]
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+func TestEndpointZones(t *testing.T) {
+	r := &BackendReconciler{Client: fake.NewClientBuilder().WithObjects(
+		newTestNode("node1", true, false, map[string]string{corev1.LabelTopologyZone: "zone-b"}),
+	).Build()}
+	zones := r.endpointZones(context.Background(), []resolvedEndpoint{
+		{podName: "web-0", address: "10.0.0.1", zone: "zone-a"},
+		{podName: "web-1", address: "10.0.0.2", nodeName: "node1"},
+		{podName: "web-2", address: "10.0.0.3", zone: "zone-b", forZones: []string{"zone-a"}},
+		{podName: "web-3", address: "10.0.0.4", nodeName: "node2"},
+	})
+
+	tests := map[string]bool{"web-0": true, "web-1": false, "web-2": true, "web-3": true}
+	for name, want := range tests {
+		if got := zones[name].local("zone-a"); got != want {
+			t.Errorf("expected %s local=%v, got %v", name, want, got)
+		}
+	}
+}
+
+func TestBackendTopology_LocalZone(t *testing.T) {
+	backend := &externalhaproxyoperatorv1alpha1.Backend{}
+	r := &BackendReconciler{LocalZone: "zone-a"}
+	if r.backendTopology(logr.Discard(), backend) != nil {
+		t.Error("expected no topology without a policy")
+	}
+
+	backend.Spec.Topology = &externalhaproxyoperatorv1alpha1.TopologyPolicy{Mode: externalhaproxyoperatorv1alpha1.TopologyModeWeight}
+	topology := r.backendTopology(logr.Discard(), backend)
+	if topology == nil || topology.localZone != "zone-a" || topology.remoteWeight != defaultRemoteWeight {
+		t.Errorf("expected topology for zone-a with default remote weight, got %+v", topology)
+	}
+
+	backend.Spec.Topology.LocalZone = "zone-b"
+	if topology := r.backendTopology(logr.Discard(), backend); topology == nil || topology.localZone != "zone-b" {
+		t.Errorf("expected the Backend to override the local zone, got %+v", topology)
+	}
+
+	backend.Spec.Topology.LocalZone = ""
+	if (&BackendReconciler{}).backendTopology(logr.Discard(), backend) != nil {
+		t.Error("expected no topology without a local zone")
+	}
+}
+
+func topologyTestServers() externalhaproxyoperatorv1alpha1.Servers {
+	weight := int64(50)
+	return externalhaproxyoperatorv1alpha1.Servers{
+		{Name: "web-0", Address: "10.0.0.1"},
+		{Name: "web-1", Address: "10.0.0.2", ServerParams: externalhaproxyoperatorv1alpha1.ServerParams{Weight: &weight}},
+	}
+}
+
+func TestZoneTopologyApply(t *testing.T) {
+	zones := map[string]endpointZone{"web-0": {zone: "zone-a"}, "web-1": {zone: "zone-b"}}
+
+	servers := topologyTestServers()
+	backup := &zoneTopology{mode: externalhaproxyoperatorv1alpha1.TopologyModeBackup, localZone: "zone-a"}
+	backup.apply(logr.Discard(), servers, zones)
+	if servers[0].Backup != "" || servers[1].Backup != "enabled" {
+		t.Errorf("expected only web-1 to be a backup server, got %q and %q", servers[0].Backup, servers[1].Backup)
+	}
+
+	servers = topologyTestServers()
+	weight := &zoneTopology{mode: externalhaproxyoperatorv1alpha1.TopologyModeWeight, localZone: "zone-a", remoteWeight: 10}
+	weight.apply(logr.Discard(), servers, zones)
+	if servers[0].Weight != nil || servers[1].Weight == nil || *servers[1].Weight != 5 {
+		t.Errorf("expected only web-1 to have its weight lowered to 5, got %v and %v", servers[0].Weight, servers[1].Weight)
+	}
+
+	servers = topologyTestServers()
+	remote := &zoneTopology{mode: externalhaproxyoperatorv1alpha1.TopologyModeBackup, localZone: "zone-c"}
+	remote.apply(logr.Discard(), servers, zones)
+	if servers[0].Backup != "" || servers[1].Backup != "" {
+		t.Error("expected no changes when no server is in the local zone")
+	}
+}
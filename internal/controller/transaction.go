@@ -0,0 +1,46 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+// haproxyTransactionMu serializes the HAProxy transactions of all reconcilers. The HAProxy client
+// is shared between them and tracks a single current transaction, so a reconciler must hold the
+// lock from starting its transaction until it is committed or deleted.
+var haproxyTransactionMu sync.Mutex
+
+// haproxyUnavailableRequeueAfter is how long reconciles wait for the Data Plane API to recover when
+// the HAProxy client short-circuits requests because it is unavailable.
+const haproxyUnavailableRequeueAfter = 30 * time.Second
+
+// unsupportedMessage returns the message of a condition reporting a field of the spec that the
+// version of HAProxy does not support.
+func unsupportedMessage(err error) string {
+	var unsupported haproxyclient.ErrUnsupported
+	if !errors.As(err, &unsupported) {
+		return err.Error()
+	}
+	return fmt.Sprintf("spec.%s: %s requires HAProxy %s or later, but HAProxy %s is running",
+		unsupported.Field, unsupported.Feature, unsupported.Required, unsupported.Version)
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+// TransactionsConfigMapName is the name of the ConfigMap tracking the HAProxy transactions started
+// by the operator, in the namespace of the operator.
+const TransactionsConfigMapName = "external-haproxy-operator-transactions"
+
+const (
+	// transactionSweepInterval is how often the tracked transactions are checked for stale ones.
+	transactionSweepInterval = time.Minute
+	// staleTransactionAge is the age after which a tracked transaction is stale. It leaves a
+	// transaction of a previous leader still running time to finish.
+	staleTransactionAge = time.Minute
+)
+
+// +kubebuilder:rbac:groups=core,namespace=system,resources=configmaps,verbs=get;create;update
+
+// TransactionTracker tracks the HAProxy transactions started by the operator in a ConfigMap, keyed
+// by transaction ID with the time they started, from when they start until they are committed or
+// deleted. The transactions it still tracks were left behind, and are deleted by the
+// StaleTransactionSweeper, also after the operator restarts.
+type TransactionTracker struct {
+	Client client.Client
+	// Reader reads the ConfigMap without a cache, as the operator does not watch ConfigMaps.
+	Reader    client.Reader
+	Namespace string
+}
+
+// Track records that the transaction started.
+func (t *TransactionTracker) Track(ctx context.Context, id string) error {
+	return t.update(ctx, func(data map[string]string) {
+		data[id] = time.Now().UTC().Format(time.RFC3339)
+	})
+}
+
+// Untrack records that the transaction was committed or deleted.
+func (t *TransactionTracker) Untrack(ctx context.Context, id string) error {
+	return t.update(ctx, func(data map[string]string) {
+		delete(data, id)
+	})
+}
+
+// Tracked returns the tracked transactions with the time they started.
+func (t *TransactionTracker) Tracked(ctx context.Context) (map[string]time.Time, error) {
+	configMap := &corev1.ConfigMap{}
+	err := t.Reader.Get(ctx, t.key(), configMap)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tracked := make(map[string]time.Time, len(configMap.Data))
+	for id, started := range configMap.Data {
+		// A start time that does not parse is treated as stale
+		tracked[id], _ = time.Parse(time.RFC3339, started)
+	}
+	return tracked, nil
+}
+
+func (t *TransactionTracker) key() types.NamespacedName {
+	return types.NamespacedName{Namespace: t.Namespace, Name: TransactionsConfigMapName}
+}
+
+// update applies change to the data of the ConfigMap, creating it if it does not exist.
+func (t *TransactionTracker) update(ctx context.Context, change func(data map[string]string)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap := &corev1.ConfigMap{}
+		err := t.Reader.Get(ctx, t.key(), configMap)
+		if errors.IsNotFound(err) {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: t.Namespace, Name: TransactionsConfigMapName},
+				Data:       map[string]string{},
+			}
+			change(configMap.Data)
+			if len(configMap.Data) == 0 {
+				return nil
+			}
+			err = t.Client.Create(ctx, configMap)
+			if errors.IsAlreadyExists(err) {
+				// Created concurrently, retry updating it
+				return errors.NewConflict(corev1.Resource("configmaps"), TransactionsConfigMapName, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		change(configMap.Data)
+		return t.Client.Update(ctx, configMap)
+	})
+}
+
+// StaleTransactionSweeper deletes the HAProxy transactions that the operator started but failed to
+// commit or delete, when the manager starts and periodically. Transactions left behind count
+// against the transaction limit of the Data Plane API until they expire, after which starting new
+// ones fails for every client. Only the transactions tracked by the TransactionTracker are deleted,
+// the transactions of the other clients of the Data Plane API are left alone.
+type StaleTransactionSweeper struct {
+	Tracker       *TransactionTracker
+	HAProxyClient haproxyclient.HAProxyClient
+}
+
+// Start sweeps the stale transactions until the context is cancelled.
+func (s *StaleTransactionSweeper) Start(ctx context.Context) error {
+	logger := logf.FromContext(ctx).WithName("transaction-sweeper")
+	ticker := time.NewTicker(transactionSweepInterval)
+	defer ticker.Stop()
+	for {
+		swept, err := s.Sweep(ctx, time.Now())
+		if err != nil {
+			logger.Error(err, "Failed to sweep stale HAProxy transactions")
+		}
+		if len(swept) > 0 {
+			logger.Info("Deleted stale HAProxy transactions", "transactions", swept)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection makes only the leader, which runs the transactions, sweep them.
+func (s *StaleTransactionSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// Sweep deletes the tracked transactions still open that started staleTransactionAge before now,
+// and returns their IDs. The tracked transactions no longer open, such as those expired by the Data
+// Plane API, are no longer tracked either.
+func (s *StaleTransactionSweeper) Sweep(ctx context.Context, now time.Time) ([]string, error) {
+	tracked, err := s.Tracker.Tracked(ctx)
+	if err != nil || len(tracked) == 0 {
+		return nil, err
+	}
+	transactions, err := s.HAProxyClient.ListTransactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	open := make(map[string]bool, len(transactions))
+	for _, transaction := range transactions {
+		open[transaction.ID] = true
+	}
+
+	ids := make([]string, 0, len(tracked))
+	for id, started := range tracked {
+		if !open[id] {
+			if err := s.Tracker.Untrack(ctx, id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if now.Sub(started) >= staleTransactionAge {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	// The reconcilers of this operator have no transaction in progress while the lock is held
+	haproxyTransactionMu.Lock()
+	defer haproxyTransactionMu.Unlock()
+	var swept []string
+	for _, id := range ids {
+		// Deleting the transaction stops tracking it
+		err := s.HAProxyClient.DeleteTransaction(ctx, id)
+		if haproxyclient.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return swept, err
+		}
+		swept = append(swept, id)
+	}
+	return swept, nil
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+// fakeTransactions is a Data Plane API with open transactions, which it deletes on request.
+type fakeTransactions struct {
+	mu   sync.Mutex
+	open map[string]bool
+}
+
+func (d *fakeTransactions) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id, ok := strings.CutPrefix(r.URL.Path, "/v3/services/haproxy/transactions/")
+	switch {
+	case r.URL.Path == "/v3/services/haproxy/transactions" && r.Method == http.MethodGet:
+		var transactions []string
+		for id := range d.open {
+			transactions = append(transactions, fmt.Sprintf(`{"id":%q,"status":"in_progress"}`, id))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, "["+strings.Join(transactions, ",")+"]")
+	case r.URL.Path == "/v3/services/haproxy/configuration/version":
+		fmt.Fprint(w, "1")
+	case r.URL.Path == "/v3/services/haproxy/transactions" && r.Method == http.MethodPost:
+		id := fmt.Sprintf("tx%d", len(d.open)+1)
+		d.open[id] = true
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id":%q}`, id)
+	case ok && r.Method == http.MethodDelete && d.open[id]:
+		delete(d.open, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestTransactionSweeper(t *testing.T, dataPlane *fakeTransactions, tracked map[string]string) *StaleTransactionSweeper {
+	t.Helper()
+	builder := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme)
+	if tracked != nil {
+		builder = builder.WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "operator", Name: TransactionsConfigMapName},
+			Data:       tracked,
+		})
+	}
+	c := builder.Build()
+	tracker := &TransactionTracker{Client: c, Reader: c, Namespace: "operator"}
+	ts := httptest.NewServer(dataPlane)
+	t.Cleanup(ts.Close)
+	return &StaleTransactionSweeper{
+		Tracker: tracker,
+		HAProxyClient: haproxyclient.NewHAProxyClient(haproxyclient.HAProxyConfig{
+			BaseURL: ts.URL,
+			Timeout: 5 * time.Second,
+			Tracker: tracker,
+		}),
+	}
+}
+
+func TestTransactionTracker(t *testing.T) {
+	dataPlane := &fakeTransactions{open: map[string]bool{}}
+	sweeper := newTestTransactionSweeper(t, dataPlane, nil)
+	ctx := context.Background()
+
+	if _, err := sweeper.HAProxyClient.StartTransaction(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tracked, err := sweeper.Tracker.Tracked(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tracked["tx1"]; !ok || len(tracked) != 1 {
+		t.Fatalf("expected the started transaction to be tracked, got %v", tracked)
+	}
+
+	if err := sweeper.HAProxyClient.DeleteTransaction(ctx, "tx1"); err != nil {
+		t.Fatal(err)
+	}
+	if tracked, err := sweeper.Tracker.Tracked(ctx); err != nil || len(tracked) != 0 {
+		t.Errorf("expected the deleted transaction not to be tracked, got %v, %v", tracked, err)
+	}
+}
+
+func TestStaleTransactionSweeper(t *testing.T) {
+	stale := time.Now().Add(-2 * staleTransactionAge).UTC().Format(time.RFC3339)
+	recent := time.Now().UTC().Format(time.RFC3339)
+	dataPlane := &fakeTransactions{open: map[string]bool{"tx1": true, "tx2": true, "other": true}}
+	sweeper := newTestTransactionSweeper(t, dataPlane, map[string]string{
+		"tx1":     stale,
+		"tx2":     recent,
+		"expired": stale,
+	})
+	ctx := context.Background()
+
+	swept, err := sweeper.Sweep(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"tx1"}; !reflect.DeepEqual(swept, want) {
+		t.Errorf("expected %v to be swept, got %v", want, swept)
+	}
+	if want := map[string]bool{"tx2": true, "other": true}; !reflect.DeepEqual(dataPlane.open, want) {
+		t.Errorf("expected the recent and untracked transactions to be kept, got %v", dataPlane.open)
+	}
+	tracked, err := sweeper.Tracker.Tracked(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tracked["tx2"]; !ok || len(tracked) != 1 {
+		t.Errorf("expected only the recent transaction to stay tracked, got %v", tracked)
+	}
+}
+
+func TestStaleTransactionSweeper_LeavesOtherClientsTransactions(t *testing.T) {
+	dataPlane := &fakeTransactions{open: map[string]bool{"other": true}}
+	sweeper := newTestTransactionSweeper(t, dataPlane, nil)
+	ctx := context.Background()
+
+	if _, err := sweeper.HAProxyClient.StartTransaction(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Long after both transactions started, only the one of the operator is stale
+	swept, err := sweeper.Sweep(ctx, time.Now().Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"tx2"}; !reflect.DeepEqual(swept, want) {
+		t.Errorf("expected %v to be swept, got %v", want, swept)
+	}
+	if want := map[string]bool{"other": true}; !reflect.DeepEqual(dataPlane.open, want) {
+		t.Errorf("expected the transaction of the other client to be left alone, got %v", dataPlane.open)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+// isInvalidConfiguration reports whether HAProxy rejected the configuration resulting from a
+// transaction before it was committed.
+func isInvalidConfiguration(err error) bool {
+	var invalid haproxyclient.ErrInvalidConfiguration
+	return errors.As(err, &invalid)
+}
+
+// invalidConfiguration returns the message of a condition reporting configuration that HAProxy
+// rejected, and whether the rejected line is in the section of the resource, in which case the
+// resource fails the same way until its spec changes. The message starts with the path of the spec
+// field that generated the line, when field finds it.
+func invalidConfiguration(err error, section string, field func(haproxyclient.ErrInvalidConfiguration) string) (string, bool) {
+	var invalid haproxyclient.ErrInvalidConfiguration
+	if !errors.As(err, &invalid) || invalid.Section != section {
+		return err.Error(), false
+	}
+	if path := field(invalid); path != "" {
+		return fmt.Sprintf("spec.%s: HAProxy rejected %q: %s", path, invalid.Line, invalid.Output), true
+	}
+	return invalid.Error(), true
+}
+
+// backendSpecField returns the path of the field of the Backend spec that generated the line of its
+// backend section HAProxy rejected, or an empty string if it is not known.
+func backendSpecField(spec externalhaproxyoperatorv1alpha1.BackendSpec, invalid haproxyclient.ErrInvalidConfiguration) string {
+	switch invalid.Keyword() {
+	case "server":
+		generatedFrom := -1
+		for i, server := range spec.Servers {
+			if server.Name == invalid.Argument() {
+				return fmt.Sprintf("servers[%d]", i)
+			}
+			if server.ValueFrom != nil {
+				generatedFrom = i
+			}
+		}
+		// Servers generated from a Service are named after its endpoints, and can only be traced
+		// back to the server they were generated from when it is the only one
+		if generatedFrom != -1 && countValueFrom(spec.Servers) == 1 {
+			return fmt.Sprintf("servers[%d]", generatedFrom)
+		}
+		return "servers"
+	case "server-template":
+		for i, template := range spec.ServerTemplates {
+			if template.Prefix == invalid.Argument() {
+				return fmt.Sprintf("server_templates[%d]", i)
+			}
+		}
+		return "server_templates"
+	case "http-check":
+		if invalid.Index < len(spec.HTTPCheckList) {
+			return fmt.Sprintf("http_check_list[%d]", invalid.Index)
+		}
+		return "http_check_list"
+	case "balance":
+		return "balance"
+	case "option":
+		if spec.AdvCheck != "" && invalid.Argument() == spec.AdvCheck {
+			return "adv_check"
+		}
+	}
+	return ""
+}
+
+// countValueFrom returns the number of servers generated from other resources.
+func countValueFrom(servers externalhaproxyoperatorv1alpha1.Servers) int {
+	count := 0
+	for _, server := range servers {
+		if server.ValueFrom != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// resolverSpecField returns the path of the field of the Resolver spec that generated the line of
+// its resolvers section HAProxy rejected, or an empty string if it is not known.
+func resolverSpecField(spec externalhaproxyoperatorv1alpha1.ResolverSpec, invalid haproxyclient.ErrInvalidConfiguration) string {
+	switch keyword := invalid.Keyword(); keyword {
+	case "nameserver":
+		for i, nameserver := range spec.Nameservers {
+			if nameserver.Name == invalid.Argument() {
+				return fmt.Sprintf("nameservers[%d]", i)
+			}
+		}
+		return "nameservers"
+	case "timeout":
+		return "timeout_" + invalid.Argument()
+	case "hold":
+		return "hold." + invalid.Argument()
+	case "accepted_payload_size", "resolve_retries", "parse-resolv-conf":
+		return keyword
+	}
+	return ""
+}
+
+// cutoverSpecField returns the path of the field of the Cutover spec that generated the line of its
+// frontend HAProxy rejected, or an empty string if it is not known.
+func cutoverSpecField(spec externalhaproxyoperatorv1alpha1.CutoverSpec, invalid haproxyclient.ErrInvalidConfiguration) string {
+	switch invalid.Keyword() {
+	case "use_backend":
+		if spec.Rule != nil && strings.Contains(invalid.Line, spec.Rule.CondTest) {
+			return "rule"
+		}
+	case "default_backend":
+		if spec.Rule == nil {
+			return "backendRef"
+		}
+	}
+	return ""
+}
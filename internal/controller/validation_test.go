@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+	"github.com/ullbergm/external-haproxy-operator/internal/haproxyclient"
+)
+
+func TestBackendSpecField(t *testing.T) {
+	spec := externalhaproxyoperatorv1alpha1.BackendSpec{
+		Name:     "web",
+		AdvCheck: "httpchk",
+		Servers: externalhaproxyoperatorv1alpha1.Servers{
+			{Name: "static", Address: "10.0.0.1"},
+			{ValueFrom: &externalhaproxyoperatorv1alpha1.ServerValueFromSource{}},
+		},
+		ServerTemplates: externalhaproxyoperatorv1alpha1.ServerTemplates{{Prefix: "vm"}},
+		HTTPCheckList:   externalhaproxyoperatorv1alpha1.HTTPChecks{{}, {}},
+	}
+	tests := map[haproxyclient.ErrInvalidConfiguration]string{
+		{Line: "server static 10.0.0.1:80 chek"}:         "servers[0]",
+		{Line: "server web-7d4b9-x2x 10.1.0.7:8080"}:     "servers[1]",
+		{Line: "server-template vm 3 vm.example.com"}:    "server_templates[0]",
+		{Line: "http-check expect stauts 200", Index: 1}: "http_check_list[1]",
+		{Line: "balance roundrobin"}:                     "balance",
+		{Line: "option httpchk"}:                         "adv_check",
+		{Line: "timeout server 5s"}:                      "",
+	}
+	for invalid, want := range tests {
+		if got := backendSpecField(spec, invalid); got != want {
+			t.Errorf("backendSpecField(%q) = %q, expected %q", invalid.Line, got, want)
+		}
+	}
+}
+
+func TestInvalidConfiguration(t *testing.T) {
+	spec := externalhaproxyoperatorv1alpha1.BackendSpec{Name: "web", Servers: externalhaproxyoperatorv1alpha1.Servers{{Name: "web-0"}}}
+	field := func(invalid haproxyclient.ErrInvalidConfiguration) string { return backendSpecField(spec, invalid) }
+	invalid := haproxyclient.ErrInvalidConfiguration{
+		Section: "backend web",
+		Line:    "server web-0 10.0.0.1:80 chek",
+		Output:  "parsing [/tmp/haproxy.cfg:10] : 'server web-0' unknown keyword 'chek'.",
+	}
+
+	message, own := invalidConfiguration(fmt.Errorf("committing: %w", invalid), "backend web", field)
+	want := `spec.servers[0]: HAProxy rejected "server web-0 10.0.0.1:80 chek": parsing [/tmp/haproxy.cfg:10] : 'server web-0' unknown keyword 'chek'.`
+	if message != want || !own {
+		t.Errorf("got %q, %v, expected %q, true", message, own, want)
+	}
+
+	// The invalid line is in another section, which changing the backend does not fix
+	invalid.Section = "backend api"
+	if message, own := invalidConfiguration(invalid, "backend web", field); own || message != invalid.Error() {
+		t.Errorf("expected the error of another section, got %q, %v", message, own)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"math"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+// maxServerWeight is the highest weight HAProxy accepts for a server.
+const maxServerWeight = 256
+
+// serverSource is the set of servers resolved from one server of a Backend.
+type serverSource struct {
+	servers externalhaproxyoperatorv1alpha1.Servers
+	// weight is the share of the traffic of the source, if it has one.
+	weight *int64
+	// zones are the zones of the servers, keyed by name, if the source supports topology.
+	zones map[string]endpointZone
+	// unresolved is set when the servers of the source could not be resolved and are removed
+	// from the backend.
+	unresolved *unresolvedSource
+}
+
+// distributeSourceWeights splits the weight of each weighted source evenly across its servers,
+// so that the sources receive traffic in proportion to their weights regardless of how many
+// servers they have. The server weights are scaled up to the HAProxy maximum to keep the
+// proportions as precise as integer weights allow. Servers of a source with a non-zero weight
+// keep a weight of at least 1, so that a small share never drains the source.
+func distributeSourceWeights(sources []serverSource) {
+	maxShare := 0.0
+	for _, source := range sources {
+		if source.weight != nil && len(source.servers) > 0 {
+			maxShare = math.Max(maxShare, float64(*source.weight)/float64(len(source.servers)))
+		}
+	}
+
+	for _, source := range sources {
+		if source.weight == nil || len(source.servers) == 0 {
+			continue
+		}
+		weight := int64(0)
+		if *source.weight > 0 {
+			share := float64(*source.weight) / float64(len(source.servers))
+			weight = max(1, int64(math.Round(share/maxShare*maxServerWeight)))
+		}
+		for _, server := range source.servers {
+			serverWeight := weight
+			server.Weight = &serverWeight
+		}
+	}
+}
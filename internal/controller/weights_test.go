@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"testing"
+
+	externalhaproxyoperatorv1alpha1 "github.com/ullbergm/external-haproxy-operator/api/v1alpha1"
+)
+
+func weightTestServers(n int) externalhaproxyoperatorv1alpha1.Servers {
+	servers := make(externalhaproxyoperatorv1alpha1.Servers, n)
+	for i := range servers {
+		servers[i] = &externalhaproxyoperatorv1alpha1.Server{}
+	}
+	return servers
+}
+
+func sourceWeight(t *testing.T, source serverSource) int64 {
+	t.Helper()
+	total := int64(0)
+	for _, server := range source.servers {
+		if server.Weight == nil {
+			t.Fatal("expected the server to have a weight")
+		}
+		total += *server.Weight
+	}
+	return total
+}
+
+func TestDistributeSourceWeights(t *testing.T) {
+	stableWeight, canaryWeight := int64(90), int64(10)
+	static := serverSource{servers: weightTestServers(1)}
+	stable := serverSource{servers: weightTestServers(3), weight: &stableWeight}
+	canary := serverSource{servers: weightTestServers(1), weight: &canaryWeight}
+	distributeSourceWeights([]serverSource{static, stable, canary})
+
+	if static.servers[0].Weight != nil {
+		t.Error("expected servers without a source weight to keep their weight")
+	}
+	if got := *stable.servers[0].Weight; got != maxServerWeight {
+		t.Errorf("expected the largest share to get weight %d, got %d", maxServerWeight, got)
+	}
+	stableTotal, canaryTotal := sourceWeight(t, stable), sourceWeight(t, canary)
+	share := float64(canaryTotal) / float64(stableTotal+canaryTotal)
+	if share < 0.09 || share > 0.11 {
+		t.Errorf("expected the canary to get 10%% of the traffic, got %.3f (%d/%d)", share, canaryTotal, stableTotal)
+	}
+}
+
+func TestDistributeSourceWeights_Bounds(t *testing.T) {
+	drainedWeight, smallWeight, largeWeight := int64(0), int64(1), int64(100)
+	drained := serverSource{servers: weightTestServers(2), weight: &drainedWeight}
+	small := serverSource{servers: weightTestServers(10), weight: &smallWeight}
+	large := serverSource{servers: weightTestServers(1), weight: &largeWeight}
+	distributeSourceWeights([]serverSource{drained, small, large})
+
+	if sourceWeight(t, drained) != 0 {
+		t.Error("expected a zero weight to drain the source")
+	}
+	if *small.servers[0].Weight != 1 {
+		t.Errorf("expected a small share to keep weight 1, got %d", *small.servers[0].Weight)
+	}
+}
@@ -1,8 +1,10 @@
 package haproxyclient
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"net/netip"
+	"strconv"
 	"strings"
 
 	"github.com/go-resty/resty/v2"
@@ -11,16 +13,15 @@ import (
 )
 
 // GetBackend retrieves a backend by name
-func (c *Client) GetBackend(name string) (*models.Backend, error) {
-	queryKey, queryVal, err := c.getVersionOrTransactionParam()
+func (c *Client) GetBackend(ctx context.Context, name string) (*models.Backend, error) {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("api request failed: %w", err)
 	}
-	resp, err := c.client.R().
+	resp, err := c.client.R().SetContext(ctx).
 		SetHeader("Accept", "application/json").
-		SetResult(&models.Backend{}).
 		SetQueryParam(queryKey, queryVal).
-		Get(fmt.Sprintf("%s/v3/services/haproxy/configuration/backends/%s", c.config.BaseURL, name))
+		Get(c.configurationURL(section{}, "backends", name))
 	if err != nil {
 		return nil, fmt.Errorf("api request failed: %w", err)
 	}
@@ -28,29 +29,33 @@ func (c *Client) GetBackend(name string) (*models.Backend, error) {
 		return nil, nil
 	}
 	if resp.IsError() {
-		return nil, ErrAPIResponse{
-			StatusCode: resp.StatusCode(),
-			Body:       string(resp.Body()),
-			Operation:  "get backend",
-		}
+		return nil, newAPIError(resp, "get backend")
+	}
+
+	backend := &models.Backend{}
+	if err := c.decode(resp, backend); err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
 	}
 
 	// Log the response body for debugging
-	logf.Log.V(2).Info("GetBackend response", "name", name, "status", resp.Status(), "body", string(resp.Body()), "object", resp.Result().(*models.Backend))
+	logf.Log.V(2).Info("GetBackend response", "name", name, "status", resp.Status(), "body", string(resp.Body()), "object", backend)
 
-	return resp.Result().(*models.Backend), nil
+	return backend, nil
 }
 
 // EnsureBackend creates or updates a backend, ensuring it's managed by this controller
-func (c *Client) EnsureBackend(backend *models.Backend) error {
+func (c *Client) EnsureBackend(ctx context.Context, backend *models.Backend) error {
 	backend.Description = ManagedDescription
+	if err := c.checkBackend(backend); err != nil {
+		return err
+	}
 
-	queryKey, queryVal, err := c.getVersionOrTransactionParam()
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
 	if err != nil {
 		return err
 	}
 
-	existing, err := c.GetBackend(backend.Name)
+	existing, err := c.GetBackend(ctx, backend.Name)
 	if err != nil {
 		return fmt.Errorf("getting backend: %w", err)
 	}
@@ -61,11 +66,11 @@ func (c *Client) EnsureBackend(backend *models.Backend) error {
 		logf.Log.V(1).Info("Creating new backend", "name", backend.Name, "object", backend)
 
 		// Create new backend
-		resp, err = c.client.R().
+		resp, err = c.client.R().SetContext(ctx).
 			SetHeader("Content-Type", "application/json").
 			SetBody(backend).
 			SetQueryParam(queryKey, queryVal).
-			Post(fmt.Sprintf("%s/v3/services/haproxy/configuration/backends", c.config.BaseURL))
+			Post(c.configurationURL(section{}, "backends", ""))
 		c.transactionDirty = true
 	} else if existing.Description != ManagedDescription {
 		logf.Log.V(2).Info("Backend exists but is not managed by us", "name", backend.Name, "object", backend)
@@ -79,11 +84,11 @@ func (c *Client) EnsureBackend(backend *models.Backend) error {
 		logf.Log.V(1).Info("Updating existing backend", "name", backend.Name, "object", backend)
 
 		// Update existing backend
-		resp, err = c.client.R().
+		resp, err = c.client.R().SetContext(ctx).
 			SetHeader("Content-Type", "application/json").
 			SetBody(backend).
 			SetQueryParam(queryKey, queryVal).
-			Put(fmt.Sprintf("%s/v3/services/haproxy/configuration/backends/%s", c.config.BaseURL, backend.Name))
+			Put(c.configurationURL(section{}, "backends", backend.Name))
 		c.transactionDirty = true
 	} else {
 		logf.Log.V(2).Info("Backend is already in desired state", "name", backend.Name, "object", backend)
@@ -93,24 +98,20 @@ func (c *Client) EnsureBackend(backend *models.Backend) error {
 		return fmt.Errorf("api request: %w", err)
 	}
 	if resp != nil && resp.IsError() {
-		return ErrAPIResponse{
-			StatusCode: resp.StatusCode(),
-			Body:       string(resp.Body()),
-			Operation:  "update backend",
-		}
+		return newAPIError(resp, "update backend")
 	}
 
 	// Ensure http checks are set
-	if err := c.EnsureBackendHTTPCheck(backend.Name, backend.HTTPCheckList); err != nil {
+	if err := c.EnsureBackendHTTPCheck(ctx, backend.Name, backend.HTTPCheckList); err != nil {
 		return fmt.Errorf("ensuring backend HTTP checks: %w", err)
 	}
 	// Ensure servers are set
-	servers, err := c.ListServers(backend.Name)
+	servers, err := c.ListServers(ctx, backend.Name)
 	if err != nil {
 		return fmt.Errorf("listing servers: %w", err)
 	}
 	for _, server := range backend.Servers {
-		if err := c.EnsureServer(backend.Name, &server); err != nil {
+		if err := c.EnsureServer(ctx, backend.Name, &server); err != nil {
 			return fmt.Errorf("ensuring server %s: %w", server.Name, err)
 		}
 	}
@@ -124,34 +125,48 @@ func (c *Client) EnsureBackend(backend *models.Backend) error {
 			}
 		}
 		if !found {
-			if err := c.DeleteServer(backend.Name, existingServer.Name); err != nil {
+			if err := c.DeleteServer(ctx, backend.Name, existingServer.Name); err != nil {
 				return fmt.Errorf("deleting server %s: %w", existingServer.Name, err)
 			}
 		}
 	}
+	// Ensure server templates are set
+	templates, err := c.ListServerTemplates(ctx, backend.Name)
+	if err != nil {
+		return fmt.Errorf("listing server templates: %w", err)
+	}
+	for _, template := range backend.ServerTemplates {
+		if err := c.EnsureServerTemplate(ctx, backend.Name, &template); err != nil {
+			return fmt.Errorf("ensuring server template %s: %w", template.Prefix, err)
+		}
+	}
+	// Ensure all server templates are deleted that are not in the backend spec
+	for _, existingTemplate := range templates {
+		if _, found := backend.ServerTemplates[existingTemplate.Prefix]; !found {
+			if err := c.DeleteServerTemplate(ctx, backend.Name, existingTemplate.Prefix); err != nil {
+				return fmt.Errorf("deleting server template %s: %w", existingTemplate.Prefix, err)
+			}
+		}
+	}
 	return nil
 }
 
 // ListBackends returns all backends managed by this controller
-func (c *Client) ListBackends() ([]*models.Backend, error) {
-	queryKey, queryVal, _ := c.getVersionOrTransactionParam()
-	resp, err := c.client.R().
+func (c *Client) ListBackends(ctx context.Context) ([]*models.Backend, error) {
+	queryKey, queryVal, _ := c.getVersionOrTransactionParam(ctx)
+	resp, err := c.client.R().SetContext(ctx).
 		SetHeader("Accept", "application/json").
 		SetQueryParam(queryKey, queryVal).
-		Get(fmt.Sprintf("%s/v3/services/haproxy/configuration/backends", c.config.BaseURL))
+		Get(c.configurationURL(section{}, "backends", ""))
 	if err != nil {
 		return nil, fmt.Errorf("api request failed: %w", err)
 	}
 	if resp.IsError() {
-		return nil, ErrAPIResponse{
-			StatusCode: resp.StatusCode(),
-			Body:       string(resp.Body()),
-			Operation:  "list backends",
-		}
+		return nil, newAPIError(resp, "list backends")
 	}
 
 	var all []*models.Backend
-	if err := json.Unmarshal(resp.Body(), &all); err != nil {
+	if err := c.decode(resp, &all); err != nil {
 		return nil, fmt.Errorf("unmarshaling response: %w", err)
 	}
 
@@ -165,13 +180,13 @@ func (c *Client) ListBackends() ([]*models.Backend, error) {
 }
 
 // DeleteBackend deletes a backend only if it's managed by this controller
-func (c *Client) DeleteBackend(name string) error {
-	queryKey, queryVal, err := c.getVersionOrTransactionParam()
+func (c *Client) DeleteBackend(ctx context.Context, name string) error {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
 	if err != nil {
 		return fmt.Errorf("getting config version: %w", err)
 	}
 
-	b, err := c.GetBackend(name)
+	b, err := c.GetBackend(ctx, name)
 	if err != nil {
 		return fmt.Errorf("getting backend: %w", err)
 	}
@@ -190,18 +205,14 @@ func (c *Client) DeleteBackend(name string) error {
 		}
 	}
 
-	resp, err := c.client.R().
+	resp, err := c.client.R().SetContext(ctx).
 		SetQueryParam(queryKey, queryVal).
-		Delete(fmt.Sprintf("%s/v3/services/haproxy/configuration/backends/%s", c.config.BaseURL, name))
+		Delete(c.configurationURL(section{}, "backends", name))
 	if err != nil {
 		return fmt.Errorf("api request failed: %w", err)
 	}
 	if resp.IsError() && resp.StatusCode() != 404 {
-		return ErrAPIResponse{
-			StatusCode: resp.StatusCode(),
-			Body:       string(resp.Body()),
-			Operation:  "delete backend",
-		}
+		return newAPIError(resp, "delete backend")
 	}
 	if resp.StatusCode() == 200 || resp.StatusCode() == 204 || resp.StatusCode() == 202 {
 		c.transactionDirty = true
@@ -253,8 +264,8 @@ func (c *Client) backendsEqual(a, b *models.Backend) bool {
 // http_check
 
 // EnsureBackendHTTPCheck sets HTTP check configuration for a backend
-func (c *Client) EnsureBackendHTTPCheck(backend string, httpChecks []*models.HTTPCheck) error {
-	current, err := c.getCurrentHTTPChecks(backend)
+func (c *Client) EnsureBackendHTTPCheck(ctx context.Context, backend string, httpChecks []*models.HTTPCheck) error {
+	current, err := c.getCurrentHTTPChecks(ctx, backend)
 	if err != nil {
 		return err
 	}
@@ -262,41 +273,27 @@ func (c *Client) EnsureBackendHTTPCheck(backend string, httpChecks []*models.HTT
 		return nil
 	}
 
-	queryKey, queryVal, err := c.getVersionOrTransactionParam()
-	if err != nil {
-		return err
-	}
-
-	resp, err := c.client.R().
-		SetHeader("Content-Type", "application/json").
-		SetBody(httpChecks).
-		SetQueryParam(queryKey, queryVal).
-		Put(fmt.Sprintf("%s/v3/services/haproxy/configuration/backends/%s/http_checks", c.config.BaseURL, backend))
-	c.transactionDirty = true
-	if err != nil {
-		return err
-	}
-	if resp.IsError() {
-		return fmt.Errorf("failed to update http_check, status: %d, body: %s", resp.StatusCode(), resp.Body())
-	}
-	return nil
+	return replaceList(ctx, c, backendSection(backend), "http_checks", len(current), httpChecks, "update http_check")
 }
 
-func (c *Client) getCurrentHTTPChecks(backend string) ([]*models.HTTPCheck, error) {
-	queryKey, queryVal, _ := c.getVersionOrTransactionParam()
-	resp, err := c.client.R().
+func (c *Client) getCurrentHTTPChecks(ctx context.Context, backend string) ([]*models.HTTPCheck, error) {
+	queryKey, queryVal, _ := c.getVersionOrTransactionParam(ctx)
+	resp, err := c.client.R().SetContext(ctx).
 		SetHeader("Accept", "application/json").
 		SetQueryParam(queryKey, queryVal).
-		Get(fmt.Sprintf("%s/v3/services/haproxy/configuration/backends/%s/http_checks", c.config.BaseURL, backend))
+		Get(c.configurationURL(backendSection(backend), "http_checks", ""))
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode() == 404 {
 		return nil, nil
 	}
+	if resp.IsError() {
+		return nil, newAPIError(resp, "get http_checks")
+	}
 
 	var checks []*models.HTTPCheck
-	if err := json.Unmarshal(resp.Body(), &checks); err != nil {
+	if err := c.decode(resp, &checks); err != nil {
 		return nil, err
 	}
 	return checks, nil
@@ -350,30 +347,36 @@ func (c *Client) httpCheckEqual(a, b *models.HTTPCheck) bool {
 // Server
 
 // GetServer retrieves a server from a backend
-func (c *Client) GetServer(backend, name string) (*models.Server, error) {
-	queryKey, queryVal, _ := c.getVersionOrTransactionParam()
-	resp, err := c.client.R().
+func (c *Client) GetServer(ctx context.Context, backend, name string) (*models.Server, error) {
+	queryKey, queryVal, _ := c.getVersionOrTransactionParam(ctx)
+	resp, err := c.client.R().SetContext(ctx).
 		SetHeader("Accept", "application/json").
 		SetQueryParam(queryKey, queryVal).
-		SetResult(&models.Server{}).
-		Get(fmt.Sprintf("%s/v3/services/haproxy/configuration/backends/%s/servers/%s", c.config.BaseURL, backend, name))
+		Get(c.configurationURL(backendSection(backend), "servers", name))
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode() == 404 {
 		return nil, nil
 	}
-	return resp.Result().(*models.Server), nil
+	if resp.IsError() {
+		return nil, newAPIError(resp, "get server")
+	}
+	server := &models.Server{}
+	if err := c.decode(resp, server); err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	}
+	return server, nil
 }
 
 // EnsureServer creates or updates a server in a backend
-func (c *Client) EnsureServer(backend string, server *models.Server) error {
-	queryKey, queryVal, err := c.getVersionOrTransactionParam()
+func (c *Client) EnsureServer(ctx context.Context, backend string, server *models.Server) error {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
 	if err != nil {
 		return err
 	}
 
-	existing, err := c.GetServer(backend, server.Name)
+	existing, err := c.GetServer(ctx, backend, server.Name)
 	if err != nil {
 		return err
 	}
@@ -381,19 +384,19 @@ func (c *Client) EnsureServer(backend string, server *models.Server) error {
 	var resp *resty.Response
 	if existing == nil {
 		logf.Log.V(1).Info("Creating new server", "backend", backend, "name", server.Name, "object", server)
-		resp, err = c.client.R().
+		resp, err = c.client.R().SetContext(ctx).
 			SetHeader("Content-Type", "application/json").
 			SetBody(server).
 			SetQueryParam(queryKey, queryVal).
-			Post(fmt.Sprintf("%s/v3/services/haproxy/configuration/backends/%s/servers", c.config.BaseURL, backend))
+			Post(c.configurationURL(backendSection(backend), "servers", ""))
 		c.transactionDirty = true
 	} else if !c.serversEqual(existing, server) {
 		logf.Log.V(1).Info("Updating existing server", "backend", backend, "name", server.Name, "object", server)
-		resp, err = c.client.R().
+		resp, err = c.client.R().SetContext(ctx).
 			SetHeader("Content-Type", "application/json").
 			SetBody(server).
 			SetQueryParam(queryKey, queryVal).
-			Put(fmt.Sprintf("%s/v3/services/haproxy/configuration/backends/%s/servers/%s", c.config.BaseURL, backend, server.Name))
+			Put(c.configurationURL(backendSection(backend), "servers", server.Name))
 		c.transactionDirty = true
 	}
 
@@ -401,56 +404,126 @@ func (c *Client) EnsureServer(backend string, server *models.Server) error {
 		return err
 	}
 	if resp != nil && resp.IsError() {
-		return fmt.Errorf("failed to update server, status: %d, body: %s", resp.StatusCode(), resp.Body())
+		return newAPIError(resp, "update server")
+	}
+	return nil
+}
+
+// UpdateServerWeights updates the weights of existing servers of a managed backend that differ from
+// the desired servers only in their weight. The updates are made outside of a transaction, which lets
+// the Data Plane API apply them through the runtime API without reloading HAProxy. It returns the
+// names of the updated servers, and must not be called while a transaction is in progress.
+func (c *Client) UpdateServerWeights(ctx context.Context, backend *models.Backend) ([]string, error) {
+	if c.currentTransactionID != "" {
+		return nil, fmt.Errorf("cannot update server weights at runtime during transaction %s", c.currentTransactionID)
+	}
+
+	existingBackend, err := c.GetBackend(ctx, backend.Name)
+	if err != nil {
+		return nil, fmt.Errorf("getting backend: %w", err)
+	}
+	if existingBackend == nil || existingBackend.Description != ManagedDescription {
+		// New backends have no servers to update, and unmanaged ones are left alone
+		return nil, nil
+	}
+
+	existing, err := c.ListServers(ctx, backend.Name)
+	if err != nil {
+		return nil, fmt.Errorf("listing servers: %w", err)
+	}
+	current := make(map[string]*models.Server, len(existing))
+	for _, server := range existing {
+		current[server.Name] = server
+	}
+
+	var updated []string
+	for _, server := range backend.Servers {
+		existingServer := current[server.Name]
+		if existingServer == nil || int64PtrEqual(existingServer.Weight, server.Weight) {
+			continue
+		}
+		// Only the weight may differ, any other change needs a reload anyway
+		candidate := *existingServer
+		candidate.Weight = server.Weight
+		if !c.serversEqual(&candidate, &server) {
+			continue
+		}
+
+		logf.Log.V(1).Info("Updating server weight at runtime", "backend", backend.Name, "name", server.Name, "weight", server.Weight)
+		// Other writes outside of transactions may race with the update, which is then made again
+		// with the fresh configuration version
+		err := c.retryConflicts(ctx, func() error {
+			return c.updateServerWeight(ctx, backend.Name, &candidate)
+		})
+		if err != nil {
+			return updated, err
+		}
+		updated = append(updated, server.Name)
+	}
+	return updated, nil
+}
+
+// updateServerWeight replaces a server outside of a transaction, at the current configuration version.
+func (c *Client) updateServerWeight(ctx context.Context, backend string, server *models.Server) error {
+	version, err := c.GetConfigVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("getting config version: %w", err)
+	}
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(server).
+		SetQueryParam("version", strconv.FormatInt(version, 10)).
+		Put(c.configurationURL(backendSection(backend), "servers", server.Name))
+	if err != nil {
+		return fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.IsError() {
+		return newAPIError(resp, "update server weight")
+	}
+	if resp.StatusCode() == 202 {
+		// The Data Plane API could not apply the change at runtime and scheduled a reload
+		logf.Log.Info("Server weight update needs a reload", "backend", backend, "name", server.Name)
 	}
 	return nil
 }
 
 // ListServers lists all servers in a backend
-func (c *Client) ListServers(backend string) ([]*models.Server, error) {
-	queryKey, queryVal, _ := c.getVersionOrTransactionParam()
-	resp, err := c.client.R().
+func (c *Client) ListServers(ctx context.Context, backend string) ([]*models.Server, error) {
+	queryKey, queryVal, _ := c.getVersionOrTransactionParam(ctx)
+	resp, err := c.client.R().SetContext(ctx).
 		SetHeader("Accept", "application/json").
 		SetQueryParam(queryKey, queryVal).
-		Get(fmt.Sprintf("%s/v3/services/haproxy/configuration/backends/%s/servers", c.config.BaseURL, backend))
+		Get(c.configurationURL(backendSection(backend), "servers", ""))
 	if err != nil {
 		return nil, fmt.Errorf("api request failed: %w", err)
 	}
 	if resp.IsError() {
-		return nil, ErrAPIResponse{
-			StatusCode: resp.StatusCode(),
-			Body:       string(resp.Body()),
-			Operation:  "list servers",
-		}
+		return nil, newAPIError(resp, "list servers")
 	}
 
 	var servers []*models.Server
-	if err := json.Unmarshal(resp.Body(), &servers); err != nil {
+	if err := c.decode(resp, &servers); err != nil {
 		return nil, fmt.Errorf("unmarshaling response: %w", err)
 	}
 	return servers, nil
 }
 
 // DeleteServer deletes a server from a backend
-func (c *Client) DeleteServer(backend, name string) error {
-	queryKey, queryVal, err := c.getVersionOrTransactionParam()
+func (c *Client) DeleteServer(ctx context.Context, backend, name string) error {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
 	if err != nil {
 		return fmt.Errorf("getting config version: %w", err)
 	}
 	logf.Log.V(1).Info("Deleting server", "backend", backend, "name", name)
 
-	resp, err := c.client.R().
+	resp, err := c.client.R().SetContext(ctx).
 		SetQueryParam(queryKey, queryVal).
-		Delete(fmt.Sprintf("%s/v3/services/haproxy/configuration/backends/%s/servers/%s", c.config.BaseURL, backend, name))
+		Delete(c.configurationURL(backendSection(backend), "servers", name))
 	if err != nil {
 		return fmt.Errorf("api request failed: %w", err)
 	}
 	if resp.IsError() && resp.StatusCode() != 404 {
-		return ErrAPIResponse{
-			StatusCode: resp.StatusCode(),
-			Body:       string(resp.Body()),
-			Operation:  "delete server",
-		}
+		return newAPIError(resp, "delete server")
 	}
 	if resp.StatusCode() == 200 || resp.StatusCode() == 204 || resp.StatusCode() == 202 {
 		c.transactionDirty = true
@@ -463,7 +536,7 @@ func (c *Client) serversEqual(a, b *models.Server) bool {
 	if a == nil || b == nil {
 		return a == b
 	}
-	if a.Name != b.Name || a.Address != b.Address {
+	if a.Name != b.Name || !addressesEqual(a.Address, b.Address) {
 		return false
 	}
 
@@ -474,8 +547,41 @@ func (c *Client) serversEqual(a, b *models.Server) bool {
 		return false
 	}
 
+	return serverParamsEqual(a.ServerParams, b.ServerParams)
+}
+
+// addressesEqual compares two server addresses, treating IPs in any notation, with or without
+// brackets around IPv6 addresses, as equal to their canonical form
+func addressesEqual(a, b string) bool {
+	return normalizeAddress(a) == normalizeAddress(b)
+}
+
+// normalizeAddress returns the canonical form of an IP address, or the address unchanged if it
+// is not an IP
+func normalizeAddress(address string) string {
+	addr, err := netip.ParseAddr(strings.Trim(address, "[]"))
+	if err != nil {
+		return address
+	}
+	return addr.WithZone("").Unmap().String()
+}
+
+// serverParamsEqual compares the parameters managed by this controller on servers and server templates
+func serverParamsEqual(a, b models.ServerParams) bool {
 	if !strings.EqualFold(a.Check, b.Check) {
 		return false
 	}
+	if a.Resolvers != b.Resolvers || a.ResolvePrefer != b.ResolvePrefer {
+		return false
+	}
+	if !int64PtrEqual(a.Weight, b.Weight) || !strings.EqualFold(a.Backup, b.Backup) {
+		return false
+	}
+	if (a.InitAddr == nil) != (b.InitAddr == nil) {
+		return false
+	}
+	if a.InitAddr != nil && b.InitAddr != nil && *a.InitAddr != *b.InitAddr {
+		return false
+	}
 	return true
 }
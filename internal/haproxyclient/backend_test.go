@@ -0,0 +1,86 @@
+package haproxyclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+func TestServersEqual_IPv6Notation(t *testing.T) {
+	port := int64(8080)
+	client := &Client{}
+	desired := &models.Server{Name: "web-0", Address: "[2001:db8::1]", Port: &port}
+
+	for _, address := range []string{"[2001:db8::1]", "2001:db8::1", "2001:DB8:0::1"} {
+		existing := &models.Server{Name: "web-0", Address: address, Port: &port}
+		if !client.serversEqual(existing, desired) {
+			t.Errorf("expected %q to equal %q", address, desired.Address)
+		}
+	}
+	if client.serversEqual(&models.Server{Name: "web-0", Address: "2001:db8::2", Port: &port}, desired) {
+		t.Error("expected different addresses to differ")
+	}
+}
+
+func TestUpdateServerWeights_OnlyWeightChanges(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		puts []string
+	)
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v3/services/haproxy/configuration")
+		switch {
+		case r.Method == http.MethodGet && path == "/backends/web":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"name":"web","description":%q}`, ManagedDescription)
+		case r.Method == http.MethodGet && path == "/backends/web/servers":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"name":"stable-0","address":"10.0.0.1","port":80,"weight":100},`+
+				`{"name":"canary-0","address":"10.0.0.2","port":80,"weight":100}]`)
+		case r.Method == http.MethodGet:
+			fmt.Fprint(w, "1")
+		case r.Method == http.MethodPut:
+			if r.URL.Query().Get("version") == "" || r.URL.Query().Get("transaction_id") != "" {
+				t.Errorf("expected a versioned update outside of a transaction, got %s", r.URL.RawQuery)
+			}
+			mu.Lock()
+			puts = append(puts, path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	defer closeFn()
+
+	port := int64(80)
+	stableWeight, canaryWeight := int64(230), int64(26)
+	updated, err := client.UpdateServerWeights(context.Background(), &models.Backend{
+		BackendBase: models.BackendBase{Name: "web"},
+		Servers: map[string]models.Server{
+			"stable-0": {Name: "stable-0", Address: "10.0.0.1", Port: &port, ServerParams: models.ServerParams{Weight: &stableWeight}},
+			// The address changed as well, which needs a reload and is left to the transaction
+			"canary-0": {Name: "canary-0", Address: "10.0.0.3", Port: &port, ServerParams: models.ServerParams{Weight: &canaryWeight}},
+			"canary-1": {Name: "canary-1", Address: "10.0.0.4", Port: &port, ServerParams: models.ServerParams{Weight: &canaryWeight}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(updated, ",") != "stable-0" {
+		t.Errorf("expected only stable-0 to be updated, got %v", updated)
+	}
+	if strings.Join(puts, ",") != "/backends/web/servers/stable-0" {
+		t.Errorf("unexpected updates: %v", puts)
+	}
+}
+
+func TestUpdateServerWeights_DuringTransaction(t *testing.T) {
+	client := &Client{currentTransactionID: "tx"}
+	if _, err := client.UpdateServerWeights(context.Background(), &models.Backend{}); err == nil {
+		t.Error("expected an error during a transaction")
+	}
+}
@@ -0,0 +1,147 @@
+package haproxyclient
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+// Defaults of the circuit breaker of the HAProxy client.
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 30 * time.Second
+)
+
+// ErrUnavailable is returned without making the request while the circuit breaker is open, because
+// the Data Plane API failed to answer the previous requests.
+var ErrUnavailable = errors.New("HAProxy Data Plane API is unavailable")
+
+// circuitBreaker short-circuits requests while the Data Plane API is down, so that reconciles fail
+// fast instead of each waiting for timeouts and retries. It opens after a number of consecutive
+// failed requests, and after a cooldown lets a single trial request through, which closes it again
+// when it succeeds.
+type circuitBreaker struct {
+	failureThreshold int
+	cooldown         time.Duration
+	now              func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	open     bool
+	trial    bool // A trial request is in flight after the cooldown
+}
+
+// newCircuitBreaker returns the circuit breaker of the configuration, with defaults for unset
+// values, or nil when it is disabled by a negative BreakerFailureThreshold.
+func newCircuitBreaker(config HAProxyConfig) *circuitBreaker {
+	breaker := &circuitBreaker{
+		failureThreshold: config.BreakerFailureThreshold,
+		cooldown:         config.BreakerCooldown,
+		now:              time.Now,
+	}
+	if breaker.failureThreshold < 0 {
+		return nil
+	}
+	if breaker.failureThreshold == 0 {
+		breaker.failureThreshold = defaultBreakerFailureThreshold
+	}
+	if breaker.cooldown == 0 {
+		breaker.cooldown = defaultBreakerCooldown
+	}
+	return breaker
+}
+
+// allow returns whether a request may be made.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return true
+	}
+	if b.trial || b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record records the result of a request, opening or closing the breaker.
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if !failed {
+		if b.open {
+			logf.Log.Info("HAProxy Data Plane API is available again")
+		}
+		b.failures = 0
+		b.setOpen(false)
+		return
+	}
+	b.failures++
+	if b.open || b.failures >= b.failureThreshold {
+		if !b.open {
+			logf.Log.Info("HAProxy Data Plane API is unavailable, short-circuiting requests", "failures", b.failures, "cooldown", b.cooldown)
+		}
+		b.openedAt = b.now()
+		b.setOpen(true)
+	}
+}
+
+// release lets another trial request through after a request that did not complete.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// setOpen sets the state of the breaker and its metric. Callers must hold mu.
+func (b *circuitBreaker) setOpen(open bool) {
+	b.open = open
+	value := 0.0
+	if open {
+		value = 1
+	}
+	monitoring.HAProxyClientCircuitOpen.Set(value)
+}
+
+// breakerTransport makes the requests of a client through a circuit breaker.
+type breakerTransport struct {
+	breaker *circuitBreaker
+	next    http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.breaker.allow() {
+		return nil, ErrUnavailable
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil && req.Context().Err() != nil {
+		// The caller gave up, which says nothing about the Data Plane API
+		t.breaker.release()
+		return resp, err
+	}
+	t.breaker.record(err != nil || unavailableStatus(resp.StatusCode))
+	return resp, err
+}
+
+// unavailableStatus returns whether a status code means that the Data Plane API, or HAProxy behind
+// it, cannot serve requests.
+func unavailableStatus(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+// breakRequests makes the requests of the client through the circuit breaker, if it is enabled.
+func breakRequests(client *resty.Client, breaker *circuitBreaker) {
+	if breaker == nil {
+		return
+	}
+	client.SetTransport(&breakerTransport{breaker: breaker, next: client.GetClient().Transport})
+}
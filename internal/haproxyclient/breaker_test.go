@@ -0,0 +1,111 @@
+package haproxyclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := newCircuitBreaker(HAProxyConfig{BreakerFailureThreshold: 2, BreakerCooldown: time.Minute})
+	breaker.now = func() time.Time { return now }
+
+	breaker.record(true)
+	if !breaker.allow() {
+		t.Fatal("expected requests to be allowed below the failure threshold")
+	}
+	breaker.record(true)
+	if breaker.allow() {
+		t.Fatal("expected requests to be short-circuited after the failure threshold")
+	}
+	if testutil.ToFloat64(monitoring.HAProxyClientCircuitOpen) != 1 {
+		t.Error("expected the open circuit to be reported")
+	}
+
+	now = now.Add(time.Minute)
+	if !breaker.allow() {
+		t.Fatal("expected a trial request after the cooldown")
+	}
+	if breaker.allow() {
+		t.Fatal("expected a single trial request at a time")
+	}
+	breaker.record(true)
+	if breaker.allow() {
+		t.Fatal("expected a failed trial request to open the circuit again")
+	}
+
+	now = now.Add(time.Minute)
+	if !breaker.allow() {
+		t.Fatal("expected a trial request after the cooldown")
+	}
+	breaker.record(false)
+	if !breaker.allow() || !breaker.allow() {
+		t.Error("expected a successful trial request to close the circuit")
+	}
+	if testutil.ToFloat64(monitoring.HAProxyClientCircuitOpen) != 0 {
+		t.Error("expected the closed circuit to be reported")
+	}
+}
+
+func TestCircuitBreaker_Disabled(t *testing.T) {
+	if newCircuitBreaker(HAProxyConfig{BreakerFailureThreshold: -1}) != nil {
+		t.Error("expected a negative failure threshold to disable the circuit breaker")
+	}
+}
+
+func TestCircuitBreaker_ShortCircuitsRequests(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	client := NewHAProxyClient(HAProxyConfig{
+		BaseURL:                 ts.URL,
+		Timeout:                 5 * time.Second,
+		MaxRetries:              -1,
+		BreakerFailureThreshold: 2,
+		BreakerCooldown:         time.Hour,
+	})
+
+	for range 2 {
+		if _, err := client.GetConfigVersion(context.Background()); !IsRetryable(err) {
+			t.Fatalf("expected a retryable error while the circuit is closed, got %v", err)
+		}
+	}
+	_, err := client.GetConfigVersion(context.Background())
+	if !IsUnavailable(err) {
+		t.Errorf("expected the request to be short-circuited, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests to reach the Data Plane API, got %d", requests)
+	}
+}
+
+func TestCircuitBreaker_NotRetried(t *testing.T) {
+	requests := 0
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	defer closeFn()
+	client.client.SetTransport(&breakerTransport{
+		breaker: newCircuitBreaker(HAProxyConfig{BreakerFailureThreshold: 1, BreakerCooldown: time.Hour}),
+		next:    http.DefaultTransport,
+	})
+
+	_, err := client.GetConfigVersion(context.Background())
+	if !IsUnavailable(err) {
+		t.Errorf("expected the retry to be short-circuited, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected short-circuited requests not to be retried, got %d requests", requests)
+	}
+}
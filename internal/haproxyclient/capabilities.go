@@ -0,0 +1,84 @@
+package haproxyclient
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// haproxyRelease is a release of HAProxy, from which a feature is supported.
+type haproxyRelease struct {
+	major, minor int
+}
+
+func (r haproxyRelease) String() string {
+	return strconv.Itoa(r.major) + "." + strconv.Itoa(r.minor)
+}
+
+// First releases of HAProxy supporting the features of the configuration that older releases
+// still in use lack.
+var (
+	// http-check rules, such as "http-check send", replaced "option httpchk" arguments in 2.2
+	httpCheckRulesRelease = haproxyRelease{2, 2}
+	// HAProxy releases that support each http-check rule type, when later than the rules themselves
+	httpCheckTypeReleases = map[string]haproxyRelease{
+		"set-var-fmt": {2, 6},
+	}
+	// HAProxy releases that support each balance algorithm, when later than 1.8
+	balanceAlgorithmReleases = map[string]haproxyRelease{
+		"random": {1, 9},
+		"hash":   {2, 6},
+	}
+	serverTemplatesRelease = haproxyRelease{1, 8}
+)
+
+// supports returns whether the version of HAProxy supports the features of a release. Features
+// are assumed supported while the version is unknown, leaving their validation to the Data Plane API.
+func (c *Client) supports(release haproxyRelease) bool {
+	major, minor, ok := parseVersion(c.haproxyVersionOf())
+	if !ok {
+		return true
+	}
+	return major > release.major || major == release.major && minor >= release.minor
+}
+
+// unsupported returns the error of a feature the version of HAProxy does not support.
+func (c *Client) unsupported(feature, field string, release haproxyRelease) error {
+	return ErrUnsupported{Feature: feature, Field: field, Required: release.String(), Version: c.haproxyVersionOf()}
+}
+
+// haproxyVersionOf returns the version of HAProxy, empty until negotiated.
+func (c *Client) haproxyVersionOf() string {
+	c.versionMu.RLock()
+	defer c.versionMu.RUnlock()
+	return c.haproxyVersion
+}
+
+// checkBackend returns an ErrUnsupported error if the backend sets a field that the version of
+// HAProxy does not support, so that it fails with a clear error rather than an opaque rejection by
+// the Data Plane API.
+func (c *Client) checkBackend(backend *models.Backend) error {
+	if backend.Balance != nil && backend.Balance.Algorithm != nil {
+		algorithm := *backend.Balance.Algorithm
+		if release, ok := balanceAlgorithmReleases[algorithm]; ok && !c.supports(release) {
+			return c.unsupported(fmt.Sprintf("balance algorithm %q", algorithm), "balance.algorithm", release)
+		}
+	}
+	for i, check := range backend.HTTPCheckList {
+		if check == nil {
+			continue
+		}
+		field := fmt.Sprintf("http_check_list[%d].type", i)
+		if !c.supports(httpCheckRulesRelease) {
+			return c.unsupported("http-check rules", field, httpCheckRulesRelease)
+		}
+		if release, ok := httpCheckTypeReleases[check.Type]; ok && !c.supports(release) {
+			return c.unsupported(fmt.Sprintf("http-check %s", check.Type), field, release)
+		}
+	}
+	if len(backend.ServerTemplates) > 0 && !c.supports(serverTemplatesRelease) {
+		return c.unsupported("server templates", "server_templates", serverTemplatesRelease)
+	}
+	return nil
+}
@@ -1,12 +1,19 @@
 package haproxyclient
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/ullbergm/external-haproxy-operator/monitoring"
 
 	"github.com/go-resty/resty/v2"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // Transaction represents a HAProxy Data Plane API transaction
@@ -14,6 +21,20 @@ type Transaction struct {
 	ID      string `json:"id"`
 	Version int    `json:"_version"`
 	Status  string `json:"status"`
+	// ReloadID is the ID of the HAProxy reload applying a committed transaction, when the Data Plane
+	// API accepted the commit with a reload pending
+	ReloadID string `json:"-"`
+	// Snapshot is the configuration from before a committed transaction, to restore if the reload
+	// fails. It is nil if the snapshot could not be taken.
+	Snapshot *ConfigSnapshot `json:"-"`
+}
+
+// TransactionTracker records the transactions started by the client until they are committed or
+// deleted, so that the transactions left behind when deleting them fails, or when the operator
+// stops in the middle of one, can be deleted later.
+type TransactionTracker interface {
+	Track(ctx context.Context, id string) error
+	Untrack(ctx context.Context, id string) error
 }
 
 // Client provides HAProxy Data Plane API operations
@@ -22,6 +43,16 @@ type Client struct {
 	config               HAProxyConfig
 	currentTransactionID string
 	transactionDirty     bool // Tracks if any create, update, or delete was performed
+	retry                retryPolicy
+	api                  dialect // Layout of the version of the Data Plane API, v3 until negotiated
+	haproxyVersion       string  // Version of HAProxy, empty until negotiated
+
+	// versionMu guards api and haproxyVersion, which a negotiation sets while requests are made, and
+	// the outcome of the last negotiation. negotiateMu serializes the negotiations.
+	versionMu       sync.RWMutex
+	negotiateMu     sync.Mutex
+	negotiateErr    error
+	lastNegotiation time.Time
 }
 
 // Config holds the configuration for the HAProxy client
@@ -30,8 +61,34 @@ type HAProxyConfig struct {
 	Username string
 	Password string
 	Timeout  time.Duration
+	// MaxRetries is the number of times a request failing with a retryable error is retried, and a
+	// transaction failing with a conflict is restarted. Defaults to 3, a negative value disables retries.
+	MaxRetries int
+	// RetryWaitTime and RetryMaxWaitTime bound the jittered exponential backoff between retries.
+	// They default to 200ms and 2s.
+	RetryWaitTime    time.Duration
+	RetryMaxWaitTime time.Duration
+	// BreakerFailureThreshold is the number of consecutive requests failing to reach the Data Plane
+	// API after which requests are short-circuited with ErrUnavailable. Defaults to 5, a negative
+	// value disables the circuit breaker.
+	BreakerFailureThreshold int
+	// BreakerCooldown is the time after which a request is let through again to check whether the
+	// Data Plane API has recovered. Defaults to 30s.
+	BreakerCooldown time.Duration
+	// TLS configures the connection to a Data Plane API served over HTTPS.
+	TLS TLSConfig
+	// Tracker records the transactions started by the client, if set.
+	Tracker TransactionTracker
 }
 
+// Operations of the transaction requests, reported in their errors.
+const (
+	opStartTransaction  = "start transaction"
+	opCommitTransaction = "commit transaction"
+	opDeleteTransaction = "delete transaction"
+	opListTransactions  = "list transactions"
+)
+
 // NewHAProxyClient creates a new HAProxy client that implements the HAProxyClient interface
 func NewHAProxyClient(config HAProxyConfig) HAProxyClient {
 	timeout := config.Timeout
@@ -39,105 +96,146 @@ func NewHAProxyClient(config HAProxyConfig) HAProxyClient {
 		timeout = 30 * time.Second // Default timeout
 	}
 
+	retry := newRetryPolicy(config)
+
 	client := resty.New().
 		SetBasicAuth(config.Username, config.Password).
 		SetDisableWarn(true).
-		SetTimeout(timeout)
+		SetTimeout(timeout).
+		SetTransport(newTransport(config.TLS, config.BaseURL))
+	instrument(client)
+	traceRequests(client)
+	breakRequests(client, newCircuitBreaker(config))
+	retryRequests(client, retry)
 
-	return &Client{
+	c := &Client{
 		client:           client,
 		config:           config,
 		transactionDirty: false,
+		retry:            retry,
+		api:              dialectV3{},
 	}
+	client.OnBeforeRequest(c.requireNegotiation)
+	return c
 }
 
-// StartTransaction starts a new transaction and returns its ID.
-// Requires passing the current config version as a query parameter.
-// Returns a 202 status code if successful, or a 409 error if there are too many transactions.
-// StartTransaction starts a new transaction and sets it as the current transaction.
-func (c *Client) StartTransaction() (Transaction, error) {
-	version, err := c.GetConfigVersion()
+// StartTransaction starts a new transaction at the current configuration version and sets it as the
+// current transaction. Starting it is retried while the Data Plane API has too many transactions.
+func (c *Client) StartTransaction(ctx context.Context) (Transaction, error) {
+	version, err := c.GetConfigVersion(ctx)
 	if err != nil {
-		monitoring.HAProxyClientErrorCountTotal.Inc()
 		return Transaction{}, err
 	}
 
-	resp, err := c.client.R().
+	resp, err := c.client.R().SetContext(ctx).
 		SetQueryParam("version", strconv.FormatInt(version, 10)).
 		SetResult(&Transaction{}).
-		Post(c.config.BaseURL + "/v3/services/haproxy/transactions")
+		Post(c.apiURL("transactions"))
 	if err != nil {
-		monitoring.HAProxyClientErrorCountTotal.Inc()
 		return Transaction{}, err
 	}
-	if resp.StatusCode() == 409 {
-		monitoring.HAProxyClientErrorCountTotal.Inc()
-		return Transaction{}, &APIError{StatusCode: 409, Body: "Too many transactions"}
-	}
 	if resp.IsError() {
-		monitoring.HAProxyClientErrorCountTotal.Inc()
-		return Transaction{}, restyErr(resp)
+		return Transaction{}, newAPIError(resp, opStartTransaction)
 	}
 	tr := resp.Result().(*Transaction)
 	c.currentTransactionID = tr.ID
 	c.transactionDirty = false
+	monitoring.HAProxyClientTransactionsTotal.WithLabelValues(monitoring.TransactionStarted).Inc()
+	if c.config.Tracker != nil {
+		if err := c.config.Tracker.Track(ctx, tr.ID); err != nil {
+			logf.FromContext(ctx).Error(err, "Failed to track HAProxy transaction, it is not swept if it is left behind", "transactionID", tr.ID)
+		}
+	}
 	return *tr, nil
 }
 
-// CommitTransaction commits the transaction with the given ID.
-// The forceReload parameter controls whether to force a reload (default: false).
-// Returns 200 if successfully committed, 202 if accepted and reload requested,
-// 400 for bad request, 404 if not found, 406 if cannot be handled.
-// CommitTransaction commits the current or specified transaction and clears it from the client.
-func (c *Client) CommitTransaction(id string, forceReload bool) (Transaction, error) {
+// CommitTransaction commits the transaction with the given ID, or deletes it when it made no changes,
+// and clears it if it was current. The returned transaction has the ID of the reload applying it.
+func (c *Client) CommitTransaction(ctx context.Context, id string, forceReload bool) (Transaction, error) {
 	if !c.transactionDirty {
 		// No changes, delete the transaction instead of committing
-		err := c.DeleteTransaction(id)
-		if err != nil {
-			monitoring.HAProxyClientErrorCountTotal.Inc()
+		err := c.deleteTransaction(ctx, id)
+		if err == nil {
+			monitoring.HAProxyClientTransactionsTotal.WithLabelValues(monitoring.TransactionNoop).Inc()
 		}
 		c.transactionDirty = false
 		return Transaction{}, err
 	}
 
-	resp, err := c.client.R().
-		SetQueryParam("force_reload", boolToString(forceReload)).
-		SetResult(&Transaction{}).
-		Put(c.config.BaseURL + "/v3/services/haproxy/transactions/" + id)
+	// The committed configuration is left unchanged by the transaction until it is committed
+	snapshot, err := c.GetRawConfiguration(ctx)
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to take a snapshot of the HAProxy configuration, the commit cannot be rolled back")
+	}
+
+	tr, resp, err := c.commitTransaction(ctx, id, forceReload)
 	if err != nil {
-		monitoring.HAProxyClientErrorCountTotal.Inc()
 		return Transaction{}, err
 	}
 
-	switch resp.StatusCode() {
-	case 200, 202:
-		tr := resp.Result().(*Transaction)
-		if c.currentTransactionID == id {
-			c.currentTransactionID = ""
+	monitoring.HAProxyClientTransactionsTotal.WithLabelValues(monitoring.TransactionCommitted).Inc()
+	tr.Snapshot = snapshot
+	if resp != nil && resp.StatusCode() == 202 {
+		// The Data Plane API accepted the commit and reloads HAProxy
+		monitoring.HAProxyClientReloadCommitsTotal.Inc()
+		tr.ReloadID = resp.Header().Get("Reload-ID")
+	}
+	if c.currentTransactionID == id {
+		c.currentTransactionID = ""
+	}
+	c.untrack(ctx, id)
+	return *tr, nil
+}
+
+// commitTransaction commits the transaction, and commits it again when the commit fails with a
+// retryable error while the transaction is still in progress. A commit failing without a response
+// may have been applied, in which case the transaction no longer exists: it is then returned as
+// committed without a response, and the reload applying it is not known.
+func (c *Client) commitTransaction(ctx context.Context, id string, forceReload bool) (*Transaction, *resty.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.client.R().SetContext(ctx).
+			SetQueryParam("force_reload", boolToString(forceReload)).
+			SetResult(&Transaction{}).
+			Put(c.apiURL("transactions/" + url.PathEscape(id)))
+		if err == nil && !resp.IsError() {
+			return resp.Result().(*Transaction), resp, nil
+		}
+		if err == nil {
+			err = newAPIError(resp, opCommitTransaction)
+		}
+		if !IsRetryable(err) || attempt >= c.retry.maxRetries {
+			return nil, nil, err
+		}
+
+		status, getErr := c.transactionStatus(ctx, id)
+		if IsNotFound(getErr) {
+			logf.FromContext(ctx).Info("HAProxy transaction was committed without a response", "transactionID", id)
+			return &Transaction{ID: id, Status: "success"}, nil, nil
 		}
-		return *tr, nil
-	case 400:
-		monitoring.HAProxyClientErrorCountTotal.Inc()
-		return Transaction{}, &APIError{StatusCode: 400, Body: "Bad request"}
-	case 404:
-		monitoring.HAProxyClientErrorCountTotal.Inc()
-		return Transaction{}, &APIError{StatusCode: 404, Body: "Resource not found"}
-	case 406:
-		monitoring.HAProxyClientErrorCountTotal.Inc()
-		return Transaction{}, &APIError{StatusCode: 406, Body: "Resource cannot be handled"}
-	default:
-		if resp.IsError() {
-			monitoring.HAProxyClientErrorCountTotal.Inc()
-			return Transaction{}, restyErr(resp)
+		if getErr != nil || status != "in_progress" {
+			return nil, nil, err
 		}
-		tr := resp.Result().(*Transaction)
-		if c.currentTransactionID == id {
-			c.currentTransactionID = ""
+		monitoring.HAProxyClientRetriesTotal.WithLabelValues(operationName(http.MethodPut, c.apiURL("transactions/"+url.PathEscape(id)))).Inc()
+		if err := c.retry.backoff(ctx, attempt); err != nil {
+			return nil, nil, err
 		}
-		return *tr, nil
 	}
 }
 
+// transactionStatus returns the status of the transaction.
+func (c *Client) transactionStatus(ctx context.Context, id string) (string, error) {
+	resp, err := c.client.R().SetContext(ctx).
+		SetResult(&Transaction{}).
+		Get(c.apiURL("transactions/" + url.PathEscape(id)))
+	if err != nil {
+		return "", err
+	}
+	if resp.IsError() {
+		return "", newAPIError(resp, "get transaction")
+	}
+	return resp.Result().(*Transaction).Status, nil
+}
+
 // boolToString converts a bool to its string representation ("true" or "false").
 func boolToString(b bool) string {
 	if b {
@@ -146,57 +244,156 @@ func boolToString(b bool) string {
 	return "false"
 }
 
-// DeleteTransaction deletes (aborts) the transaction with the given ID.
-// Returns nil if deleted (204), or APIError if not found (404).
 // DeleteTransaction deletes (aborts) the transaction with the given ID and clears it if it was current.
-func (c *Client) DeleteTransaction(id string) error {
-	resp, err := c.client.R().
-		Delete(c.config.BaseURL + "/v3/services/haproxy/transactions/" + id)
+// It returns an APIError if the transaction does not exist.
+func (c *Client) DeleteTransaction(ctx context.Context, id string) error {
+	if err := c.deleteTransaction(ctx, id); err != nil {
+		return err
+	}
+	monitoring.HAProxyClientTransactionsTotal.WithLabelValues(monitoring.TransactionAborted).Inc()
+	return nil
+}
+
+// deleteTransaction deletes the transaction with the given ID and clears it if it was current.
+func (c *Client) deleteTransaction(ctx context.Context, id string) error {
+	resp, err := c.client.R().SetContext(ctx).
+		Delete(c.apiURL("transactions/" + url.PathEscape(id)))
 	if err != nil {
-		monitoring.HAProxyClientErrorCountTotal.Inc()
 		return err
 	}
-	switch resp.StatusCode() {
-	case 204:
-		if c.currentTransactionID == id {
-			c.currentTransactionID = ""
-		}
-		return nil
-	case 404:
-		monitoring.HAProxyClientErrorCountTotal.Inc()
-		return &APIError{StatusCode: 404, Body: "Transaction not found"}
-	default:
-		if resp.IsError() {
-			monitoring.HAProxyClientErrorCountTotal.Inc()
-			return restyErr(resp)
+	if resp.IsError() {
+		apiErr := newAPIError(resp, opDeleteTransaction)
+		if apiErr.Category() == CategoryNotFound {
+			// Deleted already, or expired by the Data Plane API
+			c.untrack(ctx, id)
 		}
-		return nil
+		return apiErr
+	}
+	if c.currentTransactionID == id {
+		c.currentTransactionID = ""
 	}
+	c.untrack(ctx, id)
+	return nil
 }
 
-// restyErr extracts error details from a resty.Response
-func restyErr(resp *resty.Response) error {
-	// Could we just do this and assume the restyErr function is called every time?
-	// monitoring.HAProxyClientErrorCountTotal.Inc()
-	return &APIError{StatusCode: resp.StatusCode(), Body: resp.String()}
+// untrack stops tracking a transaction that was committed or deleted. A failure is only logged, as
+// the transaction is then swept later, and deleting it again is harmless.
+func (c *Client) untrack(ctx context.Context, id string) {
+	if c.config.Tracker == nil {
+		return
+	}
+	if err := c.config.Tracker.Untrack(ctx, id); err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to stop tracking HAProxy transaction", "transactionID", id)
+	}
 }
 
-// APIError represents an error from the HAProxy Data Plane API
-type APIError struct {
-	StatusCode int
-	Body       string
+// ListTransactions returns the transactions of the Data Plane API still in progress, started by
+// any of its clients.
+func (c *Client) ListTransactions(ctx context.Context) ([]Transaction, error) {
+	var transactions []Transaction
+	resp, err := c.client.R().SetContext(ctx).
+		SetQueryParam("status", "in_progress").
+		SetResult(&transactions).
+		Get(c.apiURL("transactions"))
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, newAPIError(resp, opListTransactions)
+	}
+	return transactions, nil
 }
 
-func (e *APIError) Error() string {
-	return "HAProxy API error: " + e.Body
+// RunTransaction runs apply in a new transaction and commits it, or deletes it when apply fails.
+// When apply or the commit fails with a conflict, because the configuration changed since the
+// transaction started, apply runs again in a new transaction started from the fresh configuration
+// version, up to MaxRetries times. It returns the committed transaction, which has no ID when
+// apply made no changes.
+func (c *Client) RunTransaction(ctx context.Context, apply func(ctx context.Context) error) (Transaction, error) {
+	var committed Transaction
+	attempts := 0
+	err := c.retryConflicts(ctx, func() error {
+		if attempts > 0 {
+			monitoring.HAProxyClientTransactionsTotal.WithLabelValues(monitoring.TransactionRestarted).Inc()
+		}
+		attempts++
+		var err error
+		committed, err = c.runTransaction(ctx, apply)
+		return err
+	})
+	return committed, err
+}
+
+// runTransaction runs apply in a new transaction and commits it. The transaction is deleted on every
+// path that does not commit it, including a panic in apply.
+func (c *Client) runTransaction(ctx context.Context, apply func(ctx context.Context) error) (Transaction, error) {
+	transaction, err := c.StartTransaction(ctx)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("starting transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			c.abortTransaction(ctx, transaction.ID)
+		}
+	}()
+
+	if err := apply(ctx); err != nil {
+		return Transaction{}, err
+	}
+	if c.transactionDirty {
+		// HAProxy rejects an invalid configuration on commit with a less specific error, if any
+		var invalid ErrInvalidConfiguration
+		if err := c.ValidateTransaction(ctx, transaction.ID); errors.As(err, &invalid) {
+			return Transaction{}, err
+		} else if err != nil {
+			logf.FromContext(ctx).Error(err, "Failed to validate HAProxy transaction, committing it unvalidated", "transactionID", transaction.ID)
+		}
+	}
+	result, err := c.CommitTransaction(ctx, transaction.ID, false)
+	if err != nil {
+		// A transaction that failed to commit is kept by the Data Plane API
+		return Transaction{}, fmt.Errorf("committing transaction: %w", err)
+	}
+	committed = true
+	return result, nil
+}
+
+// abortTransaction deletes the transaction after a failure, and clears it even if the deletion
+// fails, so that the next requests are not made in it. The transaction is deleted even when the
+// context was cancelled, which is often the failure.
+func (c *Client) abortTransaction(ctx context.Context, id string) {
+	ctx = context.WithoutCancel(ctx)
+	if err := c.DeleteTransaction(ctx, id); err != nil && !IsNotFound(err) {
+		logf.FromContext(ctx).Error(err, "Failed to delete HAProxy transaction", "transactionID", id)
+	}
+	if c.currentTransactionID == id {
+		c.currentTransactionID = ""
+	}
+	c.transactionDirty = false
+}
+
+// retryConflicts runs fn again while it fails with a conflict, waiting a jittered backoff between
+// attempts, up to MaxRetries times. fn must read the configuration version again on each attempt.
+func (c *Client) retryConflicts(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !IsConflict(err) || attempt >= c.retry.maxRetries {
+			return err
+		}
+		logf.FromContext(ctx).V(1).Info("Retrying after a configuration conflict", "attempt", attempt+1, "error", err.Error())
+		if err := c.retry.backoff(ctx, attempt); err != nil {
+			return err
+		}
+	}
 }
 
 // getVersionOrTransactionParam returns the query key and value for version or transaction_id
-func (c *Client) getVersionOrTransactionParam() (string, string, error) {
+func (c *Client) getVersionOrTransactionParam(ctx context.Context) (string, string, error) {
 	if c.currentTransactionID != "" {
 		return "transaction_id", c.currentTransactionID, nil
 	}
-	version, err := c.GetConfigVersion()
+	version, err := c.GetConfigVersion(ctx)
 	if err != nil {
 		return "", "", err
 	}
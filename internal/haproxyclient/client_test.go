@@ -0,0 +1,166 @@
+package haproxyclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeTracker records the transactions tracked by the client.
+type fakeTracker struct {
+	mu      sync.Mutex
+	tracked map[string]bool
+	events  []string
+}
+
+func (t *fakeTracker) Track(ctx context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tracked == nil {
+		t.tracked = map[string]bool{}
+	}
+	t.tracked[id] = true
+	t.events = append(t.events, "track "+id)
+	return nil
+}
+
+func (t *fakeTracker) Untrack(ctx context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.tracked, id)
+	t.events = append(t.events, "untrack "+id)
+	return nil
+}
+
+// newTestTransactionServer returns a client of a Data Plane API starting transaction tx1, and the
+// transactions it deleted.
+func newTestTransactionServer(t *testing.T, deleteStatus int) (*Client, *fakeTracker, *[]string, func()) {
+	t.Helper()
+	var deleted []string
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Path == "/v3/services/haproxy/transactions" {
+				fmt.Fprint(w, `[{"id":"tx1","_version":1,"status":"in_progress"}]`)
+				return
+			}
+			fmt.Fprint(w, "1")
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":"tx1"}`)
+		case http.MethodPut:
+			fmt.Fprint(w, `{"id":"tx1","status":"success"}`)
+		case http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(deleteStatus)
+		}
+	})
+	tracker := &fakeTracker{}
+	client.config.Tracker = tracker
+	return client, tracker, &deleted, closeFn
+}
+
+func TestRunTransaction_TracksTransaction(t *testing.T) {
+	client, tracker, deleted, closeFn := newTestTransactionServer(t, http.StatusNoContent)
+	defer closeFn()
+
+	if _, err := client.RunTransaction(context.Background(), func(ctx context.Context) error {
+		client.transactionDirty = true
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*deleted) != 0 {
+		t.Errorf("expected the committed transaction not to be deleted, got %v", *deleted)
+	}
+	if want := []string{"track tx1", "untrack tx1"}; !reflect.DeepEqual(tracker.events, want) {
+		t.Errorf("expected %v, got %v", want, tracker.events)
+	}
+}
+
+func TestRunTransaction_AbortsOnPanic(t *testing.T) {
+	client, tracker, deleted, closeFn := newTestTransactionServer(t, http.StatusNoContent)
+	defer closeFn()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic of apply to be propagated")
+			}
+		}()
+		_, _ = client.RunTransaction(context.Background(), func(ctx context.Context) error {
+			panic("apply failed")
+		})
+	}()
+	if len(*deleted) != 1 || client.currentTransactionID != "" {
+		t.Errorf("expected the transaction to be deleted, got %v and current transaction %q", *deleted, client.currentTransactionID)
+	}
+	if len(tracker.tracked) != 0 {
+		t.Errorf("expected the deleted transaction not to be tracked, got %v", tracker.tracked)
+	}
+}
+
+func TestRunTransaction_AbortsWhenCancelled(t *testing.T) {
+	client, tracker, deleted, closeFn := newTestTransactionServer(t, http.StatusNoContent)
+	defer closeFn()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := client.RunTransaction(ctx, func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	})
+	if err == nil {
+		t.Fatal("expected the cancellation to fail the transaction")
+	}
+	if len(*deleted) != 1 {
+		t.Errorf("expected the transaction to be deleted after the cancellation, got %v", *deleted)
+	}
+	if len(tracker.tracked) != 0 {
+		t.Errorf("expected the deleted transaction not to be tracked, got %v", tracker.tracked)
+	}
+}
+
+func TestRunTransaction_KeepsTrackingWhenDeleteFails(t *testing.T) {
+	client, tracker, _, closeFn := newTestTransactionServer(t, http.StatusInternalServerError)
+	defer closeFn()
+
+	_, _ = client.RunTransaction(context.Background(), func(ctx context.Context) error {
+		return &APIError{StatusCode: http.StatusBadRequest, Operation: "update backend"}
+	})
+	if !tracker.tracked["tx1"] {
+		t.Errorf("expected the transaction left behind to stay tracked, got %v", tracker.events)
+	}
+	if client.currentTransactionID != "" {
+		t.Errorf("expected the transaction to be cleared, got %q", client.currentTransactionID)
+	}
+}
+
+func TestDeleteTransaction_UntracksMissingTransaction(t *testing.T) {
+	client, tracker, _, closeFn := newTestTransactionServer(t, http.StatusNotFound)
+	defer closeFn()
+	tracker.tracked = map[string]bool{"tx1": true}
+
+	if err := client.DeleteTransaction(context.Background(), "tx1"); !IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	if len(tracker.tracked) != 0 {
+		t.Errorf("expected the missing transaction not to be tracked, got %v", tracker.tracked)
+	}
+}
+
+func TestListTransactions(t *testing.T) {
+	client, _, _, closeFn := newTestTransactionServer(t, http.StatusNoContent)
+	defer closeFn()
+
+	transactions, err := client.ListTransactions(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []Transaction{{ID: "tx1", Version: 1, Status: "in_progress"}}; !reflect.DeepEqual(transactions, want) {
+		t.Errorf("expected %v, got %v", want, transactions)
+	}
+}
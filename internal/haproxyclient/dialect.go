@@ -0,0 +1,234 @@
+package haproxyclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// section identifies the configuration section a resource belongs to, such as the backend of a
+// server. The zero section is used for sections themselves.
+type section struct {
+	kind string // backend, frontend or resolver
+	name string
+}
+
+func backendSection(name string) section  { return section{kind: "backend", name: name} }
+func frontendSection(name string) section { return section{kind: "frontend", name: name} }
+func resolverSection(name string) section { return section{kind: "resolver", name: name} }
+
+// dialect is the layout of a major version of the Data Plane API: where its resources are, and how
+// its responses wrap them. The rest of the client is shared between the versions.
+type dialect interface {
+	// name returns the API version, such as "v3".
+	name() string
+	// configuration returns the path of the collection of configuration resources of a section,
+	// such as the servers of a backend, or of the resource named name in it.
+	configuration(parent section, collection, name string) string
+	// path returns the path of a non-configuration resource, such as "transactions".
+	path(elem string) string
+	// runtimeServers returns the path of the runtime state of the servers of a backend.
+	runtimeServers(backend string) string
+	// unwrap returns the resource or the list of resources in the body of a configuration response.
+	unwrap(body []byte) ([]byte, error)
+	// replacesLists returns whether the lists of a section, such as its HTTP checks, are replaced in
+	// a single request, rather than item by item.
+	replacesLists() bool
+}
+
+// dialectV3 is the layout of the Data Plane API v3, which nests resources under their section.
+type dialectV3 struct{}
+
+func (dialectV3) name() string { return "v3" }
+
+func (dialectV3) configuration(parent section, collection, name string) string {
+	path := "/v3/services/haproxy/configuration/"
+	if parent.kind != "" {
+		path += parent.kind + "s/" + url.PathEscape(parent.name) + "/"
+	}
+	path += collection
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	return path
+}
+
+func (dialectV3) path(elem string) string { return "/v3/services/haproxy/" + elem }
+
+func (dialectV3) runtimeServers(backend string) string {
+	return "/v3/services/haproxy/runtime/backends/" + url.PathEscape(backend) + "/servers"
+}
+
+func (dialectV3) unwrap(body []byte) ([]byte, error) { return body, nil }
+
+func (dialectV3) replacesLists() bool { return true }
+
+// dialectV2 is the layout of the Data Plane API v2, which selects the section of a resource with
+// query parameters and wraps configuration resources in an object with the configuration version.
+type dialectV2 struct{}
+
+func (dialectV2) name() string { return "v2" }
+
+func (dialectV2) configuration(parent section, collection, name string) string {
+	path := "/v2/services/haproxy/configuration/" + collection
+	if name != "" {
+		path += "/" + url.PathEscape(name)
+	}
+	if parent.kind == "" {
+		return path
+	}
+	query := url.Values{}
+	if collection == "http_checks" {
+		query.Set("parent_type", parent.kind)
+		query.Set("parent_name", parent.name)
+	} else {
+		query.Set(parent.kind, parent.name)
+	}
+	return path + "?" + query.Encode()
+}
+
+func (dialectV2) path(elem string) string { return "/v2/services/haproxy/" + elem }
+
+func (dialectV2) runtimeServers(backend string) string {
+	return "/v2/services/haproxy/runtime/servers?" + url.Values{"backend": {backend}}.Encode()
+}
+
+func (dialectV2) unwrap(body []byte) ([]byte, error) {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+	return envelope.Data, nil
+}
+
+func (dialectV2) replacesLists() bool { return false }
+
+// dialectFor returns the dialect of an API version, such as "v2.9.1 2c54b1f".
+func dialectFor(apiVersion string) (dialect, error) {
+	major, _, ok := parseVersion(apiVersion)
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("unrecognized Data Plane API version %q", apiVersion)
+	case major == 2:
+		return dialectV2{}, nil
+	case major == 3:
+		return dialectV3{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported Data Plane API version %q, expected v2 or v3", apiVersion)
+	}
+}
+
+// parseVersion returns the major and minor numbers of a version such as "v2.9.1 2c54b1f" or
+// "2.8.5-1ppa1~jammy".
+func parseVersion(version string) (int, int, bool) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if end := strings.IndexFunc(version, func(r rune) bool { return r != '.' && !unicode.IsDigit(r) }); end >= 0 {
+		version = version[:end]
+	}
+	parts := strings.Split(version, ".")
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor := 0
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+	return major, minor, true
+}
+
+// configurationURL returns the URL of configuration resources, see dialect.configuration.
+func (c *Client) configurationURL(parent section, collection, name string) string {
+	return c.config.BaseURL + c.dialect().configuration(parent, collection, name)
+}
+
+// apiURL returns the URL of a non-configuration resource, see dialect.path.
+func (c *Client) apiURL(elem string) string {
+	return c.config.BaseURL + c.dialect().path(elem)
+}
+
+// dialect returns the layout of the version of the Data Plane API.
+func (c *Client) dialect() dialect {
+	c.versionMu.RLock()
+	defer c.versionMu.RUnlock()
+	return c.api
+}
+
+// decode unmarshals the resource or the list of resources of a configuration response into out.
+func (c *Client) decode(resp *resty.Response, out any) error {
+	body, err := c.dialect().unwrap(resp.Body())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, out)
+}
+
+// replaceList replaces the list of a section, such as the HTTP checks of a backend, that has current
+// items. Dialects that do not replace lists in a single request have the current items deleted, from
+// the last so that the indexes of the others do not shift, and the new ones created at their index.
+func replaceList[T any](ctx context.Context, c *Client, parent section, collection string, current int, items []T, operation string) error {
+	c.transactionDirty = true
+	if c.dialect().replacesLists() {
+		return c.write(ctx, http.MethodPut, c.configurationURL(parent, collection, ""), items, operation)
+	}
+	for i := current - 1; i >= 0; i-- {
+		if err := c.write(ctx, http.MethodDelete, c.configurationURL(parent, collection, strconv.Itoa(i)), nil, operation); err != nil {
+			return err
+		}
+	}
+	for i, item := range items {
+		body, err := withIndex(item, i)
+		if err != nil {
+			return err
+		}
+		if err := c.write(ctx, http.MethodPost, c.configurationURL(parent, collection, ""), body, operation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withIndex returns the fields of an item of a list with its index, which the Data Plane API v2
+// requires on the items it creates.
+func withIndex(item any, index int) (map[string]any, error) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["index"] = index
+	return fields, nil
+}
+
+// write makes a request changing the configuration, in the current transaction or at the current
+// configuration version.
+func (c *Client) write(ctx context.Context, method, url string, body any, operation string) error {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
+	if err != nil {
+		return err
+	}
+	req := c.client.R().SetContext(ctx).SetQueryParam(queryKey, queryVal)
+	if body != nil {
+		req.SetHeader("Content-Type", "application/json").SetBody(body)
+	}
+	resp, err := req.Execute(method, url)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return newAPIError(resp, operation)
+	}
+	return nil
+}
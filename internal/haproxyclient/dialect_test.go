@@ -0,0 +1,244 @@
+package haproxyclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+func TestDialectPaths(t *testing.T) {
+	tests := []struct {
+		api  dialect
+		got  string
+		want string
+	}{
+		{dialectV3{}, dialectV3{}.configuration(section{}, "backends", "web"), "/v3/services/haproxy/configuration/backends/web"},
+		{dialectV3{}, dialectV3{}.configuration(backendSection("web"), "servers", "web-0"), "/v3/services/haproxy/configuration/backends/web/servers/web-0"},
+		{dialectV3{}, dialectV3{}.runtimeServers("web"), "/v3/services/haproxy/runtime/backends/web/servers"},
+		{dialectV2{}, dialectV2{}.configuration(section{}, "backends", ""), "/v2/services/haproxy/configuration/backends"},
+		{dialectV2{}, dialectV2{}.configuration(backendSection("web"), "servers", "web-0"), "/v2/services/haproxy/configuration/servers/web-0?backend=web"},
+		{dialectV2{}, dialectV2{}.configuration(backendSection("web"), "http_checks", "1"), "/v2/services/haproxy/configuration/http_checks/1?parent_name=web&parent_type=backend"},
+		{dialectV2{}, dialectV2{}.runtimeServers("web"), "/v2/services/haproxy/runtime/servers?backend=web"},
+		{dialectV2{}, dialectV2{}.path("transactions"), "/v2/services/haproxy/transactions"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got path %q, expected %q", tt.api.name(), tt.got, tt.want)
+		}
+	}
+}
+
+func TestDialectFor(t *testing.T) {
+	tests := map[string]string{
+		"v2.9.1 2c54b1f": "v2",
+		"v3.0.1 4c2e6a1": "v3",
+		"3.1.0":          "v3",
+	}
+	for version, want := range tests {
+		api, err := dialectFor(version)
+		if err != nil {
+			t.Fatalf("dialectFor(%q): unexpected error: %v", version, err)
+		}
+		if api.name() != want {
+			t.Errorf("dialectFor(%q) = %s, expected %s", version, api.name(), want)
+		}
+	}
+	for _, version := range []string{"v1.2.4", "unknown"} {
+		if _, err := dialectFor(version); err == nil {
+			t.Errorf("dialectFor(%q): expected an error", version)
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string][2]int{
+		"2.8.5-1ppa1~jammy": {2, 8},
+		"v2.9.1 2c54b1f":    {2, 9},
+		"3.0-dev7":          {3, 0},
+		"1":                 {1, 0},
+	}
+	for version, want := range tests {
+		major, minor, ok := parseVersion(version)
+		if !ok || major != want[0] || minor != want[1] {
+			t.Errorf("parseVersion(%q) = %d, %d, %v, expected %d, %d", version, major, minor, ok, want[0], want[1])
+		}
+	}
+	if _, _, ok := parseVersion(""); ok {
+		t.Error("expected an empty version not to parse")
+	}
+}
+
+// newV2Handler returns a handler serving the info endpoints of a Data Plane API v2 running in front
+// of the HAProxy version, and passing the other requests to next.
+func newV2Handler(haproxyVersion string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/info":
+			w.WriteHeader(http.StatusNotFound)
+		case "/v2/info":
+			fmt.Fprint(w, `{"api":{"version":"v2.9.1 2c54b1f"},"system":{}}`)
+		case "/v2/services/haproxy/runtime/info":
+			fmt.Fprintf(w, `[{"info":{"version":%q},"runtimeAPI":"/var/run/haproxy.sock"}]`, haproxyVersion)
+		default:
+			next(w, r)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/info":
+			fmt.Fprint(w, `{"api":{"version":"v3.0.1 4c2e6a1"},"system":{}}`)
+		case "/v3/services/haproxy/runtime/info":
+			fmt.Fprint(w, `{"info":{"version":"3.0.5-1"},"runtimeAPI":"/var/run/haproxy.sock"}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer closeFn()
+
+	info, err := client.Negotiate(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.APIVersion != "v3.0.1 4c2e6a1" || info.HAProxyVersion != "3.0.5-1" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if client.api.name() != "v3" {
+		t.Errorf("expected the v3 dialect, got %s", client.api.name())
+	}
+}
+
+func TestNegotiate_V2(t *testing.T) {
+	client, closeFn := newTestHAProxyClientWithServer(t, newV2Handler("2.4.22", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/services/haproxy/configuration/version":
+			fmt.Fprint(w, "7")
+		case r.URL.Path == "/v2/services/haproxy/configuration/backends/web" && r.URL.Query().Get("version") == "7":
+			fmt.Fprint(w, `{"_version":7,"data":{"name":"web","mode":"http"}}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+	defer closeFn()
+
+	info, err := client.Negotiate(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.APIVersion != "v2.9.1 2c54b1f" || info.HAProxyVersion != "2.4.22" {
+		t.Errorf("unexpected info %+v", info)
+	}
+
+	backend, err := client.GetBackend(context.Background(), "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if backend == nil || backend.Name != "web" || backend.Mode != "http" {
+		t.Errorf("expected the backend to be unwrapped from the v2 response, got %+v", backend)
+	}
+}
+
+func TestEnsureBackendHTTPCheck_V2ReplacesItemByItem(t *testing.T) {
+	var requests []string
+	client, closeFn := newTestHAProxyClientWithServer(t, newV2Handler("2.4.22", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/services/haproxy/configuration/version" {
+			fmt.Fprint(w, "3")
+			return
+		}
+		if r.URL.Query().Get("parent_type") != "backend" || r.URL.Query().Get("parent_name") != "web" {
+			t.Errorf("expected the backend in the query, got %s", r.URL)
+		}
+		request := r.Method + " " + strings.TrimPrefix(r.URL.Path, "/v2/services/haproxy/configuration/")
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, `{"_version":3,"data":[{"type":"connect"},{"type":"expect"}]}`)
+			return
+		case http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			var fields map[string]any
+			_ = json.Unmarshal(body, &fields)
+			request += fmt.Sprintf(" %v %v", fields["index"], fields["type"])
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+		requests = append(requests, request)
+	}))
+	defer closeFn()
+	if _, err := client.Negotiate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	checks := []*models.HTTPCheck{{Type: "send"}}
+	if err := client.EnsureBackendHTTPCheck(context.Background(), "web", checks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"DELETE http_checks/1", "DELETE http_checks/0", "POST http_checks 0 send"}
+	if strings.Join(requests, ", ") != strings.Join(want, ", ") {
+		t.Errorf("got requests %q, expected %q", requests, want)
+	}
+}
+
+func TestEnsureBackend_Unsupported(t *testing.T) {
+	client, closeFn := newTestHAProxyClientWithServer(t, newV2Handler("2.4.22", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	}))
+	defer closeFn()
+	if _, err := client.Negotiate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hash := "hash"
+	err := client.EnsureBackend(context.Background(), &models.Backend{
+		BackendBase: models.BackendBase{Name: "web", Balance: &models.Balance{Algorithm: &hash}},
+	})
+	if !IsUnsupported(err) {
+		t.Fatalf("expected an unsupported error, got %v", err)
+	}
+	want := ErrUnsupported{Feature: `balance algorithm "hash"`, Field: "balance.algorithm", Required: "2.6", Version: "2.4.22"}
+	if err != want {
+		t.Errorf("got %#v, expected %#v", err, want)
+	}
+}
+
+func TestNegotiate_RetriedByRequests(t *testing.T) {
+	client, closeFn := newTestHAProxyClientWithServer(t, newV2Handler("2.4.22", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/services/haproxy/configuration/version" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		fmt.Fprint(w, "7")
+	}))
+	defer closeFn()
+	baseURL := client.config.BaseURL
+	client.config.BaseURL = "http://127.0.0.1:1"
+	client.retry.maxRetries = 0
+
+	// The Data Plane API is not reachable when the operator starts
+	if _, err := client.Negotiate(context.Background()); err == nil {
+		t.Fatal("expected the negotiation to fail")
+	}
+	client.config.BaseURL = baseURL
+	if _, err := client.GetConfigVersion(context.Background()); !IsUnavailable(err) {
+		t.Errorf("expected requests to fail until negotiated, got %v", err)
+	}
+
+	// The request after the retry interval negotiates again, and is prepared with the v3 layout
+	client.lastNegotiation = client.lastNegotiation.Add(-negotiateRetryInterval)
+	if _, err := client.GetConfigVersion(context.Background()); !IsUnavailable(err) {
+		t.Errorf("expected the request prepared before the negotiation to fail, got %v", err)
+	}
+	if client.api.name() != "v2" || client.haproxyVersion != "2.4.22" {
+		t.Errorf("expected the v2 dialect to be negotiated, got %s", client.api.name())
+	}
+	if version, err := client.GetConfigVersion(context.Background()); err != nil || version != 7 {
+		t.Errorf("expected the next requests to use the v2 layout, got %d, %v", version, err)
+	}
+}
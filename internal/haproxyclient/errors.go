@@ -1,6 +1,41 @@
 package haproxyclient
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrorCategory classifies errors of the HAProxy client by how callers should handle them.
+type ErrorCategory string
+
+const (
+	// CategoryRetryable errors are transient, such as timeouts, unavailable Data Plane APIs and
+	// too many open transactions. The request may succeed when made again later.
+	CategoryRetryable ErrorCategory = "Retryable"
+	// CategoryConflict errors are caused by the configuration changing concurrently. The changes
+	// must be made again from the fresh configuration version.
+	CategoryConflict ErrorCategory = "Conflict"
+	// CategoryNotFound errors are caused by a resource that does not exist.
+	CategoryNotFound ErrorCategory = "NotFound"
+	// CategoryNotManaged errors are caused by a resource that exists but is not managed by this controller.
+	CategoryNotManaged ErrorCategory = "NotManaged"
+	// CategoryInvalid errors are caused by a request the Data Plane API rejects, and fail again
+	// until the request changes.
+	CategoryInvalid ErrorCategory = "Invalid"
+	// CategoryUnavailable errors are returned without making the request while the Data Plane API
+	// is down. Callers should wait for it to recover rather than retry right away.
+	CategoryUnavailable ErrorCategory = "Unavailable"
+	// CategoryUnsupported errors are caused by configuration that the version of HAProxy behind the
+	// Data Plane API does not support, and fail again until the configuration changes.
+	CategoryUnsupported ErrorCategory = "Unsupported"
+	// CategoryUnknown errors fit none of the other categories.
+	CategoryUnknown ErrorCategory = "Unknown"
+)
 
 // ErrNotManaged is returned when trying to modify a resource not managed by this controller
 type ErrNotManaged struct {
@@ -22,13 +57,132 @@ func (e ErrResourceNotFound) Error() string {
 	return fmt.Sprintf("%s %q not found", e.ResourceType, e.ResourceName)
 }
 
-// ErrAPIResponse represents an API error response
-type ErrAPIResponse struct {
+// ErrUnsupported is returned when the configuration sets a field that the version of HAProxy
+// behind the Data Plane API does not support
+type ErrUnsupported struct {
+	// Feature describes the unsupported configuration, such as `balance algorithm "hash"`
+	Feature string
+	// Field is the path of the field in the configuration, such as "balance.algorithm"
+	Field string
+	// Required is the first version of HAProxy that supports the feature
+	Required string
+	// Version is the version of HAProxy
+	Version string
+}
+
+func (e ErrUnsupported) Error() string {
+	return fmt.Sprintf("%s (%s) requires HAProxy %s or later, but the Data Plane API runs HAProxy %s", e.Feature, e.Field, e.Required, e.Version)
+}
+
+// APIError represents an error response from the HAProxy Data Plane API
+type APIError struct {
 	StatusCode int
 	Body       string
 	Operation  string
 }
 
-func (e ErrAPIResponse) Error() string {
+// newAPIError returns the error of an error response to the operation.
+func newAPIError(resp *resty.Response, operation string) *APIError {
+	return &APIError{
+		StatusCode: resp.StatusCode(),
+		Body:       string(resp.Body()),
+		Operation:  operation,
+	}
+}
+
+func (e *APIError) Error() string {
 	return fmt.Sprintf("failed to %s, status: %d, body: %s", e.Operation, e.StatusCode, e.Body)
 }
+
+// Category returns the category of the error response.
+func (e *APIError) Category() ErrorCategory {
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return CategoryNotFound
+	case e.StatusCode == http.StatusConflict && e.Operation == opStartTransaction:
+		// The Data Plane API limits the number of open transactions
+		return CategoryRetryable
+	case e.StatusCode == http.StatusConflict:
+		// The version of the request or of the transaction is outdated
+		return CategoryConflict
+	case e.StatusCode == http.StatusTooManyRequests,
+		e.StatusCode >= http.StatusInternalServerError && e.StatusCode != http.StatusNotImplemented:
+		return CategoryRetryable
+	case e.StatusCode == http.StatusBadRequest,
+		e.StatusCode == http.StatusNotAcceptable,
+		e.StatusCode == http.StatusUnprocessableEntity:
+		return CategoryInvalid
+	default:
+		return CategoryUnknown
+	}
+}
+
+// Category returns the category of an error returned by the HAProxy client.
+func Category(err error) ErrorCategory {
+	var apiErr *APIError
+	var notManaged ErrNotManaged
+	var notFound ErrResourceNotFound
+	var unsupported ErrUnsupported
+	var invalid ErrInvalidConfiguration
+	var urlErr *url.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &apiErr):
+		return apiErr.Category()
+	case errors.As(err, &notManaged):
+		return CategoryNotManaged
+	case errors.As(err, &notFound):
+		return CategoryNotFound
+	case errors.As(err, &unsupported):
+		return CategoryUnsupported
+	case errors.As(err, &invalid):
+		return CategoryInvalid
+	case errors.Is(err, ErrUnavailable):
+		return CategoryUnavailable
+	case errors.Is(err, context.Canceled):
+		// The caller gave up, making the request again would fail the same way
+		return CategoryUnknown
+	case errors.As(err, &urlErr):
+		// The request failed without a response, such as on timeouts and refused connections
+		return CategoryRetryable
+	default:
+		return CategoryUnknown
+	}
+}
+
+// IsRetryable returns whether the error is transient, so that the request may succeed later.
+func IsRetryable(err error) bool {
+	return Category(err) == CategoryRetryable
+}
+
+// IsConflict returns whether the error is caused by the configuration changing concurrently.
+func IsConflict(err error) bool {
+	return Category(err) == CategoryConflict
+}
+
+// IsNotFound returns whether the error is caused by a resource that does not exist.
+func IsNotFound(err error) bool {
+	return Category(err) == CategoryNotFound
+}
+
+// IsNotManaged returns whether the error is caused by a resource not managed by this controller.
+func IsNotManaged(err error) bool {
+	return Category(err) == CategoryNotManaged
+}
+
+// IsUnavailable returns whether the request was short-circuited because the Data Plane API is down.
+func IsUnavailable(err error) bool {
+	return Category(err) == CategoryUnavailable
+}
+
+// IsUnsupported returns whether the error is caused by configuration the version of HAProxy does
+// not support.
+func IsUnsupported(err error) bool {
+	return Category(err) == CategoryUnsupported
+}
+
+// IsInvalid returns whether the error is caused by a request the Data Plane API rejects.
+func IsInvalid(err error) bool {
+	return Category(err) == CategoryInvalid
+}
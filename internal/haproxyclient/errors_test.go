@@ -0,0 +1,39 @@
+package haproxyclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestCategory(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want ErrorCategory
+	}{
+		"nil":                   {nil, ""},
+		"not found":             {&APIError{StatusCode: 404, Operation: "get backend"}, CategoryNotFound},
+		"too many transactions": {&APIError{StatusCode: 409, Operation: opStartTransaction}, CategoryRetryable},
+		"version conflict":      {&APIError{StatusCode: 409, Operation: "update backend"}, CategoryConflict},
+		"unavailable":           {&APIError{StatusCode: 503, Operation: "update backend"}, CategoryRetryable},
+		"not implemented":       {&APIError{StatusCode: 501, Operation: "update backend"}, CategoryUnknown},
+		"bad request":           {&APIError{StatusCode: 400, Operation: "update backend"}, CategoryInvalid},
+		"unauthorized":          {&APIError{StatusCode: 401, Operation: "update backend"}, CategoryUnknown},
+		"wrapped":               {fmt.Errorf("committing transaction: %w", &APIError{StatusCode: 409}), CategoryConflict},
+		"not managed":           {fmt.Errorf("ensuring: %w", ErrNotManaged{ResourceType: "backend", ResourceName: "web"}), CategoryNotManaged},
+		"resource not found":    {ErrResourceNotFound{ResourceType: "backend", ResourceName: "web"}, CategoryNotFound},
+		"unsupported":           {ErrUnsupported{Feature: "server templates", Field: "server_templates"}, CategoryUnsupported},
+		"connection refused":    {&url.Error{Op: "Get", URL: "http://haproxy", Err: errors.New("connection refused")}, CategoryRetryable},
+		"canceled":              {&url.Error{Op: "Get", URL: "http://haproxy", Err: context.Canceled}, CategoryUnknown},
+		"other":                 {errors.New("unmarshaling response"), CategoryUnknown},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Category(tt.err); got != tt.want {
+				t.Errorf("Category(%v) = %q, expected %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
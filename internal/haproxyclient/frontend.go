@@ -1,35 +1,42 @@
 package haproxyclient
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/haproxytech/client-native/v6/models"
 )
 
 // GetFrontend retrieves a frontend by name
-func (c *Client) GetFrontend(name string) (*models.Frontend, error) {
-	queryKey, queryVal, err := c.getVersionOrTransactionParam()
+func (c *Client) GetFrontend(ctx context.Context, name string) (*models.Frontend, error) {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := c.client.R().
+	resp, err := c.client.R().SetContext(ctx).
 		SetHeader("Accept", "application/json").
-		SetResult(&models.Frontend{}).
 		SetQueryParam(queryKey, queryVal).
-		Get(fmt.Sprintf("%s/v3/services/haproxy/configuration/frontends/%s", c.config.BaseURL, name))
+		Get(c.configurationURL(section{}, "frontends", name))
 	if err != nil {
 		return nil, err
 	}
 	if resp.StatusCode() == 404 {
 		return nil, nil
 	}
-	return resp.Result().(*models.Frontend), nil
+	if resp.IsError() {
+		return nil, newAPIError(resp, "get frontend")
+	}
+	frontend := &models.Frontend{}
+	if err := c.decode(resp, frontend); err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	}
+	return frontend, nil
 }
 
 // EnsureFrontend creates or updates a frontend, ensuring it's managed by this controller
-func (c *Client) EnsureFrontend(frontend *models.Frontend) error {
+func (c *Client) EnsureFrontend(ctx context.Context, frontend *models.Frontend) error {
 	frontend.Description = ManagedDescription
 
 	var (
@@ -37,31 +44,31 @@ func (c *Client) EnsureFrontend(frontend *models.Frontend) error {
 		err  error
 	)
 
-	queryKey, queryVal, err := c.getVersionOrTransactionParam()
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
 	if err != nil {
 		return err
 	}
 
-	existing, err := c.GetFrontend(frontend.Name)
+	existing, err := c.GetFrontend(ctx, frontend.Name)
 	if err != nil {
 		return err
 	}
 
 	if existing == nil {
-		resp, err = c.client.R().
+		resp, err = c.client.R().SetContext(ctx).
 			SetHeader("Content-Type", "application/json").
 			SetBody(frontend).
 			SetQueryParam(queryKey, queryVal).
-			Post(fmt.Sprintf("%s/v3/services/haproxy/configuration/frontends", c.config.BaseURL))
+			Post(c.configurationURL(section{}, "frontends", ""))
 		c.transactionDirty = true
 	} else if existing.Description != ManagedDescription {
 		return fmt.Errorf("frontend %q exists but is not managed by this controller; refusing to update or overwrite", frontend.Name)
 	} else if !c.frontendsEqual(existing, frontend) {
-		resp, err = c.client.R().
+		resp, err = c.client.R().SetContext(ctx).
 			SetHeader("Content-Type", "application/json").
 			SetBody(frontend).
 			SetQueryParam(queryKey, queryVal).
-			Put(fmt.Sprintf("%s/v3/services/haproxy/configuration/frontends/%s", c.config.BaseURL, frontend.Name))
+			Put(c.configurationURL(section{}, "frontends", frontend.Name))
 		c.transactionDirty = true
 	}
 
@@ -69,7 +76,7 @@ func (c *Client) EnsureFrontend(frontend *models.Frontend) error {
 		return err
 	}
 	if resp != nil && resp.IsError() {
-		return fmt.Errorf("failed to update frontend, status: %d, body: %s", resp.StatusCode(), resp.Body())
+		return newAPIError(resp, "update frontend")
 	}
 	return nil
 }
@@ -91,21 +98,21 @@ func (c *Client) frontendsEqual(a, b *models.Frontend) bool {
 // Bind functions
 
 // EnsureBind creates or updates a bind configuration for a frontend
-func (c *Client) EnsureBind(frontend string, bind *models.Bind) error {
+func (c *Client) EnsureBind(ctx context.Context, frontend string, bind *models.Bind) error {
 	var (
 		resp *resty.Response
 		err  error
 	)
 
-	url := fmt.Sprintf("%s/v3/services/haproxy/configuration/frontends/%s/binds", c.config.BaseURL, frontend)
+	url := c.configurationURL(frontendSection(frontend), "binds", "")
 
-	queryKey, queryVal, err := c.getVersionOrTransactionParam()
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
 	if err != nil {
 		return err
 	}
 
 	// List all binds for the frontend
-	resp, err = c.client.R().
+	resp, err = c.client.R().SetContext(ctx).
 		SetHeader("Accept", "application/json").
 		Get(url)
 	if err != nil {
@@ -113,7 +120,7 @@ func (c *Client) EnsureBind(frontend string, bind *models.Bind) error {
 	}
 
 	var binds []models.Bind
-	if err := json.Unmarshal(resp.Body(), &binds); err != nil {
+	if err := c.decode(resp, &binds); err != nil {
 		return err
 	}
 
@@ -131,7 +138,7 @@ func (c *Client) EnsureBind(frontend string, bind *models.Bind) error {
 	}
 
 	// Create new bind
-	resp, err = c.client.R().
+	resp, err = c.client.R().SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetBody(bind).
 		SetQueryParam(queryKey, queryVal).
@@ -141,7 +148,7 @@ func (c *Client) EnsureBind(frontend string, bind *models.Bind) error {
 		return err
 	}
 	if resp.IsError() {
-		return fmt.Errorf("failed to create bind, status: %d, body: %s", resp.StatusCode(), resp.Body())
+		return newAPIError(resp, "create bind")
 	}
 	return nil
 }
@@ -162,78 +169,68 @@ func (c *Client) bindsEqual(a, b *models.Bind) bool {
 
 // Backend Switching Rule
 // ListBackendSwitchingRules returns all backend switching rules for a frontend
-func (c *Client) ListBackendSwitchingRules(frontend string) ([]*models.BackendSwitchingRule, error) {
-	resp, err := c.client.R().
+func (c *Client) ListBackendSwitchingRules(ctx context.Context, frontend string) ([]*models.BackendSwitchingRule, error) {
+	resp, err := c.client.R().SetContext(ctx).
 		SetHeader("Accept", "application/json").
-		Get(fmt.Sprintf("%s/v3/services/haproxy/configuration/frontends/%s/backend_switching_rules", c.config.BaseURL, frontend))
+		Get(c.configurationURL(frontendSection(frontend), "backend_switching_rules", ""))
 	if err != nil {
 		return nil, err
 	}
 
 	var rules []*models.BackendSwitchingRule
-	if err := json.Unmarshal(resp.Body(), &rules); err != nil {
+	if err := c.decode(resp, &rules); err != nil {
 		return nil, err
 	}
 	return rules, nil
 }
 
-// EnsureBackendSwitchingRule creates or updates a backend switching rule
-func (c *Client) EnsureBackendSwitchingRule(frontend string, rule *models.BackendSwitchingRule) error {
-	var (
-		resp *resty.Response
-		err  error
-	)
-
-	queryKey, queryVal, err := c.getVersionOrTransactionParam()
-	if err != nil {
-		return err
-	}
-
+// EnsureBackendSwitchingRule creates or updates a backend switching rule. A rule with the same
+// condition is switched to the backend of the given rule in place, so that its position in the
+// rule list is kept; otherwise the rule is appended.
+func (c *Client) EnsureBackendSwitchingRule(ctx context.Context, frontend string, rule *models.BackendSwitchingRule) error {
 	// List all existing rules
-	ruleList, err := c.ListBackendSwitchingRules(frontend)
+	ruleList, err := c.ListBackendSwitchingRules(ctx, frontend)
 	if err != nil {
 		return err
 	}
+	current := len(ruleList)
 
-	// Check if rule already exists
-	for _, r := range ruleList {
-		if r.CondTest == rule.CondTest && r.Name == rule.Name {
+	// Check if a rule with the same condition already exists
+	replaced := false
+	for i, r := range ruleList {
+		if r.Cond != rule.Cond || r.CondTest != rule.CondTest {
+			continue
+		}
+		if r.Name == rule.Name {
 			return nil // Already exists
 		}
+		ruleList[i] = rule
+		replaced = true
+		break
 	}
 
 	// Add new rule and update all rules
-	ruleList = append(ruleList, rule)
-	resp, err = c.client.R().
-		SetHeader("Content-Type", "application/json").
-		SetBody(ruleList).
-		SetQueryParam(queryKey, queryVal).
-		Put(fmt.Sprintf("%s/v3/services/haproxy/configuration/frontends/%s/backend_switching_rules", c.config.BaseURL, frontend))
-	c.transactionDirty = true
-	if err != nil {
-		return err
+	if !replaced {
+		ruleList = append(ruleList, rule)
 	}
-	if resp.IsError() {
-		return fmt.Errorf("failed to upsert backend switching rules, status: %d, body: %s", resp.StatusCode(), resp.Body())
-	}
-	return nil
+	return replaceList(ctx, c, frontendSection(frontend), "backend_switching_rules", current, ruleList, "upsert backend switching rules")
 }
 
 // DeleteBackendSwitchingRuleByIndex deletes a backend switching rule by index
-func (c *Client) DeleteBackendSwitchingRuleByIndex(frontend string, index int64) error {
+func (c *Client) DeleteBackendSwitchingRuleByIndex(ctx context.Context, frontend string, index int64) error {
 	var (
 		resp *resty.Response
 		err  error
 	)
 
-	queryKey, queryVal, err := c.getVersionOrTransactionParam()
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
 	if err != nil {
 		return err
 	}
 
-	resp, err = c.client.R().
+	resp, err = c.client.R().SetContext(ctx).
 		SetQueryParam(queryKey, queryVal).
-		Delete(fmt.Sprintf("%s/v3/services/haproxy/configuration/frontends/%s/backend_switching_rules/%d", c.config.BaseURL, frontend, index))
+		Delete(c.configurationURL(frontendSection(frontend), "backend_switching_rules", strconv.FormatInt(index, 10)))
 	if err != nil {
 		return err
 	}
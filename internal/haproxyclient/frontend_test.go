@@ -0,0 +1,36 @@
+package haproxyclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+func TestEnsureBackendSwitchingRule_ReplacesSameCondition(t *testing.T) {
+	var put []*models.BackendSwitchingRule
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v3/services/haproxy/configuration/frontends/www/backend_switching_rules":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"cond":"if","cond_test":"is_api","name":"api"},{"cond":"if","cond_test":"is_app","name":"app-blue"}]`)
+		case r.Method == http.MethodPut:
+			_ = json.NewDecoder(r.Body).Decode(&put)
+			w.WriteHeader(http.StatusOK)
+		default:
+			fmt.Fprint(w, "1")
+		}
+	})
+	defer closeFn()
+
+	err := client.EnsureBackendSwitchingRule(context.Background(), "www", &models.BackendSwitchingRule{Cond: "if", CondTest: "is_app", Name: "app-green"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(put) != 2 || put[0].Name != "api" || put[1].Name != "app-green" {
+		t.Errorf("expected the is_app rule to be switched in place, got %+v", put)
+	}
+}
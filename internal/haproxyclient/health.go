@@ -0,0 +1,18 @@
+package haproxyclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// ReadyzCheck returns a readiness check that succeeds when the Data Plane API answers with the
+// configuration version, so that the operator is not reported ready while it cannot apply changes.
+// After a failed negotiation, the check fails until it negotiates the version of the Data Plane API.
+func ReadyzCheck(client VersionManager) func(*http.Request) error {
+	return func(req *http.Request) error {
+		if _, err := client.GetConfigVersion(req.Context()); err != nil {
+			return fmt.Errorf("HAProxy Data Plane API is not reachable: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,29 @@
+package haproxyclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyzCheck(t *testing.T) {
+	available := true
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "3")
+	})
+	defer closeFn()
+	check := ReadyzCheck(client)
+
+	if err := check(httptest.NewRequest(http.MethodGet, "/readyz", nil)); err != nil {
+		t.Errorf("expected ready while the Data Plane API answers, got %v", err)
+	}
+	available = false
+	if err := check(httptest.NewRequest(http.MethodGet, "/readyz", nil)); err == nil {
+		t.Error("expected not ready while the Data Plane API is unavailable")
+	}
+}
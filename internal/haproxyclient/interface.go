@@ -1,6 +1,8 @@
 package haproxyclient
 
 import (
+	"context"
+
 	"github.com/haproxytech/client-native/v6/models"
 )
 
@@ -9,55 +11,97 @@ type HAProxyClient interface {
 	BackendManager
 	FrontendManager
 	ServerManager
+	ServerTemplateManager
+	ResolverManager
 	HTTPCheckManager
 	BindManager
 	BackendSwitchingRuleManager
 	VersionManager
-	StartTransaction() (Transaction, error)
-	CommitTransaction(id string, force bool) (Transaction, error)
-	DeleteTransaction(id string) error
+	RuntimeManager
+	ReloadManager
+	ConfigurationManager
+	StartTransaction(ctx context.Context) (Transaction, error)
+	CommitTransaction(ctx context.Context, id string, force bool) (Transaction, error)
+	DeleteTransaction(ctx context.Context, id string) error
+	ListTransactions(ctx context.Context) ([]Transaction, error)
+	RunTransaction(ctx context.Context, apply func(ctx context.Context) error) (Transaction, error)
 }
 
 // VersionManager handles configuration version operations
 type VersionManager interface {
-	GetConfigVersion() (int64, error)
+	GetConfigVersion(ctx context.Context) (int64, error)
+	Negotiate(ctx context.Context) (APIInfo, error)
+}
+
+// RuntimeManager handles runtime state operations
+type RuntimeManager interface {
+	ListRuntimeServers(ctx context.Context, backend string) ([]*models.RuntimeServer, error)
+	ListServerStats(ctx context.Context, backend string) ([]*models.NativeStat, error)
+}
+
+// ReloadManager handles HAProxy reload operations
+type ReloadManager interface {
+	GetReload(ctx context.Context, id string) (*models.Reload, error)
+}
+
+// ConfigurationManager handles raw configuration operations
+type ConfigurationManager interface {
+	GetRawConfiguration(ctx context.Context) (*ConfigSnapshot, error)
+	RestoreBackend(ctx context.Context, snapshot *ConfigSnapshot, name string) (string, error)
+	ReplaceConfiguration(ctx context.Context, raw string) (string, error)
 }
 
 // BackendManager handles backend operations
 type BackendManager interface {
-	GetBackend(name string) (*models.Backend, error)
-	EnsureBackend(backend *models.Backend) error
-	ListBackends() ([]*models.Backend, error)
-	DeleteBackend(name string) error
+	GetBackend(ctx context.Context, name string) (*models.Backend, error)
+	EnsureBackend(ctx context.Context, backend *models.Backend) error
+	ListBackends(ctx context.Context) ([]*models.Backend, error)
+	DeleteBackend(ctx context.Context, name string) error
 }
 
 // FrontendManager handles frontend operations
 type FrontendManager interface {
-	GetFrontend(name string) (*models.Frontend, error)
-	EnsureFrontend(frontend *models.Frontend) error
+	GetFrontend(ctx context.Context, name string) (*models.Frontend, error)
+	EnsureFrontend(ctx context.Context, frontend *models.Frontend) error
 }
 
 // ServerManager handles server operations
 type ServerManager interface {
-	GetServer(backend, name string) (*models.Server, error)
-	EnsureServer(backend string, server *models.Server) error
-	ListServers(backendName string) ([]*models.Server, error)
-	DeleteServer(backendName, serverName string) error
+	GetServer(ctx context.Context, backend, name string) (*models.Server, error)
+	EnsureServer(ctx context.Context, backend string, server *models.Server) error
+	UpdateServerWeights(ctx context.Context, backend *models.Backend) ([]string, error)
+	ListServers(ctx context.Context, backendName string) ([]*models.Server, error)
+	DeleteServer(ctx context.Context, backendName, serverName string) error
+}
+
+// ServerTemplateManager handles server template operations
+type ServerTemplateManager interface {
+	GetServerTemplate(ctx context.Context, backend, prefix string) (*models.ServerTemplate, error)
+	EnsureServerTemplate(ctx context.Context, backend string, template *models.ServerTemplate) error
+	ListServerTemplates(ctx context.Context, backend string) ([]*models.ServerTemplate, error)
+	DeleteServerTemplate(ctx context.Context, backend, prefix string) error
+}
+
+// ResolverManager handles resolvers section operations
+type ResolverManager interface {
+	GetResolver(ctx context.Context, name string) (*models.Resolver, error)
+	EnsureResolver(ctx context.Context, resolver *models.Resolver) error
+	DeleteResolver(ctx context.Context, name string) error
 }
 
 // HTTPCheckManager handles HTTP check operations
 type HTTPCheckManager interface {
-	EnsureBackendHTTPCheck(backend string, httpChecks []*models.HTTPCheck) error
+	EnsureBackendHTTPCheck(ctx context.Context, backend string, httpChecks []*models.HTTPCheck) error
 }
 
 // BindManager handles bind operations
 type BindManager interface {
-	EnsureBind(frontend string, bind *models.Bind) error
+	EnsureBind(ctx context.Context, frontend string, bind *models.Bind) error
 }
 
 // BackendSwitchingRuleManager handles backend switching rule operations
 type BackendSwitchingRuleManager interface {
-	ListBackendSwitchingRules(frontend string) ([]*models.BackendSwitchingRule, error)
-	EnsureBackendSwitchingRule(frontend string, rule *models.BackendSwitchingRule) error
-	DeleteBackendSwitchingRuleByIndex(frontend string, index int64) error
+	ListBackendSwitchingRules(ctx context.Context, frontend string) ([]*models.BackendSwitchingRule, error)
+	EnsureBackendSwitchingRule(ctx context.Context, frontend string, rule *models.BackendSwitchingRule) error
+	DeleteBackendSwitchingRuleByIndex(ctx context.Context, frontend string, index int64) error
 }
@@ -0,0 +1,88 @@
+package haproxyclient
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+// apiPathPrefix matches the prefix of the Data Plane API paths of any version, left out of
+// operation names.
+var apiPathPrefix = regexp.MustCompile(`^(.*?/)?v[0-9]+/(services/haproxy/)?`)
+
+// apiPathSegments are the fixed segments of Data Plane API paths. Any other segment is the name of
+// a resource and is replaced in operation names, to keep the number of label values bounded.
+var apiPathSegments = map[string]bool{
+	"info":                    true,
+	"configuration":           true,
+	"runtime":                 true,
+	"stats":                   true,
+	"native":                  true,
+	"transactions":            true,
+	"reloads":                 true,
+	"version":                 true,
+	"raw":                     true,
+	"backends":                true,
+	"frontends":               true,
+	"servers":                 true,
+	"server_templates":        true,
+	"resolvers":               true,
+	"nameservers":             true,
+	"binds":                   true,
+	"backend_switching_rules": true,
+	"http_checks":             true,
+}
+
+// instrument records the latency and errors of every Data Plane API request made by the client.
+func instrument(client *resty.Client) {
+	client.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		observeRequest(resp.Request, strconv.Itoa(resp.StatusCode()), resp.Time())
+		// Not found responses are expected when checking whether resources exist
+		if resp.IsError() && resp.StatusCode() != http.StatusNotFound {
+			monitoring.HAProxyClientErrorCountTotal.Inc()
+		}
+		return nil
+	})
+	client.OnError(func(req *resty.Request, err error) {
+		var respErr *resty.ResponseError
+		if errors.As(err, &respErr) {
+			// The response was already recorded
+			return
+		}
+		observeRequest(req, "error", time.Since(req.Time))
+		monitoring.HAProxyClientErrorCountTotal.Inc()
+	})
+}
+
+// observeRequest records the latency of a request by operation and status code.
+func observeRequest(req *resty.Request, code string, duration time.Duration) {
+	monitoring.HAProxyClientRequestDuration.
+		WithLabelValues(operationName(req.Method, req.URL), code).
+		Observe(duration.Seconds())
+}
+
+// operationName returns the method and path of a request with resource names replaced, such as
+// "GET configuration/backends/{name}/servers".
+func operationName(method, rawURL string) string {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path = u.Path
+	}
+	path = apiPathPrefix.ReplaceAllString(path, "")
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		if !apiPathSegments[segment] {
+			segments[i] = "{name}"
+		}
+	}
+	return method + " " + strings.Join(segments, "/")
+}
@@ -0,0 +1,91 @@
+package haproxyclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+func TestOperationName(t *testing.T) {
+	tests := map[string]string{
+		"http://haproxy:5555/v3/services/haproxy/configuration/backends/web/servers/web-0": "GET configuration/backends/{name}/servers/{name}",
+		"http://haproxy:5555/v3/services/haproxy/configuration/version":                    "GET configuration/version",
+		"http://haproxy:5555/v3/services/haproxy/transactions/4a5b?force_reload=false":     "GET transactions/{name}",
+		"http://haproxy:5555/v3/services/haproxy/stats/native":                             "GET stats/native",
+		"http://haproxy:5555/v2/services/haproxy/configuration/servers/web-0?backend=web":  "GET configuration/servers/{name}",
+		"http://haproxy:5555/v3/info":                                                      "GET info",
+	}
+	for rawURL, want := range tests {
+		if got := operationName(http.MethodGet, rawURL); got != want {
+			t.Errorf("operationName(%q) = %q, expected %q", rawURL, got, want)
+		}
+	}
+}
+
+func TestTransactionMetrics(t *testing.T) {
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":"tx1"}`)
+		case r.Method == http.MethodPut:
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Reload-ID", "1-2")
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"id":"tx1"}`)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			fmt.Fprint(w, "1")
+		}
+	})
+	defer closeFn()
+
+	counter := func(result string) float64 {
+		return testutil.ToFloat64(monitoring.HAProxyClientTransactionsTotal.WithLabelValues(result))
+	}
+	started, committed, noop := counter(monitoring.TransactionStarted), counter(monitoring.TransactionCommitted), counter(monitoring.TransactionNoop)
+	reloads := testutil.ToFloat64(monitoring.HAProxyClientReloadCommitsTotal)
+
+	// A transaction without changes is deleted instead of committed
+	if _, err := client.StartTransaction(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CommitTransaction(context.Background(), "tx1", false); err != nil {
+		t.Fatal(err)
+	}
+	// A transaction with changes is committed, and the 202 response means HAProxy reloads
+	if _, err := client.StartTransaction(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	client.transactionDirty = true
+	tr, err := client.CommitTransaction(context.Background(), "tx1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.ReloadID != "1-2" {
+		t.Errorf("expected the ID of the pending reload, got %q", tr.ReloadID)
+	}
+
+	if got := counter(monitoring.TransactionStarted) - started; got != 2 {
+		t.Errorf("expected 2 started transactions, got %v", got)
+	}
+	if got := counter(monitoring.TransactionNoop) - noop; got != 1 {
+		t.Errorf("expected 1 noop transaction, got %v", got)
+	}
+	if got := counter(monitoring.TransactionCommitted) - committed; got != 1 {
+		t.Errorf("expected 1 committed transaction, got %v", got)
+	}
+	if got := testutil.ToFloat64(monitoring.HAProxyClientReloadCommitsTotal) - reloads; got != 1 {
+		t.Errorf("expected 1 reload commit, got %v", got)
+	}
+	if got := testutil.CollectAndCount(monitoring.HAProxyClientRequestDuration, "haproxy_client_request_duration_seconds"); got == 0 {
+		t.Error("expected request latencies to be recorded")
+	}
+}
@@ -0,0 +1,41 @@
+package haproxyclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// Statuses of HAProxy reloads.
+const (
+	ReloadInProgress = "in_progress"
+	ReloadSucceeded  = "succeeded"
+	ReloadFailed     = "failed"
+)
+
+// GetReload returns the HAProxy reload with the given ID, as returned by commits accepted with a
+// reload pending. When the reload failed, its Response holds the error reported by HAProxy. It
+// returns nil if the Data Plane API does not know the reload, such as after it restarted.
+func (c *Client) GetReload(ctx context.Context, id string) (*models.Reload, error) {
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		Get(c.apiURL("reloads/" + url.PathEscape(id)))
+	if err != nil {
+		return nil, fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.StatusCode() == 404 {
+		return nil, nil
+	}
+	if resp.IsError() {
+		return nil, newAPIError(resp, "get reload")
+	}
+
+	reload := &models.Reload{}
+	if err := json.Unmarshal(resp.Body(), reload); err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	}
+	return reload, nil
+}
@@ -0,0 +1,34 @@
+package haproxyclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetReload(t *testing.T) {
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/services/haproxy/reloads/1-2":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"id":"1-2","status":"failed","response":"[ALERT] config: parsing error"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer closeFn()
+
+	reload, err := client.GetReload(context.Background(), "1-2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reload.Status != ReloadFailed || reload.Response != "[ALERT] config: parsing error" {
+		t.Errorf("unexpected reload %+v", reload)
+	}
+
+	reload, err = client.GetReload(context.Background(), "1-3")
+	if err != nil || reload != nil {
+		t.Errorf("expected no reload, got %+v, %v", reload, err)
+	}
+}
@@ -0,0 +1,265 @@
+package haproxyclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/haproxytech/client-native/v6/models"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Resolvers sections have no description to mark them as managed, so the controller owns any
+// section it is asked to ensure. Section names must therefore not be shared with hand-written
+// configuration.
+
+// GetResolver retrieves a resolvers section by name
+func (c *Client) GetResolver(ctx context.Context, name string) (*models.Resolver, error) {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("api request failed: %w", err)
+	}
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetQueryParam(queryKey, queryVal).
+		Get(c.configurationURL(section{}, "resolvers", name))
+	if err != nil {
+		return nil, fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.StatusCode() == 404 {
+		return nil, nil
+	}
+	if resp.IsError() {
+		return nil, newAPIError(resp, "get resolver")
+	}
+	resolver := &models.Resolver{}
+	if err := c.decode(resp, resolver); err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	}
+	return resolver, nil
+}
+
+// EnsureResolver creates or updates a resolvers section and its nameservers
+func (c *Client) EnsureResolver(ctx context.Context, resolver *models.Resolver) error {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing, err := c.GetResolver(ctx, resolver.Name)
+	if err != nil {
+		return fmt.Errorf("getting resolver: %w", err)
+	}
+
+	// The section and its nameservers are sent separately
+	base := models.Resolver{ResolverBase: resolver.ResolverBase}
+
+	var resp *resty.Response
+	if existing == nil {
+		logf.Log.V(1).Info("Creating new resolver", "name", resolver.Name, "object", resolver)
+		resp, err = c.client.R().SetContext(ctx).
+			SetHeader("Content-Type", "application/json").
+			SetBody(base).
+			SetQueryParam(queryKey, queryVal).
+			Post(c.configurationURL(section{}, "resolvers", ""))
+		c.transactionDirty = true
+	} else if !c.resolversEqual(existing, resolver) {
+		logf.Log.V(1).Info("Updating existing resolver", "name", resolver.Name, "object", resolver)
+		resp, err = c.client.R().SetContext(ctx).
+			SetHeader("Content-Type", "application/json").
+			SetBody(base).
+			SetQueryParam(queryKey, queryVal).
+			Put(c.configurationURL(section{}, "resolvers", resolver.Name))
+		c.transactionDirty = true
+	} else {
+		logf.Log.V(2).Info("Resolver is already in desired state", "name", resolver.Name)
+	}
+
+	if err != nil {
+		return fmt.Errorf("api request: %w", err)
+	}
+	if resp != nil && resp.IsError() {
+		return newAPIError(resp, "update resolver")
+	}
+
+	// Ensure nameservers are set
+	nameservers, err := c.ListNameservers(ctx, resolver.Name)
+	if err != nil {
+		return fmt.Errorf("listing nameservers: %w", err)
+	}
+	for _, nameserver := range resolver.Nameservers {
+		if err := c.EnsureNameserver(ctx, resolver.Name, &nameserver); err != nil {
+			return fmt.Errorf("ensuring nameserver %s: %w", nameserver.Name, err)
+		}
+	}
+	// Ensure all nameservers are deleted that are not in the resolver spec
+	for _, existingNameserver := range nameservers {
+		if _, found := resolver.Nameservers[existingNameserver.Name]; !found {
+			if err := c.DeleteNameserver(ctx, resolver.Name, existingNameserver.Name); err != nil {
+				return fmt.Errorf("deleting nameserver %s: %w", existingNameserver.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteResolver deletes a resolvers section
+func (c *Client) DeleteResolver(ctx context.Context, name string) error {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
+	if err != nil {
+		return fmt.Errorf("getting config version: %w", err)
+	}
+	logf.Log.V(1).Info("Deleting resolver", "name", name)
+
+	resp, err := c.client.R().SetContext(ctx).
+		SetQueryParam(queryKey, queryVal).
+		Delete(c.configurationURL(section{}, "resolvers", name))
+	if err != nil {
+		return fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.IsError() && resp.StatusCode() != 404 {
+		return newAPIError(resp, "delete resolver")
+	}
+	if resp.StatusCode() == 200 || resp.StatusCode() == 204 || resp.StatusCode() == 202 {
+		c.transactionDirty = true
+	}
+	return nil
+}
+
+// resolversEqual compares the settings of two resolvers sections, ignoring their nameservers
+func (c *Client) resolversEqual(a, b *models.Resolver) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Name != b.Name ||
+		a.AcceptedPayloadSize != b.AcceptedPayloadSize ||
+		a.ParseResolvConf != b.ParseResolvConf ||
+		a.ResolveRetries != b.ResolveRetries ||
+		a.TimeoutResolve != b.TimeoutResolve ||
+		a.TimeoutRetry != b.TimeoutRetry {
+		return false
+	}
+	return int64PtrEqual(a.HoldNx, b.HoldNx) &&
+		int64PtrEqual(a.HoldObsolete, b.HoldObsolete) &&
+		int64PtrEqual(a.HoldOther, b.HoldOther) &&
+		int64PtrEqual(a.HoldRefused, b.HoldRefused) &&
+		int64PtrEqual(a.HoldTimeout, b.HoldTimeout) &&
+		int64PtrEqual(a.HoldValid, b.HoldValid)
+}
+
+// Nameserver
+
+// ListNameservers lists all nameservers of a resolvers section
+func (c *Client) ListNameservers(ctx context.Context, resolver string) ([]*models.Nameserver, error) {
+	queryKey, queryVal, _ := c.getVersionOrTransactionParam(ctx)
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetQueryParam(queryKey, queryVal).
+		Get(c.configurationURL(resolverSection(resolver), "nameservers", ""))
+	if err != nil {
+		return nil, fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.IsError() {
+		return nil, newAPIError(resp, "list nameservers")
+	}
+
+	var nameservers []*models.Nameserver
+	if err := c.decode(resp, &nameservers); err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	}
+	return nameservers, nil
+}
+
+// EnsureNameserver creates or updates a nameserver of a resolvers section
+func (c *Client) EnsureNameserver(ctx context.Context, resolver string, nameserver *models.Nameserver) error {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetQueryParam(queryKey, queryVal).
+		Get(c.configurationURL(resolverSection(resolver), "nameservers", nameserver.Name))
+	if err != nil {
+		return fmt.Errorf("api request failed: %w", err)
+	}
+
+	existing := &models.Nameserver{}
+	if resp.StatusCode() == 404 {
+		logf.Log.V(1).Info("Creating new nameserver", "resolver", resolver, "name", nameserver.Name, "object", nameserver)
+		resp, err = c.client.R().SetContext(ctx).
+			SetHeader("Content-Type", "application/json").
+			SetBody(nameserver).
+			SetQueryParam(queryKey, queryVal).
+			Post(c.configurationURL(resolverSection(resolver), "nameservers", ""))
+		c.transactionDirty = true
+	} else if resp.IsError() {
+		return newAPIError(resp, "get nameserver")
+	} else if err := c.decode(resp, existing); err != nil {
+		return fmt.Errorf("unmarshaling response: %w", err)
+	} else if !nameserversEqual(existing, nameserver) {
+		logf.Log.V(1).Info("Updating existing nameserver", "resolver", resolver, "name", nameserver.Name, "object", nameserver)
+		resp, err = c.client.R().SetContext(ctx).
+			SetHeader("Content-Type", "application/json").
+			SetBody(nameserver).
+			SetQueryParam(queryKey, queryVal).
+			Put(c.configurationURL(resolverSection(resolver), "nameservers", nameserver.Name))
+		c.transactionDirty = true
+	} else {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.IsError() {
+		return newAPIError(resp, "update nameserver")
+	}
+	return nil
+}
+
+// DeleteNameserver deletes a nameserver from a resolvers section
+func (c *Client) DeleteNameserver(ctx context.Context, resolver, name string) error {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
+	if err != nil {
+		return fmt.Errorf("getting config version: %w", err)
+	}
+	logf.Log.V(1).Info("Deleting nameserver", "resolver", resolver, "name", name)
+
+	resp, err := c.client.R().SetContext(ctx).
+		SetQueryParam(queryKey, queryVal).
+		Delete(c.configurationURL(resolverSection(resolver), "nameservers", name))
+	if err != nil {
+		return fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.IsError() && resp.StatusCode() != 404 {
+		return newAPIError(resp, "delete nameserver")
+	}
+	if resp.StatusCode() == 200 || resp.StatusCode() == 204 || resp.StatusCode() == 202 {
+		c.transactionDirty = true
+	}
+	return nil
+}
+
+// nameserversEqual compares two nameservers for equality
+func nameserversEqual(a, b *models.Nameserver) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Name != b.Name {
+		return false
+	}
+	if (a.Address == nil) != (b.Address == nil) || (a.Address != nil && *a.Address != *b.Address) {
+		return false
+	}
+	return int64PtrEqual(a.Port, b.Port)
+}
+
+// int64PtrEqual compares two optional integers for equality
+func int64PtrEqual(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,81 @@
+package haproxyclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+func TestEnsureResolver_SyncsNameservers(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+
+		path := strings.TrimPrefix(r.URL.Path, "/v3/services/haproxy/configuration")
+		switch {
+		case r.Method == http.MethodGet && path == "/resolvers/corp-dns":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"name":"corp-dns","accepted_payload_size":512}`)
+		case r.Method == http.MethodGet && path == "/resolvers/corp-dns/nameservers":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[{"name":"dns1","address":"10.0.0.53","port":53},{"name":"old","address":"10.9.9.9","port":53}]`)
+		case r.Method == http.MethodGet && path == "/resolvers/corp-dns/nameservers/dns1":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"name":"dns1","address":"10.0.0.53","port":53}`)
+		case r.Method == http.MethodGet && strings.HasPrefix(path, "/resolvers/corp-dns/nameservers/"):
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet:
+			fmt.Fprint(w, "1")
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	defer closeFn()
+
+	dns1, dns2 := "10.0.0.53", "10.0.1.53"
+	port := int64(53)
+	err := client.EnsureResolver(context.Background(), &models.Resolver{
+		ResolverBase: models.ResolverBase{Name: "corp-dns", AcceptedPayloadSize: 8192},
+		Nameservers: map[string]models.Nameserver{
+			"dns1": {Name: "dns1", Address: &dns1, Port: &port},
+			"dns2": {Name: "dns2", Address: &dns2, Port: &port},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var changes []string
+	for _, req := range requests {
+		if !strings.HasPrefix(req, http.MethodGet) {
+			changes = append(changes, req)
+		}
+	}
+	sort.Strings(changes)
+	expected := []string{
+		"DELETE /v3/services/haproxy/configuration/resolvers/corp-dns/nameservers/old",
+		"POST /v3/services/haproxy/configuration/resolvers/corp-dns/nameservers",
+		"PUT /v3/services/haproxy/configuration/resolvers/corp-dns",
+	}
+	if strings.Join(changes, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected changes:\n%s\nexpected:\n%s", strings.Join(changes, "\n"), strings.Join(expected, "\n"))
+	}
+	if !client.transactionDirty {
+		t.Error("expected the transaction to be marked dirty")
+	}
+}
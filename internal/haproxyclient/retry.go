@@ -0,0 +1,128 @@
+package haproxyclient
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+// Defaults of the retries of the HAProxy client.
+const (
+	defaultMaxRetries       = 3
+	defaultRetryWaitTime    = 200 * time.Millisecond
+	defaultRetryMaxWaitTime = 2 * time.Second
+)
+
+// retryPolicy bounds the retries of requests and the restarts of transactions.
+type retryPolicy struct {
+	maxRetries  int
+	waitTime    time.Duration
+	maxWaitTime time.Duration
+}
+
+// newRetryPolicy returns the retry policy of the configuration, with defaults for unset values.
+// A negative MaxRetries disables retries.
+func newRetryPolicy(config HAProxyConfig) retryPolicy {
+	policy := retryPolicy{
+		maxRetries:  config.MaxRetries,
+		waitTime:    config.RetryWaitTime,
+		maxWaitTime: config.RetryMaxWaitTime,
+	}
+	if policy.maxRetries == 0 {
+		policy.maxRetries = defaultMaxRetries
+	}
+	if policy.maxRetries < 0 {
+		policy.maxRetries = 0
+	}
+	if policy.waitTime == 0 {
+		policy.waitTime = defaultRetryWaitTime
+	}
+	if policy.maxWaitTime == 0 {
+		policy.maxWaitTime = defaultRetryMaxWaitTime
+	}
+	if policy.maxWaitTime < policy.waitTime {
+		policy.maxWaitTime = policy.waitTime
+	}
+	return policy
+}
+
+// retryRequests makes the client retry requests that fail with a retryable error, waiting a
+// jittered exponential backoff between attempts. Only idempotent requests are retried, as a request
+// that changes the configuration may have been applied without its response; CommitTransaction
+// checks the transaction before committing it again. Starting a transaction is also retried when
+// there are too many open transactions, which starts none. Conflicts are not retried here, as the
+// request would be made again with the same outdated version.
+func retryRequests(client *resty.Client, policy retryPolicy) {
+	client.
+		SetRetryCount(policy.maxRetries).
+		SetRetryWaitTime(policy.waitTime).
+		SetRetryMaxWaitTime(policy.maxWaitTime).
+		AddRetryCondition(func(resp *resty.Response, err error) bool {
+			if resp == nil || resp.Request == nil {
+				return false
+			}
+			if isTransactionStart(resp.Request) {
+				return err == nil && IsRetryable(newAPIError(resp, opStartTransaction)) && resp.StatusCode() == http.StatusConflict
+			}
+			if !isIdempotent(resp.Request.Method) {
+				return false
+			}
+			if err != nil {
+				return IsRetryable(err)
+			}
+			return resp.IsError() && IsRetryable(newAPIError(resp, ""))
+		}).
+		AddRetryHook(func(resp *resty.Response, _ error) {
+			if resp == nil || resp.Request == nil {
+				return
+			}
+			monitoring.HAProxyClientRetriesTotal.WithLabelValues(operationName(resp.Request.Method, resp.Request.URL)).Inc()
+		})
+}
+
+// isIdempotent returns whether making a request of the method again has no other effect than making
+// it once.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isTransactionStart returns whether the request starts a transaction, whose conflicts mean that
+// there are too many open transactions and are retryable.
+func isTransactionStart(req *resty.Request) bool {
+	if req.Method != http.MethodPost {
+		return false
+	}
+	u, err := url.Parse(req.URL)
+	return err == nil && strings.HasSuffix(strings.TrimRight(u.Path, "/"), "/transactions")
+}
+
+// backoff waits a jittered exponential backoff before the next attempt, and returns early with
+// the error of the context when it is done.
+func (p retryPolicy) backoff(ctx context.Context, attempt int) error {
+	wait := p.waitTime << attempt
+	if wait <= 0 || wait > p.maxWaitTime {
+		wait = p.maxWaitTime
+	}
+	// Full jitter spreads the attempts of clients that failed at the same time
+	wait = p.waitTime + rand.N(wait-p.waitTime+1)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
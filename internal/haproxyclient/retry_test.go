@@ -0,0 +1,269 @@
+package haproxyclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+func TestRetryRequests(t *testing.T) {
+	attempts := 0
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "7")
+	})
+	defer closeFn()
+	retries := testutil.ToFloat64(monitoring.HAProxyClientRetriesTotal.WithLabelValues("GET configuration/version"))
+
+	version, err := client.GetConfigVersion(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 7 || attempts != 3 {
+		t.Errorf("expected version 7 after 3 attempts, got %d after %d", version, attempts)
+	}
+	if got := testutil.ToFloat64(monitoring.HAProxyClientRetriesTotal.WithLabelValues("GET configuration/version")) - retries; got != 2 {
+		t.Errorf("expected 2 retries to be counted, got %v", got)
+	}
+}
+
+func TestRetryRequests_Bounded(t *testing.T) {
+	attempts := 0
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	defer closeFn()
+
+	_, err := client.GetConfigVersion(context.Background())
+	if !IsRetryable(err) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+	if attempts != defaultMaxRetries+1 {
+		t.Errorf("expected %d attempts, got %d", defaultMaxRetries+1, attempts)
+	}
+}
+
+func TestRetryRequests_NotRetried(t *testing.T) {
+	attempts := 0
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	defer closeFn()
+
+	_, err := client.GetConfigVersion(context.Background())
+	if !IsInvalid(err) {
+		t.Errorf("expected an invalid request error, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected invalid requests not to be retried, got %d attempts", attempts)
+	}
+}
+
+func TestStartTransaction_TooManyTransactions(t *testing.T) {
+	starts := 0
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			fmt.Fprint(w, "1")
+			return
+		}
+		starts++
+		if starts == 1 {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"tx1"}`)
+	})
+	defer closeFn()
+
+	transaction, err := client.StartTransaction(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.ID != "tx1" || starts != 2 {
+		t.Errorf("expected transaction tx1 after 2 attempts, got %q after %d", transaction.ID, starts)
+	}
+}
+
+func TestRunTransaction_RestartsOnConflict(t *testing.T) {
+	var versions, deleted []string
+	commits := 0
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, len(versions)+1)
+		case http.MethodPost:
+			versions = append(versions, r.URL.Query().Get("version"))
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"id":"tx%d"}`, len(versions))
+		case http.MethodPut:
+			commits++
+			if commits == 1 {
+				// The configuration changed since the transaction started
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, `{"code":409,"message":"version mismatch"}`)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"id":"tx2","status":"success"}`)
+		case http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	defer closeFn()
+	restarts := testutil.ToFloat64(monitoring.HAProxyClientTransactionsTotal.WithLabelValues(monitoring.TransactionRestarted))
+
+	applied := 0
+	committed, err := client.RunTransaction(context.Background(), func(ctx context.Context) error {
+		applied++
+		client.transactionDirty = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if committed.ID != "tx2" || applied != 2 {
+		t.Errorf("expected tx2 to be committed after applying twice, got %q after %d", committed.ID, applied)
+	}
+	if len(versions) != 2 || versions[0] == versions[1] {
+		t.Errorf("expected the restarted transaction to start from a fresh version, got %v", versions)
+	}
+	if len(deleted) != 1 || deleted[0] != "/v3/services/haproxy/transactions/tx1" {
+		t.Errorf("expected the conflicting transaction to be deleted, got %v", deleted)
+	}
+	if client.currentTransactionID != "" {
+		t.Errorf("expected no current transaction, got %q", client.currentTransactionID)
+	}
+	if got := testutil.ToFloat64(monitoring.HAProxyClientTransactionsTotal.WithLabelValues(monitoring.TransactionRestarted)) - restarts; got != 1 {
+		t.Errorf("expected 1 restarted transaction, got %v", got)
+	}
+}
+
+func TestRunTransaction_AbortsOnError(t *testing.T) {
+	var deleted []string
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, "1")
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":"tx1"}`)
+		case http.MethodDelete:
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	defer closeFn()
+
+	applied := 0
+	_, err := client.RunTransaction(context.Background(), func(ctx context.Context) error {
+		applied++
+		return &APIError{StatusCode: http.StatusBadRequest, Operation: "update backend"}
+	})
+	if !IsInvalid(err) {
+		t.Errorf("expected the error of apply, got %v", err)
+	}
+	if applied != 1 {
+		t.Errorf("expected invalid changes not to be applied again, got %d attempts", applied)
+	}
+	if len(deleted) != 1 || client.currentTransactionID != "" {
+		t.Errorf("expected the transaction to be deleted, got %v and current transaction %q", deleted, client.currentTransactionID)
+	}
+}
+
+func TestRetryRequests_NotIdempotent(t *testing.T) {
+	attempts := 0
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			fmt.Fprint(w, "1")
+			return
+		}
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	defer closeFn()
+
+	if _, err := client.StartTransaction(context.Background()); !IsRetryable(err) {
+		t.Errorf("expected a retryable error, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected the request changing the configuration not to be retried, got %d attempts", attempts)
+	}
+}
+
+func TestCommitTransaction_RetriedInProgress(t *testing.T) {
+	commits := 0
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Path == "/v3/services/haproxy/transactions/tx1" {
+				fmt.Fprint(w, `{"id":"tx1","status":"in_progress"}`)
+				return
+			}
+			fmt.Fprint(w, "1")
+		case http.MethodPut:
+			commits++
+			if commits == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Reload-ID", "1-2")
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"id":"tx1","status":"success"}`)
+		}
+	})
+	defer closeFn()
+	client.transactionDirty = true
+
+	committed, err := client.CommitTransaction(context.Background(), "tx1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commits != 2 || committed.ReloadID != "1-2" {
+		t.Errorf("expected the commit to be made again, got %d commits and %+v", commits, committed)
+	}
+}
+
+func TestCommitTransaction_CommittedWithoutResponse(t *testing.T) {
+	commits := 0
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Path == "/v3/services/haproxy/transactions/tx1" {
+				// The first commit was applied
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, "1")
+		case http.MethodPut:
+			commits++
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	})
+	defer closeFn()
+	client.transactionDirty = true
+
+	committed, err := client.CommitTransaction(context.Background(), "tx1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commits != 1 || committed.ID != "tx1" {
+		t.Errorf("expected the commit not to be made again, got %d commits and %+v", commits, committed)
+	}
+}
@@ -0,0 +1,39 @@
+package haproxyclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// ListRuntimeServers returns the runtime state of the servers in a backend of the running HAProxy
+// process. It returns no servers if the running process does not have the backend yet.
+func (c *Client) ListRuntimeServers(ctx context.Context, backend string) ([]*models.RuntimeServer, error) {
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		Get(c.config.BaseURL + c.dialect().runtimeServers(backend))
+	if err != nil {
+		return nil, fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.StatusCode() == 404 {
+		return nil, nil
+	}
+	if resp.IsError() {
+		return nil, newAPIError(resp, "list runtime servers")
+	}
+
+	var servers []*models.RuntimeServer
+	if err := json.Unmarshal(resp.Body(), &servers); err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	}
+	return servers, nil
+}
+
+// RuntimeServerUp reports whether HAProxy considers the server UP and it is not in maintenance
+// or draining, so it takes new traffic.
+func RuntimeServerUp(server *models.RuntimeServer) bool {
+	return strings.EqualFold(server.OperationalState, "up") && strings.EqualFold(server.AdminState, "ready")
+}
@@ -0,0 +1,131 @@
+package haproxyclient
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/haproxytech/client-native/v6/models"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// GetServerTemplate retrieves a server template from a backend
+func (c *Client) GetServerTemplate(ctx context.Context, backend, prefix string) (*models.ServerTemplate, error) {
+	queryKey, queryVal, _ := c.getVersionOrTransactionParam(ctx)
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetQueryParam(queryKey, queryVal).
+		Get(c.configurationURL(backendSection(backend), "server_templates", prefix))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode() == 404 {
+		return nil, nil
+	}
+	if resp.IsError() {
+		return nil, newAPIError(resp, "get server template")
+	}
+	template := &models.ServerTemplate{}
+	if err := c.decode(resp, template); err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	}
+	return template, nil
+}
+
+// EnsureServerTemplate creates or updates a server template in a backend
+func (c *Client) EnsureServerTemplate(ctx context.Context, backend string, template *models.ServerTemplate) error {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
+	if err != nil {
+		return err
+	}
+
+	existing, err := c.GetServerTemplate(ctx, backend, template.Prefix)
+	if err != nil {
+		return err
+	}
+
+	var resp *resty.Response
+	if existing == nil {
+		logf.Log.V(1).Info("Creating new server template", "backend", backend, "prefix", template.Prefix, "object", template)
+		resp, err = c.client.R().SetContext(ctx).
+			SetHeader("Content-Type", "application/json").
+			SetBody(template).
+			SetQueryParam(queryKey, queryVal).
+			Post(c.configurationURL(backendSection(backend), "server_templates", ""))
+		c.transactionDirty = true
+	} else if !serverTemplatesEqual(existing, template) {
+		logf.Log.V(1).Info("Updating existing server template", "backend", backend, "prefix", template.Prefix, "object", template)
+		resp, err = c.client.R().SetContext(ctx).
+			SetHeader("Content-Type", "application/json").
+			SetBody(template).
+			SetQueryParam(queryKey, queryVal).
+			Put(c.configurationURL(backendSection(backend), "server_templates", template.Prefix))
+		c.transactionDirty = true
+	}
+
+	if err != nil {
+		return err
+	}
+	if resp != nil && resp.IsError() {
+		return newAPIError(resp, "update server template")
+	}
+	return nil
+}
+
+// ListServerTemplates lists all server templates in a backend
+func (c *Client) ListServerTemplates(ctx context.Context, backend string) ([]*models.ServerTemplate, error) {
+	queryKey, queryVal, _ := c.getVersionOrTransactionParam(ctx)
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetQueryParam(queryKey, queryVal).
+		Get(c.configurationURL(backendSection(backend), "server_templates", ""))
+	if err != nil {
+		return nil, fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.IsError() {
+		return nil, newAPIError(resp, "list server templates")
+	}
+
+	var templates []*models.ServerTemplate
+	if err := c.decode(resp, &templates); err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	}
+	return templates, nil
+}
+
+// DeleteServerTemplate deletes a server template from a backend
+func (c *Client) DeleteServerTemplate(ctx context.Context, backend, prefix string) error {
+	queryKey, queryVal, err := c.getVersionOrTransactionParam(ctx)
+	if err != nil {
+		return fmt.Errorf("getting config version: %w", err)
+	}
+	logf.Log.V(1).Info("Deleting server template", "backend", backend, "prefix", prefix)
+
+	resp, err := c.client.R().SetContext(ctx).
+		SetQueryParam(queryKey, queryVal).
+		Delete(c.configurationURL(backendSection(backend), "server_templates", prefix))
+	if err != nil {
+		return fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.IsError() && resp.StatusCode() != 404 {
+		return newAPIError(resp, "delete server template")
+	}
+	if resp.StatusCode() == 200 || resp.StatusCode() == 204 || resp.StatusCode() == 202 {
+		c.transactionDirty = true
+	}
+	return nil
+}
+
+// serverTemplatesEqual compares two server templates for equality
+func serverTemplatesEqual(a, b *models.ServerTemplate) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Prefix != b.Prefix || a.NumOrRange != b.NumOrRange || a.Fqdn != b.Fqdn {
+		return false
+	}
+	if !int64PtrEqual(a.Port, b.Port) {
+		return false
+	}
+	return serverParamsEqual(a.ServerParams, b.ServerParams)
+}
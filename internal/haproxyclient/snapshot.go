@@ -0,0 +1,141 @@
+package haproxyclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ConfigSnapshot is the raw HAProxy configuration at a version, taken before committing a
+// transaction so that it can be restored when HAProxy fails to reload with the committed changes.
+type ConfigSnapshot struct {
+	Version int64
+	Raw     string
+}
+
+// GetRawConfiguration returns a snapshot of the current raw HAProxy configuration.
+func (c *Client) GetRawConfiguration(ctx context.Context) (*ConfigSnapshot, error) {
+	return c.rawConfiguration(ctx, "")
+}
+
+// rawConfiguration returns the raw HAProxy configuration, resulting from the transaction with the
+// ID if it is not empty.
+func (c *Client) rawConfiguration(ctx context.Context, transactionID string) (*ConfigSnapshot, error) {
+	req := c.client.R().SetContext(ctx)
+	if transactionID != "" {
+		req.SetQueryParam("transaction_id", transactionID)
+	}
+	resp, err := req.Get(c.configurationURL(section{}, "raw", ""))
+	if err != nil {
+		return nil, fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.IsError() {
+		return nil, newAPIError(resp, "get raw configuration")
+	}
+
+	snapshot := &ConfigSnapshot{Raw: string(resp.Body())}
+	if strings.Contains(resp.Header().Get("Content-Type"), "json") {
+		// The Data Plane API v2 wraps the configuration in an object with its version
+		var raw struct {
+			Version int64  `json:"_version"`
+			Data    string `json:"data"`
+		}
+		if err := json.Unmarshal(resp.Body(), &raw); err != nil {
+			return nil, fmt.Errorf("unmarshaling response: %w", err)
+		}
+		snapshot.Version, snapshot.Raw = raw.Version, raw.Data
+	} else if version, err := strconv.ParseInt(resp.Header().Get("Configuration-Version"), 10, 64); err == nil {
+		snapshot.Version = version
+	}
+	if snapshot.Version == 0 {
+		return nil, errors.New("no configuration version in the raw configuration response")
+	}
+	return snapshot, nil
+}
+
+// RestoreBackend restores the section of a backend in the HAProxy configuration from a snapshot,
+// leaving the rest of the current configuration, with the changes made since the snapshot, as it
+// is. The backend is removed if the snapshot has none. It returns the ID of the reload applying it,
+// if any, and fails with a conflict if the configuration changes while it is restored. It must not
+// be called during a transaction.
+func (c *Client) RestoreBackend(ctx context.Context, snapshot *ConfigSnapshot, name string) (string, error) {
+	if c.currentTransactionID != "" {
+		return "", fmt.Errorf("cannot restore the configuration during transaction %s", c.currentTransactionID)
+	}
+	current, err := c.GetRawConfiguration(ctx)
+	if err != nil {
+		return "", fmt.Errorf("getting raw configuration: %w", err)
+	}
+
+	_, previous, _ := rawBackendSection(snapshot.Raw, name)
+	before, section, after := rawBackendSection(current.Raw, name)
+	if previous == section {
+		return "", fmt.Errorf("backend %s is unchanged since the snapshot", name)
+	}
+	if section == "" && !strings.HasSuffix(before, "\n") {
+		before += "\n"
+	}
+	return c.postRawConfiguration(ctx, before+previous+after, current.Version)
+}
+
+// rawBackendSection splits a raw configuration around the section of a backend, from its header to
+// the next unindented line, which starts the next section or comments it. The section is empty, and
+// the whole configuration before it, if there is none.
+func rawBackendSection(raw, name string) (before, section, after string) {
+	lines := strings.SplitAfter(raw, "\n")
+	start, end := len(lines), len(lines)
+	for i, line := range lines {
+		unindented := strings.TrimSpace(line) != "" && line[0] != ' ' && line[0] != '\t'
+		if start == len(lines) {
+			if fields := strings.Fields(line); unindented && len(fields) >= 2 && fields[0] == "backend" && fields[1] == name {
+				start = i
+			}
+		} else if unindented {
+			end = i
+			break
+		}
+	}
+	return strings.Join(lines[:start], ""), strings.Join(lines[start:end], ""), strings.Join(lines[end:], "")
+}
+
+// ReplaceConfiguration replaces the HAProxy configuration with a raw configuration, whatever changes
+// were made since it was taken, and returns the ID of the reload applying it, if any. It must not be
+// called during a transaction.
+func (c *Client) ReplaceConfiguration(ctx context.Context, raw string) (string, error) {
+	if c.currentTransactionID != "" {
+		return "", fmt.Errorf("cannot replace the configuration during transaction %s", c.currentTransactionID)
+	}
+	version, err := c.GetConfigVersion(ctx)
+	if err != nil {
+		return "", fmt.Errorf("getting config version: %w", err)
+	}
+	return c.postRawConfiguration(ctx, raw, version)
+}
+
+// postRawConfiguration replaces the configuration at version with a raw configuration.
+func (c *Client) postRawConfiguration(ctx context.Context, raw string, version int64) (string, error) {
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Content-Type", "text/plain").
+		SetBody(withoutVersionLine(raw)).
+		SetQueryParam("version", strconv.FormatInt(version, 10)).
+		Post(c.configurationURL(section{}, "raw", ""))
+	if err != nil {
+		return "", fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.IsError() {
+		return "", newAPIError(resp, "replace raw configuration")
+	}
+	return resp.Header().Get("Reload-ID"), nil
+}
+
+// withoutVersionLine returns the raw configuration without the version comment the Data Plane API
+// writes on its first line, which it writes again with the version of the restored configuration.
+func withoutVersionLine(raw string) string {
+	if first, rest, found := strings.Cut(raw, "\n"); found && strings.HasPrefix(first, "# _version") {
+		return rest
+	}
+	return raw
+}
@@ -0,0 +1,105 @@
+package haproxyclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestRestoreBackend(t *testing.T) {
+	var restored string
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v3/services/haproxy/configuration/raw" && r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Configuration-Version", "10")
+			fmt.Fprint(w, "# _version=10\nglobal\n  daemon\n\nbackend web\n  server web-0 10.0.0.2:80\n\n# other\nbackend api\n  balance source\n")
+		case r.URL.Path == "/v3/services/haproxy/configuration/raw" && r.Method == http.MethodPost:
+			if r.URL.Query().Get("version") != "10" {
+				t.Errorf("expected the restore at the current version, got %s", r.URL)
+			}
+			body, _ := io.ReadAll(r.Body)
+			restored = string(body)
+			w.Header().Set("Reload-ID", "1-3")
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer closeFn()
+	snapshot := &ConfigSnapshot{Version: 8, Raw: "# _version=8\nglobal\n  daemon\n\nbackend web\n  server web-0 10.0.0.1:80\n\n"}
+
+	// The other backends changed since the snapshot are kept
+	reloadID, err := client.RestoreBackend(context.Background(), snapshot, "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "global\n  daemon\n\nbackend web\n  server web-0 10.0.0.1:80\n\n# other\nbackend api\n  balance source\n"
+	if reloadID != "1-3" || restored != want {
+		t.Errorf("unexpected restore of %q in reload %q", restored, reloadID)
+	}
+
+	// A backend created by the changes is removed
+	if _, err := client.RestoreBackend(context.Background(), snapshot, "api"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "global\n  daemon\n\nbackend web\n  server web-0 10.0.0.2:80\n\n# other\n"; restored != want {
+		t.Errorf("expected the new backend to be removed, got %q", restored)
+	}
+
+	// A backend unchanged since the snapshot is not restored
+	restored = ""
+	unchanged := &ConfigSnapshot{Version: 8, Raw: "global\nbackend api\n  balance source\n"}
+	if _, err := client.RestoreBackend(context.Background(), unchanged, "api"); err == nil || restored != "" {
+		t.Errorf("expected the unchanged backend not to be restored, got %v", err)
+	}
+}
+
+func TestGetRawConfiguration_V2(t *testing.T) {
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"_version":4,"data":"global\n  daemon\n"}`)
+	})
+	defer closeFn()
+	client.api = dialectV2{}
+
+	snapshot, err := client.GetRawConfiguration(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if snapshot.Version != 4 || snapshot.Raw != "global\n  daemon\n" {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+}
+
+func TestReplaceConfiguration(t *testing.T) {
+	var replaced string
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v3/services/haproxy/configuration/version":
+			fmt.Fprint(w, "12")
+		case r.URL.Path == "/v3/services/haproxy/configuration/raw" && r.Method == http.MethodPost:
+			if r.URL.Query().Get("version") != "12" {
+				t.Errorf("expected the replacement at the current version, got %s", r.URL)
+			}
+			body, _ := io.ReadAll(r.Body)
+			replaced = string(body)
+			w.Header().Set("Reload-ID", "1-7")
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer closeFn()
+
+	// A configuration from any earlier version replaces the current one
+	reloadID, err := client.ReplaceConfiguration(context.Background(), "# _version=4\nglobal\n  daemon\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reloadID != "1-7" || replaced != "global\n  daemon\n" {
+		t.Errorf("unexpected replacement with %q in reload %q", replaced, reloadID)
+	}
+}
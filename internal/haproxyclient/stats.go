@@ -0,0 +1,71 @@
+package haproxyclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/haproxytech/client-native/v6/models"
+)
+
+// ListServerStats returns the native stats of the servers in a backend of the running HAProxy
+// process. It returns no stats if the running process does not have the backend yet.
+func (c *Client) ListServerStats(ctx context.Context, backend string) ([]*models.NativeStat, error) {
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		SetQueryParam("type", "server").
+		SetQueryParam("parent", backend).
+		Get(c.apiURL("stats/native"))
+	if err != nil {
+		return nil, fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.IsError() {
+		return nil, newAPIError(resp, "get server stats")
+	}
+
+	var stats models.NativeStats
+	if body := bytes.TrimSpace(resp.Body()); bytes.HasPrefix(body, []byte("[")) {
+		// The Data Plane API v2 returns the stats of each process without an envelope
+		err = json.Unmarshal(body, &stats.Stats)
+	} else {
+		err = json.Unmarshal(body, &stats)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
+	}
+	if stats.Error != "" {
+		return nil, fmt.Errorf("getting stats: %s", stats.Error)
+	}
+
+	var servers []*models.NativeStat
+	for _, collection := range stats.Stats {
+		if collection == nil {
+			continue
+		}
+		if collection.Error != "" {
+			return nil, fmt.Errorf("getting stats from %s: %s", collection.RuntimeAPI, collection.Error)
+		}
+		for _, stat := range collection.Stats {
+			if stat != nil && stat.Type == "server" && stat.BackendName == backend {
+				servers = append(servers, stat)
+			}
+		}
+	}
+	return servers, nil
+}
+
+// ServerState returns the state of a server from its stats status, such as "UP 1/3" or
+// "MAINT (via web/web-0)", as one of UP, DOWN, MAINT, DRAIN or NOLB. Servers without health checks
+// are reported as UP, since HAProxy sends them traffic.
+func ServerState(status string) string {
+	if strings.EqualFold(status, "no check") {
+		return "UP"
+	}
+	fields := strings.Fields(strings.ToUpper(status))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
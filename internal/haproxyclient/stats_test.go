@@ -0,0 +1,48 @@
+package haproxyclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestServerState(t *testing.T) {
+	tests := map[string]string{
+		"UP":                   "UP",
+		"UP 1/3":               "UP",
+		"DOWN":                 "DOWN",
+		"DOWN 1/2":             "DOWN",
+		"MAINT (via web/web0)": "MAINT",
+		"DRAIN":                "DRAIN",
+		"no check":             "UP",
+		"":                     "",
+	}
+	for status, want := range tests {
+		if got := ServerState(status); got != want {
+			t.Errorf("ServerState(%q) = %q, expected %q", status, got, want)
+		}
+	}
+}
+
+func TestListServerStats_FiltersBackend(t *testing.T) {
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("parent") != "web" || r.URL.Query().Get("type") != "server" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"stats":[{"runtimeAPI":"/var/run/haproxy.sock","stats":[`+
+			`{"type":"server","name":"web-0","backend_name":"web","stats":{"status":"UP","scur":3}},`+
+			`{"type":"backend","name":"web","backend_name":"web","stats":{"status":"UP"}},`+
+			`{"type":"server","name":"api-0","backend_name":"api","stats":{"status":"DOWN"}}]}]}`)
+	})
+	defer closeFn()
+
+	stats, err := client.ListServerStats(context.Background(), "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Name != "web-0" || stats[0].Stats == nil || *stats[0].Stats.Scur != 3 {
+		t.Errorf("expected only the stats of server web-0, got %+v", stats)
+	}
+}
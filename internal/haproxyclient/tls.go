@@ -0,0 +1,191 @@
+package haproxyclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// TLSConfig configures the TLS connection to a Data Plane API served over HTTPS. The files are
+// typically mounted from Secrets, and are loaded again when they change, so that rotated
+// certificates are used without restarting the operator.
+type TLSConfig struct {
+	// CAFile is a PEM bundle of the certificate authorities that issue the Data Plane API
+	// certificate. The system roots are used when empty.
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and key presented to Data Plane APIs
+	// that require mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ServerName is the name the Data Plane API certificate is verified against, when it differs
+	// from the host of the URL.
+	ServerName string
+	// InsecureSkipVerify disables the verification of the Data Plane API certificate.
+	InsecureSkipVerify bool
+}
+
+// Validate returns an error if the configuration is incomplete.
+func (c TLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return errors.New("a client certificate and its key must be set together")
+	}
+	return nil
+}
+
+// tlsFiles holds the certificates loaded from the files of a TLSConfig, and loads them again when
+// the files change.
+type tlsFiles struct {
+	config TLSConfig
+	// serverName is the name the Data Plane API certificate is verified against.
+	serverName string
+
+	mu     sync.Mutex
+	stamps map[string]fileStamp
+	roots  *x509.CertPool
+	cert   *tls.Certificate
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// newTLSFiles loads the files of the configuration, to connect to the Data Plane API at baseURL.
+func newTLSFiles(config TLSConfig, baseURL string) *tlsFiles {
+	files := &tlsFiles{config: config, serverName: config.ServerName}
+	if u, err := url.Parse(baseURL); err == nil && files.serverName == "" {
+		files.serverName = u.Hostname()
+	}
+	if err := files.reload(); err != nil {
+		logf.Log.Error(err, "Failed to load the TLS files of the HAProxy Data Plane API")
+	}
+	return files
+}
+
+// reload loads the files again if any of them changed since they were last loaded. When loading
+// fails, the previous certificates are kept, as the files may be in the middle of a rotation.
+func (f *tlsFiles) reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stamps := map[string]fileStamp{}
+	for _, name := range []string{f.config.CAFile, f.config.CertFile, f.config.KeyFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		stamps[name] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+	if f.stamps != nil && maps.Equal(stamps, f.stamps) {
+		return nil
+	}
+
+	var roots *x509.CertPool
+	if f.config.CAFile != "" {
+		pem, err := os.ReadFile(f.config.CAFile)
+		if err != nil {
+			return err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in CA bundle %s", f.config.CAFile)
+		}
+	}
+	var cert *tls.Certificate
+	if f.config.CertFile != "" {
+		pair, err := tls.LoadX509KeyPair(f.config.CertFile, f.config.KeyFile)
+		if err != nil {
+			return fmt.Errorf("loading client certificate: %w", err)
+		}
+		cert = &pair
+	}
+
+	if f.stamps != nil {
+		logf.Log.Info("Reloaded the TLS files of the HAProxy Data Plane API")
+	}
+	f.stamps, f.roots, f.cert = stamps, roots, cert
+	return nil
+}
+
+// current returns the loaded certificates, after loading the files again if they changed.
+func (f *tlsFiles) current() (*x509.CertPool, *tls.Certificate, error) {
+	err := f.reload()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stamps == nil {
+		// Nothing was ever loaded
+		return nil, nil, err
+	}
+	if err != nil {
+		logf.Log.Error(err, "Failed to reload the TLS files of the HAProxy Data Plane API, using the previous ones")
+	}
+	return f.roots, f.cert, nil
+}
+
+// clientConfig returns the TLS configuration of the connections to the Data Plane API.
+func (f *tlsFiles) clientConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         f.config.ServerName,
+		InsecureSkipVerify: f.config.InsecureSkipVerify,
+	}
+	if f.config.CAFile != "" && !f.config.InsecureSkipVerify {
+		// The certificate is verified against the current CA bundle in VerifyConnection instead, as
+		// the roots of a tls.Config cannot change
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = f.verifyConnection
+	}
+	if f.config.CertFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			_, cert, err := f.current()
+			if err != nil {
+				return nil, err
+			}
+			return cert, nil
+		}
+	}
+	return config
+}
+
+// verifyConnection verifies the certificate of the Data Plane API against the current CA bundle.
+func (f *tlsFiles) verifyConnection(state tls.ConnectionState) error {
+	roots, _, err := f.current()
+	if err != nil {
+		return err
+	}
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("the Data Plane API presented no certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       f.serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+// newTransport returns the transport of the requests to the Data Plane API at baseURL.
+func newTransport(config TLSConfig, baseURL string) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config != (TLSConfig{}) {
+		transport.TLSClientConfig = newTLSFiles(config, baseURL).clientConfig()
+	}
+	return transport
+}
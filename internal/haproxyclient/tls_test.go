@@ -0,0 +1,164 @@
+package haproxyclient
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCertificate is a certificate and its key, issued by a test CA.
+type testCertificate struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	keyPEM  []byte
+	key     *ecdsa.PrivateKey
+}
+
+// newTestCertificate issues a certificate from parent, or a self-signed CA if parent is nil.
+func newTestCertificate(t *testing.T, name string, parent *testCertificate, usage x509.ExtKeyUsage) *testCertificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	issuer, signer := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// writeFile writes a file with a modification time of at, so that rewrites are detected even
+// within the resolution of the file system clock.
+func writeFile(t *testing.T, name string, data []byte, at time.Time) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, at, at); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLS_MutualTLSWithReload(t *testing.T) {
+	ca := newTestCertificate(t, "haproxy-ca", nil, 0)
+	server := newTestCertificate(t, "haproxy", ca, x509.ExtKeyUsageServerAuth)
+	clientCert := newTestCertificate(t, "operator", ca, x509.ExtKeyUsageClientAuth)
+	otherCA := newTestCertificate(t, "other-ca", nil, 0)
+
+	serverPair, err := tls.X509KeyPair(server.certPEM, server.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "5")
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	// The CA bundle does not issue the server certificate yet
+	dir := t.TempDir()
+	config := TLSConfig{
+		CAFile:   filepath.Join(dir, "ca.crt"),
+		CertFile: filepath.Join(dir, "tls.crt"),
+		KeyFile:  filepath.Join(dir, "tls.key"),
+	}
+	now := time.Now()
+	writeFile(t, config.CAFile, otherCA.certPEM, now)
+	writeFile(t, config.CertFile, clientCert.certPEM, now)
+	writeFile(t, config.KeyFile, clientCert.keyPEM, now)
+	client := NewHAProxyClient(HAProxyConfig{BaseURL: ts.URL, Timeout: 5 * time.Second, MaxRetries: -1, TLS: config})
+
+	if _, err := client.GetConfigVersion(context.Background()); err == nil {
+		t.Fatal("expected the server certificate not to be trusted")
+	}
+
+	// Rotating the CA bundle is picked up without creating a new client
+	writeFile(t, config.CAFile, append(otherCA.certPEM, ca.certPEM...), now.Add(time.Minute))
+	version, err := client.GetConfigVersion(context.Background())
+	if err != nil {
+		t.Fatalf("expected the rotated CA bundle to be used, got %v", err)
+	}
+	if version != 5 {
+		t.Errorf("expected version 5, got %d", version)
+	}
+}
+
+func TestTLS_ServerName(t *testing.T) {
+	ca := newTestCertificate(t, "haproxy-ca", nil, 0)
+	server := newTestCertificate(t, "haproxy", ca, x509.ExtKeyUsageServerAuth)
+	config := TLSConfig{CAFile: filepath.Join(t.TempDir(), "ca.crt")}
+	writeFile(t, config.CAFile, ca.certPEM, time.Now())
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{server.cert}}
+
+	// The certificate is issued for 127.0.0.1 only
+	if err := newTLSFiles(config, "https://127.0.0.1:5555").verifyConnection(state); err != nil {
+		t.Errorf("expected the certificate to be valid for the host of the URL, got %v", err)
+	}
+	if err := newTLSFiles(config, "https://haproxy.internal:5555").verifyConnection(state); err == nil {
+		t.Error("expected the certificate to be verified against the host of the URL")
+	}
+	config.ServerName = "haproxy.internal"
+	if err := newTLSFiles(config, "https://127.0.0.1:5555").verifyConnection(state); err == nil {
+		t.Error("expected the certificate to be verified against the server name")
+	}
+}
+
+func TestTLSConfig_Validate(t *testing.T) {
+	if err := (TLSConfig{CertFile: "tls.crt"}).Validate(); err == nil {
+		t.Error("expected a client certificate without its key to be invalid")
+	}
+	if err := (TLSConfig{CAFile: "ca.crt", CertFile: "tls.crt", KeyFile: "tls.key"}).Validate(); err != nil {
+		t.Errorf("expected a complete configuration to be valid, got %v", err)
+	}
+}
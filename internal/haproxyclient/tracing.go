@@ -0,0 +1,18 @@
+package haproxyclient
+
+import (
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// traceRequests creates a span for every Data Plane API request made by the client, named after its
+// operation, and propagates the trace context to the Data Plane API in the request headers.
+func traceRequests(client *resty.Client) {
+	client.SetTransport(otelhttp.NewTransport(client.GetClient().Transport,
+		otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+			return "haproxy " + operationName(req.Method, req.URL.String())
+		}),
+	))
+}
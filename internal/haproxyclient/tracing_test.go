@@ -0,0 +1,58 @@
+package haproxyclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceRequests(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `7`)
+	}))
+	defer ts.Close()
+	client := NewHAProxyClient(HAProxyConfig{BaseURL: ts.URL, Timeout: 5 * time.Second})
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "reconcile")
+	if _, err := client.GetConfigVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected the request span and its parent, got %d spans", len(spans))
+	}
+	request := spans[0]
+	if request.Name() != "haproxy GET configuration/version" {
+		t.Errorf("expected the span to be named after the operation, got %q", request.Name())
+	}
+	if request.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected the request span to be a child of the span of the context")
+	}
+	want := fmt.Sprintf("00-%s-%s-01", request.SpanContext().TraceID(), request.SpanContext().SpanID())
+	if traceparent != want {
+		t.Errorf("expected the trace context %q to be propagated, got %q", want, traceparent)
+	}
+}
@@ -0,0 +1,142 @@
+package haproxyclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// opValidateTransaction is the operation of the validation request, reported in its errors.
+const opValidateTransaction = "validate transaction"
+
+// parsingLine matches the location HAProxy reports configuration errors at, such as
+// "parsing [/etc/haproxy/haproxy.cfg:23]".
+var parsingLine = regexp.MustCompile(`parsing \[[^\]]*:(\d+)\]`)
+
+// ErrInvalidConfiguration is returned when HAProxy rejects the configuration resulting from a
+// transaction, before it is committed.
+type ErrInvalidConfiguration struct {
+	// Section is the section with the invalid line, such as "backend web", if HAProxy reported it
+	Section string
+	// Line is the invalid line, without its indentation
+	Line string
+	// Index is the number of lines before Line in Section with the same keyword, such as the
+	// index of an http-check rule
+	Index int
+	// Output is the error reported by the configuration check of HAProxy
+	Output string
+}
+
+func (e ErrInvalidConfiguration) Error() string {
+	if e.Line == "" {
+		return "HAProxy rejected the configuration: " + e.Output
+	}
+	return fmt.Sprintf("HAProxy rejected %q in %s: %s", e.Line, e.Section, e.Output)
+}
+
+// Keyword returns the keyword of the invalid line, such as "server" or "http-check".
+func (e ErrInvalidConfiguration) Keyword() string {
+	if fields := strings.Fields(e.Line); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// Argument returns the first argument of the invalid line, such as the name of a server.
+func (e ErrInvalidConfiguration) Argument() string {
+	if fields := strings.Fields(e.Line); len(fields) > 1 {
+		return fields[1]
+	}
+	return ""
+}
+
+// ValidateTransaction runs the configuration check of HAProxy on the configuration resulting from the
+// transaction, without applying it. It returns ErrInvalidConfiguration if HAProxy rejects it.
+func (c *Client) ValidateTransaction(ctx context.Context, id string) error {
+	snapshot, err := c.rawConfiguration(ctx, id)
+	if err != nil {
+		return fmt.Errorf("getting the configuration of transaction %s: %w", id, err)
+	}
+
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Content-Type", "text/plain").
+		SetBody(snapshot.Raw).
+		SetQueryParam("only_validate", "true").
+		SetQueryParam("skip_version", "true").
+		Post(c.configurationURL(section{}, "raw", ""))
+	if err != nil {
+		return fmt.Errorf("api request failed: %w", err)
+	}
+	if resp.IsError() {
+		apiErr := newAPIError(resp, opValidateTransaction)
+		if apiErr.Category() != CategoryInvalid {
+			return apiErr
+		}
+		return newInvalidConfiguration(snapshot.Raw, errorMessage(resp.Body()))
+	}
+	return nil
+}
+
+// errorMessage returns the message of an error response of the Data Plane API.
+func errorMessage(body []byte) string {
+	var apiErr struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Message != "" {
+		return apiErr.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// newInvalidConfiguration returns the error of a configuration that HAProxy rejected with the output,
+// locating the first line HAProxy reported in the configuration.
+func newInvalidConfiguration(raw, output string) ErrInvalidConfiguration {
+	// The output ends with a summary of the errors, which the first error explains best
+	invalid := ErrInvalidConfiguration{Output: strings.TrimSpace(output)}
+	if line := firstLine(output, parsingLine.MatchString); line != "" {
+		invalid.Output = line
+	} else if line := firstLine(output, func(line string) bool { return strings.Contains(line, "[ALERT]") }); line != "" {
+		invalid.Output = line
+	}
+
+	match := parsingLine.FindStringSubmatch(output)
+	if match == nil {
+		return invalid
+	}
+	number, err := strconv.Atoi(match[1])
+	lines := strings.Split(raw, "\n")
+	if err != nil || number < 1 || number > len(lines) {
+		return invalid
+	}
+	invalid.Line = strings.TrimSpace(lines[number-1])
+	keyword := invalid.Keyword()
+	for i := number - 1; i >= 0; i-- {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			// Sections start with an unindented line, such as "backend web"
+			fields := strings.Fields(line)
+			invalid.Section = strings.Join(fields[:min(len(fields), 2)], " ")
+			break
+		}
+		if i < number-1 && strings.Fields(line)[0] == keyword {
+			invalid.Index++
+		}
+	}
+	return invalid
+}
+
+// firstLine returns the first line of the output that matches, without surrounding spaces.
+func firstLine(output string, match func(string) bool) string {
+	for _, line := range strings.Split(output, "\n") {
+		if match(line) {
+			return strings.TrimSpace(line)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package haproxyclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+const invalidConfiguration = `# _version=5
+global
+  daemon
+
+backend web
+  mode http
+  http-check connect
+  http-check send meth GET uri /health
+  http-check expect stauts 200
+  server web-0 10.0.0.1:80 check
+`
+
+func TestNewInvalidConfiguration(t *testing.T) {
+	output := "[NOTICE]   (1) : haproxy version is 3.0.5\n" +
+		"[ALERT]    (1) : config : parsing [/tmp/haproxy.cfg.tmp:9] : 'http-check expect' : unknown match 'stauts'.\n" +
+		"[ALERT]    (1) : config : Error(s) found in configuration file : /tmp/haproxy.cfg.tmp\n"
+	got := newInvalidConfiguration(invalidConfiguration, output)
+	want := ErrInvalidConfiguration{
+		Section: "backend web",
+		Line:    "http-check expect stauts 200",
+		Index:   2,
+		Output:  "[ALERT]    (1) : config : parsing [/tmp/haproxy.cfg.tmp:9] : 'http-check expect' : unknown match 'stauts'.",
+	}
+	if got != want {
+		t.Errorf("got %#v, expected %#v", got, want)
+	}
+	if got.Keyword() != "http-check" || got.Argument() != "expect" {
+		t.Errorf("unexpected keyword %q and argument %q", got.Keyword(), got.Argument())
+	}
+
+	// Errors found after parsing have no line
+	got = newInvalidConfiguration(invalidConfiguration, "[ALERT]    (1) : config : Proxy 'web': unable to find required default_backend: 'app'.\n")
+	if got.Section != "" || got.Line != "" || got.Output != "[ALERT]    (1) : config : Proxy 'web': unable to find required default_backend: 'app'." {
+		t.Errorf("unexpected error %#v", got)
+	}
+}
+
+func TestRunTransaction_ValidationFailed(t *testing.T) {
+	var requests []string
+	client, closeFn := newTestHAProxyClientWithServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.URL.Path == "/v3/services/haproxy/configuration/version":
+			fmt.Fprint(w, "5")
+		case r.URL.Path == "/v3/services/haproxy/transactions" && r.Method == http.MethodPost:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id":"tx1"}`)
+		case r.URL.Path == "/v3/services/haproxy/configuration/raw" && r.Method == http.MethodGet:
+			if r.URL.Query().Get("transaction_id") != "tx1" {
+				t.Errorf("expected the configuration of the transaction, got %s", r.URL)
+			}
+			w.Header().Set("Configuration-Version", "5")
+			fmt.Fprint(w, invalidConfiguration)
+		case r.URL.Path == "/v3/services/haproxy/configuration/raw" && r.Method == http.MethodPost:
+			if r.URL.Query().Get("only_validate") != "true" {
+				t.Errorf("expected the configuration to be validated only, got %s", r.URL)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"code":400,"message":"[ALERT]    (1) : config : parsing [/tmp/haproxy.cfg.tmp:10] : 'server web-0' unknown keyword 'chek'."}`)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	})
+	defer closeFn()
+
+	_, err := client.RunTransaction(context.Background(), func(ctx context.Context) error {
+		client.transactionDirty = true
+		return nil
+	})
+	var invalid ErrInvalidConfiguration
+	if !errors.As(err, &invalid) || !IsInvalid(err) {
+		t.Fatalf("expected an invalid configuration, got %v", err)
+	}
+	if invalid.Section != "backend web" || invalid.Line != "server web-0 10.0.0.1:80 check" {
+		t.Errorf("unexpected invalid line %#v", invalid)
+	}
+	if last := requests[len(requests)-1]; last != "DELETE /v3/services/haproxy/transactions/tx1" {
+		t.Errorf("expected the transaction to be deleted instead of committed, got %v", requests)
+	}
+}
@@ -1,20 +1,29 @@
 package haproxyclient
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/haproxytech/client-native/v6/models"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // GetConfigVersion returns the current HAProxy configuration version
-func (c *Client) GetConfigVersion() (int64, error) {
-	resp, err := c.client.R().
+func (c *Client) GetConfigVersion(ctx context.Context) (int64, error) {
+	resp, err := c.client.R().SetContext(ctx).
 		SetHeader("Accept", "application/json").
-		Get(fmt.Sprintf("%s/v3/services/haproxy/configuration/version", c.config.BaseURL))
+		Get(c.configurationURL(section{}, "version", ""))
 	if err != nil {
 		return 0, err
 	}
+	if resp.IsError() {
+		return 0, newAPIError(resp, "get configuration version")
+	}
 
 	body := strings.TrimSpace(string(resp.Body()))
 	version, err := strconv.ParseInt(body, 10, 64)
@@ -30,3 +39,162 @@ func (c *Client) GetConfigVersion() (int64, error) {
 	}
 	return version, nil
 }
+
+// APIInfo describes the Data Plane API the client talks to, and the HAProxy behind it.
+type APIInfo struct {
+	// APIVersion is the version of the Data Plane API, such as "v3.0.1 2c54b1f".
+	APIVersion string
+	// HAProxyVersion is the version of HAProxy, such as "3.0.5-1", or empty when it is unknown.
+	HAProxyVersion string
+}
+
+// negotiateRetryInterval is how long requests fail after a failed negotiation before negotiating
+// again.
+const negotiateRetryInterval = 5 * time.Second
+
+// negotiationRequest marks the context of the requests of a negotiation.
+type negotiationRequest struct{}
+
+// Negotiate detects the versions of the Data Plane API and of HAProxy, so that the next requests
+// use the layout of the Data Plane API version and the configuration is checked against the
+// capabilities of HAProxy. Requests use the layout of the Data Plane API v3 until then. When it
+// fails, the other requests fail with ErrUnavailable until negotiating again succeeds, which they
+// attempt at most every negotiateRetryInterval, rather than using a layout that may be wrong.
+func (c *Client) Negotiate(ctx context.Context) (APIInfo, error) {
+	c.negotiateMu.Lock()
+	defer c.negotiateMu.Unlock()
+	return c.negotiate(ctx)
+}
+
+// negotiate detects the versions, callers must hold negotiateMu.
+func (c *Client) negotiate(ctx context.Context) (APIInfo, error) {
+	info, api, err := c.detectVersions(context.WithValue(ctx, negotiationRequest{}, true))
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+	c.lastNegotiation = time.Now()
+	c.negotiateErr = err
+	if err != nil {
+		return info, err
+	}
+	c.api, c.haproxyVersion = api, info.HAProxyVersion
+	return info, nil
+}
+
+// detectVersions returns the versions of the Data Plane API and of HAProxy, and the layout of the
+// version of the Data Plane API.
+func (c *Client) detectVersions(ctx context.Context) (APIInfo, dialect, error) {
+	var info APIInfo
+	var err error
+	// The Data Plane API v2 does not serve the v3 paths
+	for _, api := range []dialect{dialectV3{}, dialectV2{}} {
+		info.APIVersion, err = c.getAPIVersion(ctx, api)
+		if !IsNotFound(err) {
+			break
+		}
+	}
+	if err != nil {
+		return APIInfo{}, nil, err
+	}
+	api, err := dialectFor(info.APIVersion)
+	if err != nil {
+		return info, nil, err
+	}
+
+	info.HAProxyVersion, err = c.getHAProxyVersion(ctx, api)
+	if err != nil {
+		// The configuration is then validated by the Data Plane API only
+		logf.FromContext(ctx).Error(err, "Failed to get the HAProxy version, not checking its capabilities")
+	}
+	return info, api, nil
+}
+
+// requireNegotiation fails the requests made after a failed negotiation with ErrUnavailable, and
+// negotiates again once negotiateRetryInterval passed. It is called before each request.
+func (c *Client) requireNegotiation(_ *resty.Client, req *resty.Request) error {
+	if req.Context().Value(negotiationRequest{}) != nil {
+		return nil
+	}
+	c.versionMu.RLock()
+	err := c.negotiateErr
+	c.versionMu.RUnlock()
+	if err == nil {
+		return nil
+	}
+
+	c.negotiateMu.Lock()
+	defer c.negotiateMu.Unlock()
+	c.versionMu.RLock()
+	err, last := c.negotiateErr, c.lastNegotiation
+	c.versionMu.RUnlock()
+	if err != nil && time.Since(last) >= negotiateRetryInterval {
+		var info APIInfo
+		info, err = c.negotiate(req.Context())
+		if err == nil {
+			logf.FromContext(req.Context()).Info("Detected the HAProxy Data Plane API", "apiVersion", info.APIVersion, "haproxyVersion", info.HAProxyVersion)
+		}
+	}
+	switch {
+	case err != nil:
+		return fmt.Errorf("%w: detecting the Data Plane API version: %v", ErrUnavailable, err)
+	case c.dialect().name() != "v3":
+		// The request was prepared with the layout of v3, which the client used until now
+		return fmt.Errorf("%w: the Data Plane API version was detected after the request was prepared", ErrUnavailable)
+	}
+	return nil
+}
+
+// getAPIVersion returns the version of the Data Plane API from the info endpoint of a dialect.
+func (c *Client) getAPIVersion(ctx context.Context, api dialect) (string, error) {
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		Get(c.config.BaseURL + "/" + api.name() + "/info")
+	if err != nil {
+		return "", err
+	}
+	if resp.IsError() {
+		return "", newAPIError(resp, "get info")
+	}
+	var info models.Info
+	if err := json.Unmarshal(resp.Body(), &info); err != nil {
+		return "", fmt.Errorf("unmarshaling response: %w", err)
+	}
+	if info.API == nil || info.API.Version == "" {
+		return "", fmt.Errorf("no API version in info: %s", resp.Body())
+	}
+	return info.API.Version, nil
+}
+
+// getHAProxyVersion returns the version of the running HAProxy process from the runtime info
+// endpoint of a dialect.
+func (c *Client) getHAProxyVersion(ctx context.Context, api dialect) (string, error) {
+	resp, err := c.client.R().SetContext(ctx).
+		SetHeader("Accept", "application/json").
+		Get(c.config.BaseURL + api.path("runtime/info"))
+	if err != nil {
+		return "", err
+	}
+	if resp.IsError() {
+		return "", newAPIError(resp, "get runtime info")
+	}
+	// The Data Plane API v2 returns the info of each process
+	var processes []*models.ProcessInfo
+	if err := json.Unmarshal(resp.Body(), &processes); err != nil {
+		process := &models.ProcessInfo{}
+		if err := json.Unmarshal(resp.Body(), process); err != nil {
+			return "", fmt.Errorf("unmarshaling response: %w", err)
+		}
+		processes = []*models.ProcessInfo{process}
+	}
+	for _, process := range processes {
+		if process == nil {
+			continue
+		}
+		if process.Error != "" {
+			return "", fmt.Errorf("getting runtime info: %s", process.Error)
+		}
+		if process.Info != nil && process.Info.Version != "" {
+			return process.Info.Version, nil
+		}
+	}
+	return "", fmt.Errorf("no HAProxy version in runtime info: %s", resp.Body())
+}
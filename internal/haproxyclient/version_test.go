@@ -1,6 +1,7 @@
 package haproxyclient
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -13,8 +14,10 @@ func newTestHAProxyClientWithServer(t *testing.T, handler http.HandlerFunc) (*Cl
 	t.Helper()
 	ts := httptest.NewServer(handler)
 	config := HAProxyConfig{
-		BaseURL: ts.URL,
-		Timeout: 5 * time.Second,
+		BaseURL:          ts.URL,
+		Timeout:          5 * time.Second,
+		RetryWaitTime:    time.Millisecond,
+		RetryMaxWaitTime: 5 * time.Millisecond,
 	}
 	client := NewHAProxyClient(config).(*Client)
 	return client, ts.Close
@@ -25,7 +28,7 @@ func TestGetConfigVersion_PlainInt(t *testing.T) {
 		fmt.Fprint(w, "42")
 	})
 	defer closeFn()
-	version, err := client.GetConfigVersion()
+	version, err := client.GetConfigVersion(context.Background())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -39,7 +42,7 @@ func TestGetConfigVersion_JSON(t *testing.T) {
 		fmt.Fprint(w, `{"_version":99}`)
 	})
 	defer closeFn()
-	version, err := client.GetConfigVersion()
+	version, err := client.GetConfigVersion(context.Background())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -53,7 +56,7 @@ func TestGetConfigVersion_Invalid(t *testing.T) {
 		fmt.Fprint(w, "notanumber")
 	})
 	defer closeFn()
-	_, err := client.GetConfigVersion()
+	_, err := client.GetConfigVersion(context.Background())
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup_Disabled(t *testing.T) {
+	provider := otel.GetTracerProvider()
+
+	shutdown, err := Setup(context.Background(), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetTracerProvider() != provider {
+		t.Error("expected the tracer provider to be left unset when tracing is disabled")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("expected shutting down disabled tracing to succeed, got %v", err)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	provider, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(provider)
+		otel.SetTextMapPropagator(propagator)
+	})
+
+	// The exporter connects lazily, so no collector is needed
+	shutdown, err := Setup(context.Background(), Config{Endpoint: "localhost:4317", Insecure: true, SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetTracerProvider() == provider {
+		t.Error("expected the tracer provider to be set")
+	}
+	if fields := otel.GetTextMapPropagator().Fields(); len(fields) == 0 {
+		t.Error("expected the trace context to be propagated")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = shutdown(ctx)
+}
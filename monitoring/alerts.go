@@ -9,11 +9,225 @@ import (
 const (
 	ruleName                     = "external-haproxy-operator-rules"
 	alertRuleGroup               = "external-haproxy-operator.rules"
+	recordingRuleGroup           = "external-haproxy-operator.recording.rules"
 	haProxyClientErrorCountAlert = "HAProxyClientErrorCountTotal"
 	runbookURLBasePath           = "https://github.com/ullbergm/external-haproxy-operator/" +
 		"tree/master/docs/monitoring/runbooks/"
 )
 
+// Alert defines an alerting rule of the operator together with its runbook, so that the
+// PrometheusRule and the runbook documentation are generated from the same definition.
+type Alert struct {
+	Name     string
+	Expr     string
+	For      string
+	Severity string
+	Summary  string
+	// Description is the description annotation of the alert, and introduces its runbook.
+	Description string
+	// RunbookName is the name of the runbook document, without extension. Defaults to Name.
+	RunbookName string
+	Causes      []string
+	Diagnosis   []string
+	Mitigation  []string
+}
+
+// Runbook returns the name of the runbook document of the alert, without extension.
+func (a Alert) Runbook() string {
+	if a.RunbookName != "" {
+		return a.RunbookName
+	}
+	return a.Name
+}
+
+// RunbookURL returns the URL of the runbook of the alert.
+func (a Alert) RunbookURL() string {
+	return runbookURLBasePath + a.Runbook() + ".md"
+}
+
+// RecordingRule defines a recording rule of the operator.
+type RecordingRule struct {
+	Record string
+	Expr   string
+	Help   string
+}
+
+// alerts are the alerting rules shipped in the PrometheusRule of the operator.
+var alerts = []Alert{
+	{
+		Name:        haProxyClientErrorCountAlert,
+		Expr:        "increase(haproxy_client_errors_count_total[5m]) > 0",
+		Severity:    "warning",
+		Summary:     "The operator gets errors from the HAProxy Data Plane API.",
+		Description: "HAProxy client has encountered errors in the last 5 minutes.",
+		RunbookName: "HAProxyClientErrorCount",
+		Causes: []string{
+			"The Data Plane API rejected a configuration change, for example because of an invalid Backend.",
+			"The Data Plane API was unreachable or timed out.",
+			"Concurrent changes to the HAProxy configuration caused version conflicts.",
+		},
+		Diagnosis: []string{
+			"Check the operator logs for the failed Data Plane API operation: `kubectl logs -n <namespace> deploy/external-haproxy-operator-controller-manager`.",
+			"Check the `haproxy_client_request_duration_seconds_count` metric by `operation` and `code` to find the failing requests.",
+			"Check the `ReconcilingComplete` condition of the Backend, Resolver and Cutover resources.",
+		},
+		Mitigation: []string{
+			"Fix the resources whose changes are rejected by the Data Plane API.",
+			"Restore the connectivity between the operator and the Data Plane API.",
+		},
+	},
+	{
+		Name:     "HAProxyUnreachable",
+		Expr:     `sum(code:haproxy_client_requests:rate5m{code="error"}) > 0 unless sum(code:haproxy_client_requests:rate5m{code!="error"}) > 0`,
+		For:      "5m",
+		Severity: "critical",
+		Summary:  "The operator cannot reach the HAProxy Data Plane API.",
+		Description: "No request of the operator to the HAProxy Data Plane API has received a response in the last 5 minutes. " +
+			"Configuration changes are not applied to HAProxy.",
+		Causes: []string{
+			"The Data Plane API or the HAProxy host is down.",
+			"The HAPROXY_API_URL of the operator is wrong, or the network between the operator and the Data Plane API is broken.",
+			"A firewall or a NetworkPolicy blocks the traffic of the operator.",
+		},
+		Diagnosis: []string{
+			"Check the operator logs for connection errors.",
+			"Check that the Data Plane API answers from the operator namespace: `curl -u <user> <HAPROXY_API_URL>/v3/info`.",
+			"Check the status of the Data Plane API service on the HAProxy host.",
+			"Check the `haproxy_client_circuit_open` metric: while it is 1, the operator short-circuits its requests, reports not ready, and requeues the Backends with the `HAProxyUnavailable` reason.",
+		},
+		Mitigation: []string{
+			"Restart the Data Plane API or HAProxy on the HAProxy host.",
+			"Fix the HAPROXY_API_URL of the operator, or the network path to the Data Plane API.",
+		},
+	},
+	{
+		Name:        "HAProxyBackendNotReconciled",
+		Expr:        "haproxy_backend_reconciled == 0",
+		For:         "15m",
+		Severity:    "warning",
+		Summary:     "A Backend has not been reconciled for 15 minutes.",
+		Description: "The Backend {{ $labels.namespace }}/{{ $labels.name }} has failed to reconcile for 15 minutes, so HAProxy may not match it.",
+		Causes: []string{
+			"The Backend is invalid, or references Services, Resolvers or ports that do not exist.",
+			"A reference to a Service in another namespace is not permitted by a ReferenceGrant.",
+			"The Data Plane API rejects the backend or is unreachable.",
+			"HAProxy failed to reload with the changes, and runs its previous configuration.",
+			"The configuration check of HAProxy rejects the changes, which reports the reason `ValidationFailed`.",
+		},
+		Diagnosis: []string{
+			"Check the `ReconcilingComplete` condition and the events of the Backend: `kubectl describe backend -n <namespace> <name>`.",
+			"Check the `Reloaded` condition of the Backend for the error HAProxy reported when reloading.",
+			"Check the `haproxy_operator_reconcile_failures_total` metric for the reason of the failures.",
+		},
+		Mitigation: []string{
+			"Fix the Backend or the resources it references, following the reason of the condition.",
+			"If the Data Plane API is failing, follow the HAProxyUnreachable and HAProxyClientErrorCount runbooks.",
+		},
+	},
+	{
+		Name:        "HAProxyBackendAllServersDown",
+		Expr:        "haproxy_backend_servers > 0 and haproxy_backend_up_servers == 0",
+		For:         "5m",
+		Severity:    "critical",
+		Summary:     "All servers of a managed backend are DOWN.",
+		Description: "HAProxy reports none of the servers of backend {{ $labels.backend }} of Backend {{ $labels.namespace }}/{{ $labels.name }} UP, so it cannot serve traffic.",
+		Causes: []string{
+			"The application behind the servers is down or failing its health checks.",
+			"The servers are in maintenance or drained.",
+			"The HAProxy host cannot reach the servers, for example because of the address mode of the Backend.",
+		},
+		Diagnosis: []string{
+			"Check the `status.servers` of the Backend for the state and the last health check of each server.",
+			"Check the pods and the endpoints of the referenced Services.",
+			"Check that the server addresses are reachable from the HAProxy host.",
+		},
+		Mitigation: []string{
+			"Restore the application behind the servers.",
+			"Fix the health check of the Backend if it fails for healthy servers.",
+			"Use an address mode that is reachable from the HAProxy host, such as NodePort or LoadBalancer.",
+		},
+	},
+	{
+		Name:     "HAProxyConfigDriftDetected",
+		Expr:     "increase(haproxy_backend_drift_corrections_total[30m]) > 0",
+		Severity: "warning",
+		Summary:  "The configuration of a managed backend was changed outside of the operator.",
+		Description: "The operator corrected backend {{ $labels.backend }} of Backend {{ $labels.namespace }}/{{ $labels.name }} " +
+			"in the last 30 minutes although the Backend had not changed.",
+		Causes: []string{
+			"Someone changed the backend through the Data Plane API, the HAProxy configuration file or the runtime API.",
+			"Another operator instance or automation manages the same backend.",
+		},
+		Diagnosis: []string{
+			"Check the events of the Backend for `DriftCorrected`.",
+			"Check the Data Plane API logs and the HAProxy configuration history for the changes.",
+		},
+		Mitigation: []string{
+			"Make the changes through the Backend resource instead of in HAProxy.",
+			"Make sure that a single operator instance manages each backend.",
+		},
+	},
+	{
+		Name:        "HAProxyOrphanedBackends",
+		Expr:        "haproxy_operator_orphaned_backends > 0",
+		For:         "30m",
+		Severity:    "warning",
+		Summary:     "HAProxy has managed backends without a Backend resource.",
+		Description: "{{ $value }} backends in HAProxy are marked as managed by the operator, but no Backend resource manages them.",
+		Causes: []string{
+			"A Backend was deleted while its finalizer was removed by hand, or while the operator was not running.",
+			"The name of the HAProxy backend of a Backend was changed, leaving the previous backend behind.",
+			"Another operator instance with a different watch namespace or label selector manages the backends.",
+		},
+		Diagnosis: []string{
+			"List the backends in HAProxy with the description `managed-by=external-haproxy-controller`, and compare them with `kubectl get backends -A`.",
+		},
+		Mitigation: []string{
+			"Delete the orphaned backends through the Data Plane API, once no frontend uses them.",
+			"Recreate the Backend resource if the backend is still needed.",
+		},
+	},
+}
+
+// recordingRules are the recording rules shipped in the PrometheusRule of the operator.
+var recordingRules = []RecordingRule{
+	{
+		Record: "code:haproxy_client_requests:rate5m",
+		Expr:   "sum by (code) (rate(haproxy_client_request_duration_seconds_count[5m]))",
+		Help:   "Rate of Data Plane API requests by status code, or error when no response was received.",
+	},
+	{
+		Record: "operation:haproxy_client_request_duration_seconds:p99",
+		Expr:   "histogram_quantile(0.99, sum by (operation, le) (rate(haproxy_client_request_duration_seconds_bucket[5m])))",
+		Help:   "99th percentile latency of Data Plane API requests by operation.",
+	},
+	{
+		Record: "result:haproxy_client_transactions:rate5m",
+		Expr:   "sum by (result) (rate(haproxy_client_transactions_total[5m]))",
+		Help:   "Rate of Data Plane API transactions by result.",
+	},
+	{
+		Record: "controller_reason:haproxy_operator_reconcile_failures:rate5m",
+		Expr:   "sum by (controller, reason) (rate(haproxy_operator_reconcile_failures_total[5m]))",
+		Help:   "Rate of failed reconciliations by controller and reason.",
+	},
+	{
+		Record: "backend:haproxy_backend_up_servers:ratio",
+		Expr:   "haproxy_backend_up_servers / (haproxy_backend_servers > 0)",
+		Help:   "Ratio of the servers of each managed backend that HAProxy reports UP.",
+	},
+}
+
+// ListAlerts returns the alerting rules of the operator.
+func ListAlerts() []Alert {
+	return append([]Alert(nil), alerts...)
+}
+
+// ListRecordingRules returns the recording rules of the operator.
+func ListRecordingRules() []RecordingRule {
+	return append([]RecordingRule(nil), recordingRules...)
+}
+
 // NewPrometheusRule creates new PrometheusRule(CR) for the operator to have alerts and recording rules
 func NewPrometheusRule(namespace string) *monitoringv1.PrometheusRule {
 	return &monitoringv1.PrometheusRule{
@@ -24,6 +238,10 @@ func NewPrometheusRule(namespace string) *monitoringv1.PrometheusRule {
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      ruleName,
 			Namespace: namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":       "external-haproxy-operator",
+				"app.kubernetes.io/managed-by": "external-haproxy-operator",
+			},
 		},
 		Spec: *NewPrometheusRuleSpec(),
 	}
@@ -31,28 +249,48 @@ func NewPrometheusRule(namespace string) *monitoringv1.PrometheusRule {
 
 // NewPrometheusRuleSpec creates PrometheusRuleSpec for alerts and recording rules
 func NewPrometheusRuleSpec() *monitoringv1.PrometheusRuleSpec {
+	alertRules := make([]monitoringv1.Rule, 0, len(alerts))
+	for _, alert := range alerts {
+		alertRules = append(alertRules, alert.rule())
+	}
+	recordRules := make([]monitoringv1.Rule, 0, len(recordingRules))
+	for _, rule := range recordingRules {
+		recordRules = append(recordRules, monitoringv1.Rule{
+			Record: rule.Record,
+			Expr:   intstr.FromString(rule.Expr),
+		})
+	}
 	return &monitoringv1.PrometheusRuleSpec{
-		Groups: []monitoringv1.RuleGroup{{
-			Name: alertRuleGroup,
-			Rules: []monitoringv1.Rule{
-				createhaProxyClientErrorCountAlertRule(),
+		Groups: []monitoringv1.RuleGroup{
+			{
+				Name:  recordingRuleGroup,
+				Rules: recordRules,
+			},
+			{
+				Name:  alertRuleGroup,
+				Rules: alertRules,
 			},
-		}},
+		},
 	}
 }
 
-// createhaProxyClientErrorCountAlertRule creates HAProxyClientErrorCountTotal alert rule that triggers
-// when the HAProxy client error count exceeds a threshold for the last 5 minutes.
-func createhaProxyClientErrorCountAlertRule() monitoringv1.Rule {
-	return monitoringv1.Rule{
-		Alert: haProxyClientErrorCountAlert,
-		Expr:  intstr.FromString("increase(haproxy_client_errors_count_total[5m]) > 0"),
+// rule creates the alerting rule of the alert.
+func (a Alert) rule() monitoringv1.Rule {
+	rule := monitoringv1.Rule{
+		Alert: a.Name,
+		Expr:  intstr.FromString(a.Expr),
 		Annotations: map[string]string{
-			"description": "HAProxy client has encountered errors in the last 5 minutes.",
+			"summary":     a.Summary,
+			"description": a.Description,
 		},
 		Labels: map[string]string{
-			"severity":    "warning",
-			"runbook_url": runbookURLBasePath + "HAProxyClientErrorCount.md",
+			"severity":    a.Severity,
+			"runbook_url": a.RunbookURL(),
 		},
 	}
+	if a.For != "" {
+		duration := monitoringv1.Duration(a.For)
+		rule.For = &duration
+	}
+	return rule
 }
@@ -0,0 +1,468 @@
+package monitoring
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	dashboardUID   = "external-haproxy-operator-backends"
+	dashboardTitle = "External HAProxy Operator / Backends"
+	datasource     = "${DS_PROMETHEUS}"
+	// backendSelector selects the series of the Backend resources chosen in the dashboard variables.
+	backendSelector = `namespace=~"$namespace", name=~"$name"`
+	panelsPerRow    = 3
+	panelWidth      = 24 / panelsPerRow
+	panelHeight     = 8
+)
+
+// Dashboard is a Grafana dashboard, with the subset of the model the operator uses.
+type Dashboard struct {
+	Inputs        []DashboardInput  `json:"__inputs"`
+	Requires      []DashboardPlugin `json:"__requires"`
+	UID           string            `json:"uid"`
+	Title         string            `json:"title"`
+	Tags          []string          `json:"tags"`
+	Editable      bool              `json:"editable"`
+	GraphTooltip  int               `json:"graphTooltip"`
+	Refresh       string            `json:"refresh"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Time          DashboardTime     `json:"time"`
+	Templating    Templating        `json:"templating"`
+	Panels        []Panel           `json:"panels"`
+}
+
+// DashboardInput is a datasource the dashboard asks for when it is imported.
+type DashboardInput struct {
+	Name     string `json:"name"`
+	Label    string `json:"label"`
+	Type     string `json:"type"`
+	PluginID string `json:"pluginId"`
+}
+
+// DashboardPlugin is a plugin the dashboard requires.
+type DashboardPlugin struct {
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// DashboardTime is the default time range of the dashboard.
+type DashboardTime struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Templating holds the variables of the dashboard.
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+// Variable is a query variable of the dashboard.
+type Variable struct {
+	Name       string `json:"name"`
+	Label      string `json:"label"`
+	Type       string `json:"type"`
+	Datasource string `json:"datasource"`
+	Query      string `json:"query"`
+	Definition string `json:"definition"`
+	Refresh    int    `json:"refresh"`
+	IncludeAll bool   `json:"includeAll"`
+	Multi      bool   `json:"multi"`
+	Sort       int    `json:"sort"`
+}
+
+// Panel is a panel or a row of the dashboard.
+type Panel struct {
+	ID          int          `json:"id"`
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	Datasource  string       `json:"datasource,omitempty"`
+	GridPos     GridPos      `json:"gridPos"`
+	FieldConfig *FieldConfig `json:"fieldConfig,omitempty"`
+	Targets     []Target     `json:"targets,omitempty"`
+	Collapsed   *bool        `json:"collapsed,omitempty"`
+}
+
+// GridPos is the position of a panel.
+type GridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// FieldConfig configures how the values of a panel are displayed.
+type FieldConfig struct {
+	Defaults FieldDefaults `json:"defaults"`
+}
+
+// FieldDefaults are the display defaults of the values of a panel.
+type FieldDefaults struct {
+	Unit   string            `json:"unit,omitempty"`
+	Custom map[string]string `json:"custom,omitempty"`
+}
+
+// Target is a query of a panel.
+type Target struct {
+	Datasource   string `json:"datasource"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	RefID        string `json:"refId"`
+}
+
+// dashboardPanel is a panel of the dashboard before it is laid out.
+type dashboardPanel struct {
+	panelType   string
+	title       string
+	description string
+	unit        string
+	bars        bool
+	// metrics are the operator metrics the queries use, so that they are not generated again.
+	metrics []string
+	queries []query
+}
+
+type query struct {
+	expr   string
+	legend string
+}
+
+// dashboardRow is a row of panels of the dashboard.
+type dashboardRow struct {
+	title  string
+	panels []dashboardPanel
+}
+
+// metricName returns the name of an operator metric, and panics if it is not defined, so that
+// the dashboard cannot refer to metrics that were renamed or removed.
+func metricName(name string) string {
+	for _, metric := range metricDescription {
+		if metric.Name == name {
+			return name
+		}
+	}
+	panic(fmt.Sprintf("dashboard refers to undefined metric %q", name))
+}
+
+// overviewRows are the rows of the dashboard with curated panels. Metrics they do not use get
+// a generated panel in the rows that follow them.
+func overviewRows() []dashboardRow {
+	upServers := metricName("haproxy_backend_up_servers")
+	servers := metricName("haproxy_backend_servers")
+	serverUp := metricName("haproxy_backend_server_up")
+	responses5xx := metricName("haproxy_backend_server_http_responses_5xx_total")
+	checkFailures := metricName("haproxy_backend_server_check_failures")
+	reconciled := metricName("haproxy_backend_reconciled")
+	failures := metricName("haproxy_operator_reconcile_failures_total")
+	transactions := metricName("haproxy_client_transactions_total")
+	reloads := metricName("haproxy_client_reload_commits_total")
+	requests := metricName("haproxy_client_request_duration_seconds")
+	drift := metricName("haproxy_backend_drift_corrections_total")
+	orphans := metricName("haproxy_operator_orphaned_backends")
+
+	return []dashboardRow{
+		{
+			title: "Server Health",
+			panels: []dashboardPanel{
+				{
+					panelType:   "timeseries",
+					title:       "Servers UP",
+					description: "Servers of each backend that HAProxy reports UP, out of all its servers.",
+					metrics:     []string{upServers, servers},
+					queries: []query{
+						{expr: upServers + "{" + backendSelector + "}", legend: "{{namespace}}/{{name}} UP"},
+						{expr: servers + "{" + backendSelector + "}", legend: "{{namespace}}/{{name}} total"},
+					},
+				},
+				{
+					panelType:   "state-timeline",
+					title:       "Server state",
+					description: "Whether HAProxy reports each server UP (1) or not (0).",
+					metrics:     []string{serverUp},
+					queries: []query{
+						{expr: serverUp + "{" + backendSelector + "}", legend: "{{namespace}}/{{name}} {{server}}"},
+					},
+				},
+				{
+					panelType:   "timeseries",
+					title:       "HTTP 5xx responses",
+					description: "Rate of HTTP responses with a 5xx status from each server.",
+					unit:        "reqps",
+					metrics:     []string{responses5xx},
+					queries: []query{{
+						expr:   "sum by (namespace, name, server) (rate(" + responses5xx + "{" + backendSelector + "}[$__rate_interval]))",
+						legend: "{{namespace}}/{{name}} {{server}}",
+					}},
+				},
+				{
+					panelType:   "timeseries",
+					title:       "Health check failures",
+					description: "Failed health checks of each server.",
+					bars:        true,
+					metrics:     []string{checkFailures},
+					queries: []query{{
+						expr:   "sum by (namespace, name, server) (increase(" + checkFailures + "{" + backendSelector + "}[$__rate_interval]))",
+						legend: "{{namespace}}/{{name}} {{server}}",
+					}},
+				},
+				{
+					panelType:   "state-timeline",
+					title:       "Reconciled",
+					description: "Whether the last reconciliation of each Backend succeeded (1) or not (0).",
+					metrics:     []string{reconciled},
+					queries: []query{
+						{expr: reconciled + "{" + backendSelector + "}", legend: "{{namespace}}/{{name}}"},
+					},
+				},
+			},
+		},
+		{
+			title: "Reconciliation",
+			panels: []dashboardPanel{
+				{
+					panelType:   "timeseries",
+					title:       "Reconcile latency (p99)",
+					description: "99th percentile of the time the reconcilers take per reconciliation.",
+					unit:        "s",
+					queries: []query{{
+						expr: "histogram_quantile(0.99, sum by (controller, le) " +
+							`(rate(controller_runtime_reconcile_time_seconds_bucket{controller=~"backend|resolver|cutover"}[$__rate_interval])))`,
+						legend: "{{controller}}",
+					}},
+				},
+				{
+					panelType:   "timeseries",
+					title:       "Reconcile failures",
+					description: "Failed reconciliations by controller and reason.",
+					unit:        "ops",
+					metrics:     []string{failures},
+					queries: []query{
+						{expr: "sum by (controller, reason) (rate(" + failures + "[$__rate_interval]))", legend: "{{controller}} {{reason}}"},
+					},
+				},
+				{
+					panelType:   "timeseries",
+					title:       "Drift corrections",
+					description: "Corrections of backends that were changed in HAProxy outside of the operator.",
+					bars:        true,
+					metrics:     []string{drift},
+					queries: []query{
+						{expr: "sum by (namespace, name) (increase(" + drift + "{" + backendSelector + "}[$__rate_interval]))", legend: "{{namespace}}/{{name}}"},
+					},
+				},
+			},
+		},
+		{
+			title: "Data Plane API",
+			panels: []dashboardPanel{
+				{
+					panelType:   "timeseries",
+					title:       "Transactions and reloads",
+					description: "Data Plane API transactions by result, and commits that made HAProxy reload.",
+					unit:        "ops",
+					metrics:     []string{transactions, reloads},
+					queries: []query{
+						{expr: "sum by (result) (rate(" + transactions + "[$__rate_interval]))", legend: "{{result}}"},
+						{expr: "sum(rate(" + reloads + "[$__rate_interval]))", legend: "reloads"},
+					},
+				},
+				{
+					panelType:   "timeseries",
+					title:       "API errors by operation",
+					description: "Data Plane API requests that failed or did not get a response, by operation and status code.",
+					unit:        "ops",
+					metrics:     []string{requests},
+					queries: []query{{
+						expr:   `sum by (operation, code) (rate(` + requests + `_count{code!~"2.."}[$__rate_interval]))`,
+						legend: "{{operation}} {{code}}",
+					}},
+				},
+				{
+					panelType:   "timeseries",
+					title:       "API latency by operation (p99)",
+					description: "99th percentile latency of Data Plane API requests by operation.",
+					unit:        "s",
+					metrics:     []string{requests},
+					queries: []query{{
+						expr:   "histogram_quantile(0.99, sum by (operation, le) (rate(" + requests + "_bucket[$__rate_interval])))",
+						legend: "{{operation}}",
+					}},
+				},
+				{
+					panelType:   "stat",
+					title:       "Orphaned backends",
+					description: "Backends in HAProxy marked as managed by the operator that no Backend resource manages.",
+					metrics:     []string{orphans},
+					queries:     []query{{expr: "max(" + orphans + ")", legend: "orphans"}},
+				},
+			},
+		},
+	}
+}
+
+// metricPanel generates a panel for an operator metric from its definition: gauges are shown
+// as they are, counters as rates and histograms as their 99th percentile.
+func metricPanel(metric MetricDescription) dashboardPanel {
+	selector := ""
+	if slices.Contains(metric.Labels, "namespace") && slices.Contains(metric.Labels, "name") {
+		selector = "{" + backendSelector + "}"
+	}
+	legends := make([]string, 0, len(metric.Labels))
+	for _, label := range metric.Labels {
+		legends = append(legends, "{{"+label+"}}")
+	}
+	legend := strings.Join(legends, " ")
+	sum := "sum"
+	if len(metric.Labels) > 0 {
+		sum = "sum by (" + strings.Join(metric.Labels, ", ") + ")"
+	}
+
+	unit := ""
+	if strings.HasSuffix(metric.Name, "_seconds") {
+		unit = "s"
+	}
+	panel := dashboardPanel{
+		panelType:   "timeseries",
+		title:       metric.Name,
+		description: metric.Help,
+		unit:        unit,
+		metrics:     []string{metric.Name},
+	}
+	switch metric.Type {
+	case "Counter":
+		panel.unit = "ops"
+		panel.queries = []query{{expr: sum + " (rate(" + metric.Name + selector + "[$__rate_interval]))", legend: legend}}
+	case "Histogram":
+		panel.queries = []query{{
+			expr:   "histogram_quantile(0.99, sum by (" + strings.Join(append(slices.Clone(metric.Labels), "le"), ", ") + ") (rate(" + metric.Name + "_bucket" + selector + "[$__rate_interval])))",
+			legend: legend,
+		}}
+	default:
+		panel.queries = []query{{expr: metric.Name + selector, legend: legend}}
+	}
+	if legend == "" {
+		panel.queries[0].legend = metric.Name
+	}
+	return panel
+}
+
+// generatedRows returns rows with a generated panel for each operator metric the curated rows
+// do not use, grouped by backend metrics, server metrics and operator metrics.
+func generatedRows(used map[string]bool) []dashboardRow {
+	metrics := ListMetrics()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+
+	rows := []dashboardRow{{title: "Servers"}, {title: "Backends"}, {title: "Operator"}}
+	for _, metric := range metrics {
+		if used[metric.Name] {
+			continue
+		}
+		row := &rows[2]
+		if slices.Contains(metric.Labels, "server") {
+			row = &rows[0]
+		} else if slices.Contains(metric.Labels, "name") {
+			row = &rows[1]
+		}
+		row.panels = append(row.panels, metricPanel(metric))
+	}
+	return rows
+}
+
+// NewDashboard creates the Grafana dashboard of the Backends managed by the operator. It has
+// curated panels for the health of the servers, the reconciliations and the Data Plane API, and
+// a generated panel for each other operator metric, so that new metrics appear on it.
+func NewDashboard() *Dashboard {
+	rows := overviewRows()
+	used := map[string]bool{}
+	for _, row := range rows {
+		for _, panel := range row.panels {
+			for _, metric := range panel.metrics {
+				used[metric] = true
+			}
+		}
+	}
+	rows = append(rows, generatedRows(used)...)
+
+	dashboard := &Dashboard{
+		Inputs: []DashboardInput{{
+			Name: "DS_PROMETHEUS", Label: "Prometheus", Type: "datasource", PluginID: "prometheus",
+		}},
+		Requires: []DashboardPlugin{{
+			Type: "datasource", ID: "prometheus", Name: "Prometheus", Version: "1.0.0",
+		}},
+		UID:           dashboardUID,
+		Title:         dashboardTitle,
+		Tags:          []string{"external-haproxy-operator", "haproxy"},
+		Editable:      true,
+		GraphTooltip:  1,
+		Refresh:       "30s",
+		SchemaVersion: 38,
+		Time:          DashboardTime{From: "now-6h", To: "now"},
+		Templating: Templating{List: []Variable{
+			{
+				Name: "namespace", Label: "Namespace", Type: "query", Datasource: datasource,
+				Query:      "label_values(" + metricName("haproxy_backend_reconciled") + ", namespace)",
+				Definition: "label_values(" + metricName("haproxy_backend_reconciled") + ", namespace)",
+				Refresh:    2, IncludeAll: true, Multi: true, Sort: 1,
+			},
+			{
+				Name: "name", Label: "Backend", Type: "query", Datasource: datasource,
+				Query:      "label_values(" + metricName("haproxy_backend_reconciled") + `{namespace=~"$namespace"}, name)`,
+				Definition: "label_values(" + metricName("haproxy_backend_reconciled") + `{namespace=~"$namespace"}, name)`,
+				Refresh:    2, IncludeAll: true, Multi: true, Sort: 1,
+			},
+		}},
+	}
+
+	id, y := 1, 0
+	for _, row := range rows {
+		if len(row.panels) == 0 {
+			continue
+		}
+		collapsed := false
+		dashboard.Panels = append(dashboard.Panels, Panel{
+			ID: id, Type: "row", Title: row.title, Collapsed: &collapsed,
+			GridPos: GridPos{H: 1, W: 24, X: 0, Y: y},
+		})
+		id++
+		y++
+		for i, panel := range row.panels {
+			dashboard.Panels = append(dashboard.Panels, panel.layout(id, GridPos{
+				H: panelHeight, W: panelWidth, X: (i % panelsPerRow) * panelWidth, Y: y + (i/panelsPerRow)*panelHeight,
+			}))
+			id++
+		}
+		y += (len(row.panels) + panelsPerRow - 1) / panelsPerRow * panelHeight
+	}
+	return dashboard
+}
+
+// layout creates the Grafana panel at the given position.
+func (p dashboardPanel) layout(id int, pos GridPos) Panel {
+	panel := Panel{
+		ID:          id,
+		Type:        p.panelType,
+		Title:       p.title,
+		Description: p.description,
+		Datasource:  datasource,
+		GridPos:     pos,
+		FieldConfig: &FieldConfig{Defaults: FieldDefaults{Unit: p.unit}},
+	}
+	if p.bars {
+		panel.FieldConfig.Defaults.Custom = map[string]string{"drawStyle": "bars"}
+	}
+	for i, q := range p.queries {
+		panel.Targets = append(panel.Targets, Target{
+			Datasource:   datasource,
+			Expr:         q.expr,
+			LegendFormat: q.legend,
+			RefID:        string(rune('A' + i)),
+		})
+	}
+	return panel
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+func main() {
+	// generate the dashboard from the operator metrics
+	data, err := json.MarshalIndent(monitoring.NewDashboard(), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	// print the generated dashboard
+	fmt.Println(string(data))
+}
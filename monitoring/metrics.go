@@ -1,10 +1,18 @@
 package monitoring
 
 import (
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+// backendLabels identify a HAProxy backend by the Backend resource it is managed by.
+var backendLabels = []string{"namespace", "name", "backend"}
+
+// serverLabels identify a server of a HAProxy backend by the Backend resource it is managed by.
+var serverLabels = []string{"namespace", "name", "backend", "server"}
+
 // HAProxyClientErrorCountTotal is a Prometheus counter metric to track the number of errors from the HAProxy client.
 var (
 	HAProxyClientErrorCountTotal = prometheus.NewCounter(
@@ -15,17 +23,267 @@ var (
 	)
 )
 
-// RegisterMetrics registers the HAProxyClientErrorCountTotal metric with the controller-runtime metrics registry.
+// Transaction results counted by HAProxyClientTransactionsTotal.
+const (
+	TransactionStarted   = "started"
+	TransactionCommitted = "committed"
+	TransactionAborted   = "aborted"
+	TransactionNoop      = "noop"
+	TransactionRestarted = "restarted"
+)
+
+// Metrics of the HAProxy client and the reconcilers.
+var (
+	HAProxyClientRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "haproxy_client_request_duration_seconds",
+			Help:    "Latency of Data Plane API requests by operation and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"operation", "code"},
+	)
+	HAProxyClientTransactionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "haproxy_client_transactions_total",
+			Help: "Number of Data Plane API transactions by result: started, committed, aborted, noop when deleted without changes, or restarted after a conflict.",
+		}, []string{"result"},
+	)
+	HAProxyClientReloadCommitsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "haproxy_client_reload_commits_total",
+			Help: "Number of committed transactions that made HAProxy reload.",
+		},
+	)
+	HAProxyClientCircuitOpen = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "haproxy_client_circuit_open",
+			Help: "Whether requests to the Data Plane API are short-circuited because it is unavailable (1) or not (0).",
+		},
+	)
+	HAProxyClientRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "haproxy_client_retries_total",
+			Help: "Number of Data Plane API requests retried after a retryable error, by operation.",
+		}, []string{"operation"},
+	)
+	ReconcileFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "haproxy_operator_reconcile_failures_total",
+			Help: "Number of failed reconciliations by controller and condition reason.",
+		}, []string{"controller", "reason"},
+	)
+)
+
+// Gauges sourced from the HAProxy native stats of the backends managed by Backend resources.
+var (
+	BackendServers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "haproxy_backend_servers",
+			Help: "Number of servers HAProxy reports for the backend.",
+		}, backendLabels,
+	)
+	BackendUpServers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "haproxy_backend_up_servers",
+			Help: "Number of servers of the backend that HAProxy reports UP.",
+		}, backendLabels,
+	)
+	ServerUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "haproxy_backend_server_up",
+			Help: "Whether HAProxy reports the server UP (1) or not (0).",
+		}, serverLabels,
+	)
+	ServerCurrentSessions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "haproxy_backend_server_current_sessions",
+			Help: "Number of sessions the server is currently handling.",
+		}, serverLabels,
+	)
+	ServerCurrentQueue = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "haproxy_backend_server_current_queue",
+			Help: "Number of requests queued for the server.",
+		}, serverLabels,
+	)
+	ServerQueueTimeAverage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "haproxy_backend_server_queue_time_average_seconds",
+			Help: "Average time requests spent in the queue over the last 1024 requests.",
+		}, serverLabels,
+	)
+	ServerConnectTimeAverage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "haproxy_backend_server_connect_time_average_seconds",
+			Help: "Average time to connect to the server over the last 1024 requests.",
+		}, serverLabels,
+	)
+	ServerResponseTimeAverage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "haproxy_backend_server_response_time_average_seconds",
+			Help: "Average response time of the server over the last 1024 requests.",
+		}, serverLabels,
+	)
+	ServerTotalTimeAverage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "haproxy_backend_server_total_time_average_seconds",
+			Help: "Average total session time on the server over the last 1024 requests.",
+		}, serverLabels,
+	)
+	ServerHTTPResponses5xxTotal = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "haproxy_backend_server_http_responses_5xx_total",
+			Help: "Number of HTTP responses with a 5xx status from the server since HAProxy started. A counter of HAProxy exported as a gauge, which resets when HAProxy restarts: use rate() or increase().",
+		}, serverLabels,
+	)
+	ServerCheckFailures = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "haproxy_backend_server_check_failures",
+			Help: "Number of failed health checks of the server since HAProxy started.",
+		}, serverLabels,
+	)
+)
+
+// Metrics of the state of the Backend resources and of HAProxy as a whole, maintained by the
+// reconcilers rather than sourced from the HAProxy stats.
+var (
+	BackendReconciled = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "haproxy_backend_reconciled",
+			Help: "Whether the last reconciliation of the Backend resource succeeded (1) or not (0).",
+		}, backendLabels,
+	)
+	BackendDriftCorrectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "haproxy_backend_drift_corrections_total",
+			Help: "Number of times the backend in HAProxy had changed although the Backend resource had not, and was corrected.",
+		}, backendLabels,
+	)
+	BackendRollbacksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "haproxy_backend_rollbacks_total",
+			Help: "Number of times the HAProxy configuration was rolled back because HAProxy failed to reload with the changes to the Backend resource.",
+		}, backendLabels,
+	)
+	OrphanedBackends = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "haproxy_operator_orphaned_backends",
+			Help: "Number of backends in HAProxy marked as managed by the operator that no Backend resource manages.",
+		},
+	)
+)
+
+// backendGauges are the gauges labelled by Backend resource.
+var backendGauges = []*prometheus.GaugeVec{
+	BackendServers,
+	BackendUpServers,
+	ServerUp,
+	ServerCurrentSessions,
+	ServerCurrentQueue,
+	ServerQueueTimeAverage,
+	ServerConnectTimeAverage,
+	ServerResponseTimeAverage,
+	ServerTotalTimeAverage,
+	ServerHTTPResponses5xxTotal,
+	ServerCheckFailures,
+}
+
+// RegisterMetrics registers the operator metrics with the controller-runtime metrics registry.
 func RegisterMetrics() {
-	metrics.Registry.MustRegister(HAProxyClientErrorCountTotal)
+	metrics.Registry.MustRegister(
+		HAProxyClientErrorCountTotal,
+		HAProxyClientRequestDuration,
+		HAProxyClientTransactionsTotal,
+		HAProxyClientReloadCommitsTotal,
+		HAProxyClientRetriesTotal,
+		HAProxyClientCircuitOpen,
+		ReconcileFailuresTotal,
+		BackendReconciled,
+		BackendDriftCorrectionsTotal,
+		BackendRollbacksTotal,
+		OrphanedBackends,
+	)
+	for _, gauge := range backendGauges {
+		metrics.Registry.MustRegister(gauge)
+	}
+}
+
+// reportedBackend is the backend in HAProxy and the servers whose gauges are set for a Backend
+// resource.
+type reportedBackend struct {
+	backend string
+	servers map[string]bool
+}
+
+var (
+	reportedMu sync.Mutex
+	// reported are the reported backends by the "namespace/name" keys of their Backend resources.
+	reported = map[string]reportedBackend{}
+)
+
+// DeleteBackendMetrics removes the gauges of a Backend resource, so that deleted Backends and
+// Backends whose stats are unavailable stop being reported.
+func DeleteBackendMetrics(namespace, name string) {
+	reportedMu.Lock()
+	delete(reported, namespace+"/"+name)
+	reportedMu.Unlock()
+
+	labels := prometheus.Labels{"namespace": namespace, "name": name}
+	for _, gauge := range backendGauges {
+		gauge.DeletePartialMatch(labels)
+	}
+}
+
+// DeleteVanishedServerMetrics records the servers HAProxy reports for the backend of a Backend
+// resource, and removes the gauges of the servers it reported before but no longer does, and those
+// of the previous backend when the Backend was renamed. The gauges of the servers still reported
+// are kept, so that a scrape does not miss them while they are set.
+func DeleteVanishedServerMetrics(namespace, name, backend string, servers []string) {
+	current := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		current[server] = true
+	}
+
+	reportedMu.Lock()
+	defer reportedMu.Unlock()
+	key := namespace + "/" + name
+	previous, ok := reported[key]
+	reported[key] = reportedBackend{backend: backend, servers: current}
+	if !ok {
+		return
+	}
+	if previous.backend != backend {
+		labels := prometheus.Labels{"namespace": namespace, "name": name, "backend": previous.backend}
+		for _, gauge := range backendGauges {
+			gauge.DeletePartialMatch(labels)
+		}
+		return
+	}
+	for server := range previous.servers {
+		if current[server] {
+			continue
+		}
+		labels := prometheus.Labels{"namespace": namespace, "name": name, "server": server}
+		for _, gauge := range backendGauges {
+			gauge.DeletePartialMatch(labels)
+		}
+	}
+}
+
+// DeleteBackendReconcileMetrics removes the reconciliation metrics of a deleted Backend resource.
+func DeleteBackendReconcileMetrics(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "name": name}
+	BackendReconciled.DeletePartialMatch(labels)
+	BackendDriftCorrectionsTotal.DeletePartialMatch(labels)
+	BackendRollbacksTotal.DeletePartialMatch(labels)
 }
 
 // MetricDescription is an exported struct that defines the metric description (Name, Help)
 // as a new type named MetricDescription.
 type MetricDescription struct {
-	Name string
-	Help string
-	Type string
+	Name   string
+	Help   string
+	Type   string
+	Labels []string
 }
 
 // metricsDescription is a map of string keys (metrics) to MetricDescription values (Name, Help).
@@ -35,6 +293,129 @@ var metricDescription = map[string]MetricDescription{
 		Help: "Total number of errors from the HAProxy client.",
 		Type: "Counter",
 	},
+	"HAProxyClientRequestDuration": {
+		Name:   "haproxy_client_request_duration_seconds",
+		Help:   "Latency of Data Plane API requests by operation and status code.",
+		Type:   "Histogram",
+		Labels: []string{"operation", "code"},
+	},
+	"HAProxyClientTransactionsTotal": {
+		Name:   "haproxy_client_transactions_total",
+		Help:   "Number of Data Plane API transactions by result: started, committed, aborted, noop when deleted without changes, or restarted after a conflict.",
+		Type:   "Counter",
+		Labels: []string{"result"},
+	},
+	"HAProxyClientReloadCommitsTotal": {
+		Name: "haproxy_client_reload_commits_total",
+		Help: "Number of committed transactions that made HAProxy reload.",
+		Type: "Counter",
+	},
+	"HAProxyClientCircuitOpen": {
+		Name: "haproxy_client_circuit_open",
+		Help: "Whether requests to the Data Plane API are short-circuited because it is unavailable (1) or not (0).",
+		Type: "Gauge",
+	},
+	"HAProxyClientRetriesTotal": {
+		Name:   "haproxy_client_retries_total",
+		Help:   "Number of Data Plane API requests retried after a retryable error, by operation.",
+		Type:   "Counter",
+		Labels: []string{"operation"},
+	},
+	"ReconcileFailuresTotal": {
+		Name:   "haproxy_operator_reconcile_failures_total",
+		Help:   "Number of failed reconciliations by controller and condition reason.",
+		Type:   "Counter",
+		Labels: []string{"controller", "reason"},
+	},
+	"BackendReconciled": {
+		Name:   "haproxy_backend_reconciled",
+		Help:   "Whether the last reconciliation of the Backend resource succeeded (1) or not (0).",
+		Type:   "Gauge",
+		Labels: backendLabels,
+	},
+	"BackendDriftCorrectionsTotal": {
+		Name:   "haproxy_backend_drift_corrections_total",
+		Help:   "Number of times the backend in HAProxy had changed although the Backend resource had not, and was corrected.",
+		Type:   "Counter",
+		Labels: backendLabels,
+	},
+	"BackendRollbacksTotal": {
+		Name:   "haproxy_backend_rollbacks_total",
+		Help:   "Number of times the HAProxy configuration was rolled back because HAProxy failed to reload with the changes to the Backend resource.",
+		Type:   "Counter",
+		Labels: backendLabels,
+	},
+	"OrphanedBackends": {
+		Name: "haproxy_operator_orphaned_backends",
+		Help: "Number of backends in HAProxy marked as managed by the operator that no Backend resource manages.",
+		Type: "Gauge",
+	},
+	"BackendServers": {
+		Name:   "haproxy_backend_servers",
+		Help:   "Number of servers HAProxy reports for the backend.",
+		Type:   "Gauge",
+		Labels: backendLabels,
+	},
+	"BackendUpServers": {
+		Name:   "haproxy_backend_up_servers",
+		Help:   "Number of servers of the backend that HAProxy reports UP.",
+		Type:   "Gauge",
+		Labels: backendLabels,
+	},
+	"ServerUp": {
+		Name:   "haproxy_backend_server_up",
+		Help:   "Whether HAProxy reports the server UP (1) or not (0).",
+		Type:   "Gauge",
+		Labels: serverLabels,
+	},
+	"ServerCurrentSessions": {
+		Name:   "haproxy_backend_server_current_sessions",
+		Help:   "Number of sessions the server is currently handling.",
+		Type:   "Gauge",
+		Labels: serverLabels,
+	},
+	"ServerCurrentQueue": {
+		Name:   "haproxy_backend_server_current_queue",
+		Help:   "Number of requests queued for the server.",
+		Type:   "Gauge",
+		Labels: serverLabels,
+	},
+	"ServerQueueTimeAverage": {
+		Name:   "haproxy_backend_server_queue_time_average_seconds",
+		Help:   "Average time requests spent in the queue over the last 1024 requests.",
+		Type:   "Gauge",
+		Labels: serverLabels,
+	},
+	"ServerConnectTimeAverage": {
+		Name:   "haproxy_backend_server_connect_time_average_seconds",
+		Help:   "Average time to connect to the server over the last 1024 requests.",
+		Type:   "Gauge",
+		Labels: serverLabels,
+	},
+	"ServerResponseTimeAverage": {
+		Name:   "haproxy_backend_server_response_time_average_seconds",
+		Help:   "Average response time of the server over the last 1024 requests.",
+		Type:   "Gauge",
+		Labels: serverLabels,
+	},
+	"ServerTotalTimeAverage": {
+		Name:   "haproxy_backend_server_total_time_average_seconds",
+		Help:   "Average total session time on the server over the last 1024 requests.",
+		Type:   "Gauge",
+		Labels: serverLabels,
+	},
+	"ServerHTTPResponses5xxTotal": {
+		Name:   "haproxy_backend_server_http_responses_5xx_total",
+		Help:   "Number of HTTP responses with a 5xx status from the server since HAProxy started. A counter of HAProxy exported as a gauge, which resets when HAProxy restarts: use rate() or increase().",
+		Type:   "Gauge",
+		Labels: serverLabels,
+	},
+	"ServerCheckFailures": {
+		Name:   "haproxy_backend_server_check_failures",
+		Help:   "Number of failed health checks of the server since HAProxy started.",
+		Type:   "Gauge",
+		Labels: serverLabels,
+	},
 }
 
 // ListMetrics will create a slice with the metrics available in metricDescription
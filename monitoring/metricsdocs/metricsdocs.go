@@ -20,13 +20,21 @@ func main() {
 		"The metrics documentation is auto-generated by the utility tool \"monitoring/metricsdocs\"" +
 		" and reflects all of the metrics that are exposed by the operator.\n\n" +
 		"## Operator Metrics List" +
-		"{{range .}}\n" +
+		"{{range .Metrics}}\n" +
 		"### {{.Name}}\n" +
 		"{{.Help}} " +
-		"Type: {{.Type}}.\n" +
+		"Type: {{.Type}}." +
+		"{{if .Labels}} Labels: {{range $i, $label := .Labels}}{{if $i}}, {{end}}`{{$label}}`{{end}}.{{end}}\n" +
+		"{{end}}" +
+		"\n## Recording Rules\n" +
+		"The PrometheusRule installed by the operator records the following metrics.\n" +
+		"{{range .RecordingRules}}\n" +
+		"### {{.Record}}\n" +
+		"{{.Help}} " +
+		"Expression: `{{.Expr}}`.\n" +
 		"{{end}}" +
 		"## Developing new metrics\n" +
-		"After developing new metrics or changing old ones, please run \"make generate-metricsdocs\"" +
+		"After developing new metrics or recording rules or changing old ones, please run \"make generate-metricsdocs\"" +
 		" to regenerate this document.\n\n" +
 		"If you feel that the new metric doesn't follow these rules, please change \"monitoring/metricsdocs\"" +
 		" according to your needs.")
@@ -35,9 +43,18 @@ func main() {
 		panic(err)
 	}
 
-	// generate the template using the sorted list of metrics
+	recordingRules := monitoring.ListRecordingRules()
+	sort.Slice(recordingRules, func(i, j int) bool {
+		return recordingRules[i].Record < recordingRules[j].Record
+	})
+
+	// generate the template using the sorted lists of metrics and recording rules
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, metricDescriptions); err != nil {
+	err = tmpl.Execute(&buf, struct {
+		Metrics        []monitoring.MetricDescription
+		RecordingRules []monitoring.RecordingRule
+	}{metricDescriptions, recordingRules})
+	if err != nil {
 		panic(err)
 	}
 
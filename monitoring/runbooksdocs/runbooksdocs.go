@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/ullbergm/external-haproxy-operator/monitoring"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: runbooksdocs <output directory>")
+		os.Exit(1)
+	}
+	dir := os.Args[1]
+
+	tmpl, err := template.New("Runbook").Parse("# Runbook: {{.Runbook}}\n\n" +
+		"<!-- This runbook is auto-generated by the utility tool \"monitoring/runbooksdocs\"" +
+		" from the alerts in \"monitoring/alerts.go\". Please run \"make generate-runbooks\" after changing them. -->\n\n" +
+		"## Alert\n" +
+		"**{{.Name}}** (severity: {{.Severity}})\n\n" +
+		"```promql\n{{.Expr}}\n```\n" +
+		"{{if .For}}\nThe alert fires when the expression holds for {{.For}}.\n{{end}}\n" +
+		"## Description\n" +
+		"{{.Summary}} {{.Description}}\n\n" +
+		"## Possible Causes\n" +
+		"{{range .Causes}}- {{.}}\n{{end}}\n" +
+		"## Diagnosis\n" +
+		"{{range .Diagnosis}}1. {{.}}\n{{end}}\n" +
+		"## Mitigation\n" +
+		"{{range .Mitigation}}- {{.}}\n{{end}}\n" +
+		"## Escalation\n" +
+		"If the issue persists after following the above steps, open an issue at " +
+		"https://github.com/ullbergm/external-haproxy-operator/issues with the operator logs, " +
+		"the affected resources and details of recent changes.\n")
+	if err != nil {
+		panic(err)
+	}
+
+	// generate one runbook per alert
+	for _, alert := range monitoring.ListAlerts() {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, alert); err != nil {
+			panic(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, alert.Runbook()+".md"), buf.Bytes(), 0o644); err != nil {
+			panic(err)
+		}
+	}
+}